@@ -0,0 +1,470 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/darylhjd/mangodex"
+
+	"github.com/darylhjd/mangadesk/app/core"
+)
+
+// The OAuth2 endpoints of the MangaDex authentication server, which personal API clients log in with. Requests for
+// them are sent to another server if the authUrl setting, or the apiUrl setting, says so.
+const (
+	tokenURL  = "https://auth.mangadex.org/realms/mangadex/protocol/openid-connect/token"
+	logoutURL = "https://auth.mangadex.org/realms/mangadex/protocol/openid-connect/logout"
+	apiHost   = "api.mangadex.org"
+
+	// refreshMargin : How long before the access token expires that it is refreshed, so that it does not expire
+	// while a request is being sent.
+	refreshMargin = time.Minute
+	// refreshInterval : How often the access token is checked, to refresh it in the background before it is needed.
+	refreshInterval = 30 * time.Second
+)
+
+var (
+	// ErrNoSession : The user has not logged in before, so there is no session to resume.
+	ErrNoSession = errors.New("not logged in, log in from the app first")
+	// ErrOldSession : The stored session is from before MangaDex needed personal API clients to log in.
+	ErrOldSession = errors.New("the stored session is from an older version of the app, log in again with a " +
+		"personal API client")
+	// ErrSessionExpired : The session has ended, such as when it has not been used for a long time, or the user
+	// logged out elsewhere.
+	ErrSessionExpired = errors.New("session expired, log in again")
+	// ErrInvalidLogin : The username or password is wrong.
+	ErrInvalidLogin = errors.New("wrong username or password")
+	// ErrInvalidClient : The personal API client does not exist, or its secret is wrong. This is also the case once
+	// the client is deleted, or its secret is regenerated.
+	ErrInvalidClient = errors.New("the API client ID or secret is wrong, or the client was deleted or its secret " +
+		"regenerated; check the API Clients section of your MangaDex settings")
+	// ErrClientNotAllowed : The personal API client cannot log in, such as when it has not been approved yet, or it
+	// was disabled.
+	ErrClientNotAllowed = errors.New("the API client cannot be used to log in, it may not be approved yet or " +
+		"may have been disabled; check the API Clients section of your MangaDex settings")
+)
+
+// CredentialStore : Keeps the user's session between runs of the app.
+type CredentialStore interface {
+	Load() (core.Credentials, error) // Get the stored credentials. Returns core.ErrNoSecret if there are none.
+	Store(creds core.Credentials) error
+	Delete() error
+}
+
+// Auth : Logging the user in and out with their personal API client, and keeping their session.
+// Requests for the API are authorised with the session by Authorise.
+type Auth struct {
+	client      *mangodex.DexClient
+	credentials CredentialStore
+	http        *http.Client // For the authentication server.
+
+	mu      sync.Mutex
+	session *session // Nil if the user is not logged in. Set with setSession.
+	// refreshing : The refresh being sent, if any. Only one is sent at a time, and others wait for it instead.
+	refreshing *refreshCall
+	// ended : Called when the session ends without the user logging out. May be nil.
+	ended func(err error, remembered bool)
+	// loggedIn : Whether there is a session, so that it can be checked without waiting for the lock.
+	loggedIn atomic.Bool
+}
+
+// session : The tokens of a logged in user, and the client they were given to.
+type session struct {
+	creds      core.Credentials
+	access     string
+	expires    time.Time
+	remembered bool // Whether the credentials are stored, so that they are stored again when the tokens change.
+}
+
+// refreshCall : A refresh of the session. done is closed once it is finished, after which err is set.
+type refreshCall struct {
+	done chan struct{}
+	err  error
+}
+
+// tokenResponse : The response of the authentication server when it gives out tokens.
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"` // In seconds.
+}
+
+// tokenError : An error from the authentication server.
+type tokenError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+// NewAuth : Create the authentication service, which sends requests for the authentication server with httpClient.
+func NewAuth(client *mangodex.DexClient, httpClient *http.Client, credentials CredentialStore) *Auth {
+	return &Auth{client: client, credentials: credentials, http: httpClient}
+}
+
+// LoggedIn : Check whether the user is logged in.
+func (a *Auth) LoggedIn() bool {
+	return a.loggedIn.Load()
+}
+
+// Login : Log in with the ID and secret of a personal API client, and the username and password of the user who
+// owns it.
+func (a *Auth) Login(ctx context.Context, clientID, clientSecret, user, pwd string) error {
+	creds := core.Credentials{ClientID: strings.TrimSpace(clientID), ClientSecret: strings.TrimSpace(clientSecret)}
+	tokens, err := a.requestTokens(ctx, creds, url.Values{
+		"grant_type": {"password"},
+		"username":   {user},
+		"password":   {pwd},
+	})
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.setSession(newSession(creds, tokens))
+	return nil
+}
+
+// Remember : Store the user's session, so that it can be resumed the next time the app is run.
+func (a *Auth) Remember() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.session == nil {
+		return errors.New("not logged in")
+	}
+	a.session.remembered = true
+	return a.credentials.Store(a.session.creds)
+}
+
+// Resume : Log in with the stored session. Returns ErrNoSession if there is none, or an error saying why it
+// cannot be resumed, such as ErrSessionExpired.
+func (a *Auth) Resume(ctx context.Context) error {
+	creds, err := a.credentials.Load()
+	if errors.Is(err, core.ErrNoSecret) {
+		return ErrNoSession
+	} else if err != nil {
+		return fmt.Errorf("unable to read the stored session: %w", err)
+	}
+	if creds.ClientID == "" {
+		return ErrOldSession
+	}
+	// The session is not used until it is refreshed, so the user is not logged in before then.
+	tokens, err := a.requestTokens(ctx, creds, refreshGrant(creds))
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	s := &session{creds: creds, remembered: true}
+	if err != nil {
+		a.endedBy(s, err)
+		return err
+	}
+	a.setSession(a.refreshed(s, tokens))
+	return nil
+}
+
+// Logout : Log the user out, and delete their stored session. The user is logged out of the app even if the
+// session cannot be ended on MangaDex, in which case the error is returned.
+func (a *Auth) Logout(ctx context.Context) error {
+	a.mu.Lock()
+	s := a.session
+	a.setSession(nil)
+	a.mu.Unlock()
+	// A stored session that could not be resumed is deleted too.
+	if err := a.credentials.Delete(); err != nil {
+		core.LogErrorf("Unable to delete stored credentials: %s\n", err.Error())
+	}
+	if s == nil {
+		return nil
+	}
+
+	form := url.Values{
+		"client_id":     {s.creds.ClientID},
+		"client_secret": {s.creds.ClientSecret},
+		"refresh_token": {s.creds.RefreshToken},
+	}
+	resp, err := a.post(ctx, logoutURL, form)
+	if err != nil {
+		return fmt.Errorf("unable to end the session on MangaDex: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unable to end the session on MangaDex: %w", decodeTokenError(resp))
+	}
+	return nil
+}
+
+// Username : Get the name of the logged in user.
+func (a *Auth) Username(ctx context.Context) (string, error) {
+	u, err := a.client.User.GetLoggedUserContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	return u.Data.Attributes.Username, nil
+}
+
+// OnSessionEnded : Call a function when the session ends without the user logging out, such as when it expires
+// or the API client is deleted. It is called on its own goroutine, with why the session ended, and whether it was
+// remembered, so that the session the user logs in with again can be remembered too.
+func (a *Auth) OnSessionEnded(f func(err error, remembered bool)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.ended = f
+}
+
+// RefreshInBackground : Keep refreshing the access token before it expires, so that requests do not have to wait
+// for it, until the context is done.
+func (a *Auth) RefreshInBackground(ctx context.Context) {
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.refreshIfExpiring(ctx, refreshMargin+refreshInterval)
+		}
+	}
+}
+
+// refreshIfExpiring : Refresh the access token if it expires within some time. Problems are only logged, as the
+// token is refreshed again when it is needed.
+func (a *Auth) refreshIfExpiring(ctx context.Context, within time.Duration) {
+	s := a.current()
+	if s == nil || time.Until(s.expires) >= within {
+		return
+	}
+	if err := a.refresh(ctx, s); err != nil {
+		core.LogErrorf("Unable to refresh session: %s\n", err.Error())
+	}
+}
+
+// Authorise : Send a request with the user's access token if it is for the API, using next to send it. The access
+// token is refreshed first if it is about to expire. If it is refused, it is refreshed and the request is sent
+// once more. This is a core.Authoriser.
+func (a *Auth) Authorise(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+	if !strings.EqualFold(req.URL.Hostname(), apiHost) || req.Header.Get("Authorization") != "" {
+		return next.RoundTrip(req)
+	}
+	access, err := a.accessToken(req.Context(), "")
+	if err != nil {
+		return nil, err
+	} else if access == "" {
+		return next.RoundTrip(req)
+	}
+	resp, err := next.RoundTrip(withToken(req, access))
+	// Requests with a body can only be sent again if the body can be read again.
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || (req.Body != nil && req.GetBody == nil) {
+		return resp, err
+	}
+
+	// The token was refused, such as when the session was ended on the server, so it is refreshed.
+	log.Println("Access token refused, refreshing session...")
+	if access, err = a.accessToken(req.Context(), access); err != nil || access == "" {
+		return resp, nil
+	}
+	_ = resp.Body.Close()
+	retry := withToken(req, access)
+	if req.GetBody != nil {
+		if retry.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	return next.RoundTrip(retry)
+}
+
+// accessToken : Get the access token of the session, refreshing it if it is about to expire, or if it is the token
+// that was refused. Empty if the user is not logged in.
+func (a *Auth) accessToken(ctx context.Context, refused string) (string, error) {
+	s := a.current()
+	if s == nil {
+		return "", nil
+	}
+	// Another request may have refreshed the refused token already.
+	if (refused == "" && time.Until(s.expires) < refreshMargin) || s.access == refused {
+		if err := a.refresh(ctx, s); err != nil {
+			// A token that has not expired can still be used, if it was not refused and the session has not ended.
+			if refused != "" || time.Now().After(s.expires) || !a.LoggedIn() {
+				return "", err
+			}
+			core.LogErrorf("Unable to refresh session, using current token: %s\n", err.Error())
+			return s.access, nil
+		}
+		if s = a.current(); s == nil {
+			return "", nil
+		}
+	}
+	return s.access, nil
+}
+
+// withToken : Copy a request, with an access token. Requests must not be changed, and clients may share their
+// headers between requests.
+func withToken(req *http.Request, access string) *http.Request {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+access)
+	return req
+}
+
+// current : Get the session, which is nil if the user is not logged in. Its tokens are not changed once it is used,
+// as it is replaced instead, so they can be read without the lock. Whether it is remembered can change.
+func (a *Auth) current() *session {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.session
+}
+
+// setSession : Replace the session. The lock must be held.
+func (a *Auth) setSession(s *session) {
+	a.session = s
+	a.loggedIn.Store(s != nil)
+}
+
+// refresh : Get new tokens for a session with its refresh token, unless it was replaced already, such as by
+// another refresh. If the session has ended, the user is logged out, and told so. Only one refresh is sent at a
+// time, and requests that need one while it is being sent wait for it and get its result instead. The lock must not
+// be held, as it is not held while waiting for the authentication server, so that the user can be checked to be
+// logged in meanwhile.
+func (a *Auth) refresh(ctx context.Context, s *session) error {
+	a.mu.Lock()
+	if a.session != s {
+		a.mu.Unlock()
+		return nil
+	}
+	if call := a.refreshing; call != nil {
+		a.mu.Unlock()
+		select {
+		case <-call.done:
+			return call.err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	call := &refreshCall{done: make(chan struct{})}
+	a.refreshing = call
+	a.mu.Unlock()
+
+	tokens, err := a.requestTokens(ctx, s.creds, refreshGrant(s.creds))
+
+	a.mu.Lock()
+	a.refreshing = nil
+	// The tokens are not used if the user logged out, or in again, while they were requested.
+	if a.session == s {
+		if err == nil {
+			a.setSession(a.refreshed(s, tokens))
+		} else if a.endedBy(s, err) {
+			a.setSession(nil)
+			if a.ended != nil {
+				go a.ended(err, s.remembered)
+			}
+		}
+	}
+	a.mu.Unlock()
+	call.err = err
+	close(call.done)
+	return err
+}
+
+// refreshed : Create the session that a session is replaced with once it is refreshed. The lock must be held.
+func (a *Auth) refreshed(s *session, tokens *tokenResponse) *session {
+	refreshed := newSession(s.creds, tokens)
+	refreshed.remembered = s.remembered
+	// The refresh token may change, so the stored one is replaced to keep it valid.
+	if refreshed.remembered {
+		if err := a.credentials.Store(refreshed.creds); err != nil {
+			core.LogErrorf("Unable to store refreshed credentials: %s\n", err.Error())
+		}
+	}
+	return refreshed
+}
+
+// endedBy : Check whether a session has ended, from why it could not be refreshed. If so, the stored session cannot
+// be resumed either, so it is deleted instead of being tried on every run. Clients that are not allowed to log in
+// may be allowed again, so their session is kept. The lock must be held.
+func (a *Auth) endedBy(s *session, err error) bool {
+	if !errors.Is(err, ErrSessionExpired) && !errors.Is(err, ErrInvalidClient) && !errors.Is(err, ErrClientNotAllowed) {
+		return false
+	}
+	core.LogErrorf("Session ended: %s\n", err.Error())
+	if s.remembered && !errors.Is(err, ErrClientNotAllowed) {
+		if derr := a.credentials.Delete(); derr != nil {
+			core.LogErrorf("Unable to delete stored credentials: %s\n", derr.Error())
+		}
+	}
+	return true
+}
+
+// refreshGrant : The form that asks for new tokens with the refresh token of a client.
+func refreshGrant(creds core.Credentials) url.Values {
+	return url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {creds.RefreshToken},
+	}
+}
+
+// requestTokens : Ask the authentication server for tokens with a grant, on behalf of a client.
+func (a *Auth) requestTokens(ctx context.Context, creds core.Credentials, form url.Values) (*tokenResponse, error) {
+	if creds.ClientID == "" || creds.ClientSecret == "" {
+		return nil, errors.New("the API client ID and secret are needed to log in")
+	}
+	form.Set("client_id", creds.ClientID)
+	form.Set("client_secret", creds.ClientSecret)
+	resp, err := a.post(ctx, tokenURL, form)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, decodeTokenError(resp)
+	}
+	var tokens tokenResponse
+	if err = json.NewDecoder(resp.Body).Decode(&tokens); err != nil {
+		return nil, fmt.Errorf("unexpected response from the authentication server: %w", err)
+	}
+	return &tokens, nil
+}
+
+// post : Send a form to the authentication server.
+func (a *Auth) post(ctx context.Context, endpoint string, form url.Values) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return a.http.Do(req)
+}
+
+// newSession : Create a session from the tokens given to a client.
+func newSession(creds core.Credentials, tokens *tokenResponse) *session {
+	creds.RefreshToken = tokens.RefreshToken
+	return &session{
+		creds:   creds,
+		access:  tokens.AccessToken,
+		expires: time.Now().Add(time.Duration(tokens.ExpiresIn) * time.Second),
+	}
+}
+
+// decodeTokenError : Describe an error response from the authentication server.
+func decodeTokenError(resp *http.Response) error {
+	var e tokenError
+	if err := json.NewDecoder(resp.Body).Decode(&e); err != nil || e.Code == "" {
+		return fmt.Errorf("authentication server responded with %s", resp.Status)
+	}
+	switch e.Code {
+	case "invalid_client":
+		return ErrInvalidClient
+	case "unauthorized_client":
+		return ErrClientNotAllowed
+	case "invalid_grant":
+		// The same error is given for a wrong password and for a session that has ended.
+		if strings.Contains(strings.ToLower(e.Description), "credentials") {
+			return ErrInvalidLogin
+		}
+		return fmt.Errorf("%w (%s)", ErrSessionExpired, e.Description)
+	}
+	return fmt.Errorf("authentication failed: %s (%s)", e.Description, e.Code)
+}
@@ -0,0 +1,43 @@
+// Package backend : The logic of the app that does not depend on the terminal interface, such as finding chapters,
+// downloading them, logging in and listing manga. Everything a service needs is given to it when it is created,
+// so the same services back the app, the command-line commands and tests.
+package backend
+
+import (
+	"net/http"
+
+	"github.com/darylhjd/mangodex"
+
+	"github.com/darylhjd/mangadesk/app/core"
+)
+
+// Services : The services that the app uses.
+type Services struct {
+	Library   *Library
+	Downloads *Downloads
+	Auth      *Auth
+	Feed      *Feed
+}
+
+// Config : Get the configuration to use. It is called whenever a setting is needed, as the configuration may be
+// changed while the app is running.
+type Config func() *core.UserConfig
+
+// New : Create the services, which contact MangaDex through a client. Requests that the client does not send, such
+// as for logging in and for chapter pages, are sent with httpClient. The user's session is kept in a credential
+// store.
+func New(client *mangodex.DexClient, httpClient *http.Client, config Config, credentials CredentialStore) *Services {
+	return &Services{
+		Library:   NewLibrary(client, config),
+		Downloads: NewDownloads(client, httpClient, config),
+		Auth:      NewAuth(client, httpClient, credentials),
+		Feed:      NewFeed(client, config),
+	}
+}
+
+// StaticConfig : Use a configuration that does not change, such as for a command or a test.
+func StaticConfig(conf *core.UserConfig) Config {
+	return func() *core.UserConfig {
+		return conf
+	}
+}
@@ -0,0 +1,452 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/darylhjd/mangodex"
+
+	"github.com/darylhjd/mangadesk/app/core"
+	"github.com/darylhjd/mangadesk/app/internal/fakedex"
+)
+
+const (
+	testMangaID = "a96676e5-8ae2-425e-b549-7f15dd34a6d8"
+	testUser    = "reader"
+	testPwd     = "hunter2"
+	testClient  = "personal-client-reader"
+	testSecret  = "client-secret"
+)
+
+// memoryCredentials : Keeps the credentials in memory.
+type memoryCredentials struct {
+	creds *core.Credentials
+}
+
+func (c *memoryCredentials) Load() (core.Credentials, error) {
+	if c.creds == nil {
+		return core.Credentials{}, core.ErrNoSecret
+	}
+	return *c.creds, nil
+}
+
+func (c *memoryCredentials) Store(creds core.Credentials) error {
+	c.creds = &creds
+	return nil
+}
+
+func (c *memoryCredentials) Delete() error {
+	c.creds = nil
+	return nil
+}
+
+// roundTripFunc : A transport that is a function.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// authorise : Authorise requests with the default transport with a session, as the app does. Returns a function
+// that stops doing so.
+func authorise(auth *Auth) (restore func()) {
+	original := http.DefaultTransport
+	http.DefaultTransport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return auth.Authorise(req, original)
+	})
+	return func() {
+		http.DefaultTransport = original
+	}
+}
+
+// login : Log in as the test user.
+func login(t *testing.T, auth *Auth) {
+	t.Helper()
+	if err := auth.Login(context.Background(), testClient, testSecret, testUser, testPwd); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newTestServices : Create services that use a fake MangaDex API with a manga, its chapters and a user.
+func newTestServices(t *testing.T) (*Services, *fakedex.Server, *core.UserConfig) {
+	t.Helper()
+	fake := fakedex.NewServer()
+	t.Cleanup(fake.Close)
+	// Requests are sent to the fake both by clients that can be given a transport, and by the client for MangaDex,
+	// which uses the default transport.
+	httpClient := &http.Client{Transport: fake.Transport(nil)}
+	t.Cleanup(fake.Intercept())
+
+	fake.AddUser(testUser, testPwd)
+	fake.AddClient(testClient, testSecret)
+	fake.AddManga(
+		fakedex.Manga{ID: testMangaID, Title: "Test Manga", Author: "Someone", Tags: []string{"Comedy"}},
+		fakedex.Manga{ID: "4f3a8e6e-9a27-4c55-9d33-0d3c52f5b0b1", Title: "Other Manga"},
+	)
+	fake.AddChapters(
+		fakedex.Chapter{ID: "c2", MangaID: testMangaID, Number: "2", Volume: "1", Title: "Two",
+			GroupID: "g1", Group: "Group One", Pages: []string{"a.png", "b.png"}},
+		fakedex.Chapter{ID: "c1", MangaID: testMangaID, Number: "1", Volume: "1", Title: "One",
+			GroupID: "g1", Group: "Group One", Pages: []string{"a.png"}},
+		fakedex.Chapter{ID: "c1-fr", MangaID: testMangaID, Number: "1", Volume: "1", Language: "fr",
+			GroupID: "g2", Group: "Group Two", Pages: []string{"a.png"}},
+	)
+
+	conf := &core.UserConfig{
+		Languages:       []string{"en"},
+		DownloadDir:     t.TempDir(),
+		DownloadQuality: "data",
+	}
+	s := New(mangodex.NewDexClient(), httpClient, StaticConfig(conf), &memoryCredentials{})
+	t.Cleanup(authorise(s.Auth))
+	return s, fake, conf
+}
+
+func TestLoginAndResume(t *testing.T) {
+	s, _, _ := newTestServices(t)
+	ctx := context.Background()
+
+	if err := s.Auth.Resume(ctx); !errors.Is(err, ErrNoSession) {
+		t.Fatalf("Resume before logging in = %v, want ErrNoSession", err)
+	}
+	if err := s.Auth.Login(ctx, testClient, testSecret, testUser, "wrong"); !errors.Is(err, ErrInvalidLogin) {
+		t.Fatalf("Login with the wrong password = %v, want ErrInvalidLogin", err)
+	}
+	if err := s.Auth.Login(ctx, testClient, "wrong", testUser, testPwd); !errors.Is(err, ErrInvalidClient) {
+		t.Fatalf("Login with the wrong client secret = %v, want ErrInvalidClient", err)
+	}
+	if s.Auth.LoggedIn() {
+		t.Fatal("logged in with the wrong credentials")
+	}
+	if err := s.Auth.Login(ctx, testClient, testSecret, testUser, testPwd); err != nil || !s.Auth.LoggedIn() {
+		t.Fatalf("Login = %v, want to be logged in", err)
+	}
+	if name, err := s.Auth.Username(ctx); err != nil || name != testUser {
+		t.Errorf("Username = %q, %v, want %q", name, err, testUser)
+	}
+	if err := s.Auth.Remember(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new client resumes the stored session.
+	resumed := NewAuth(mangodex.NewDexClient(), s.Auth.http, s.Auth.credentials)
+	defer authorise(resumed)()
+	if err := resumed.Resume(ctx); err != nil || !resumed.LoggedIn() {
+		t.Fatalf("Resume = %v, want to be logged in", err)
+	}
+	if err := resumed.Logout(ctx); err != nil || resumed.LoggedIn() {
+		t.Fatalf("Logout = %v, want to be logged out", err)
+	}
+	if err := resumed.Resume(ctx); !errors.Is(err, ErrNoSession) {
+		t.Errorf("Resume after logging out = %v, want ErrNoSession", err)
+	}
+}
+
+func TestRefreshBeforeExpiry(t *testing.T) {
+	s, fake, _ := newTestServices(t)
+	ctx := context.Background()
+
+	// Tokens that expire within the refresh margin are refreshed before every request.
+	fake.TokenLifetime = 1
+	login(t, s.Auth)
+	if err := s.Auth.Remember(); err != nil {
+		t.Fatal(err)
+	}
+	stored, _ := s.Auth.credentials.Load()
+	fake.ExpireSessions()
+	if name, err := s.Auth.Username(ctx); err != nil || name != testUser {
+		t.Fatalf("Username = %q, %v, want %q after refreshing", name, err, testUser)
+	}
+	// The refresh token changes, so the stored one is replaced.
+	if refreshed, _ := s.Auth.credentials.Load(); refreshed.RefreshToken == stored.RefreshToken {
+		t.Error("stored refresh token was not replaced")
+	}
+}
+
+func TestRevokedClient(t *testing.T) {
+	s, fake, _ := newTestServices(t)
+	ctx := context.Background()
+	login(t, s.Auth)
+	if err := s.Auth.Remember(); err != nil {
+		t.Fatal(err)
+	}
+
+	fake.RevokeClient(testClient)
+	resumed := NewAuth(mangodex.NewDexClient(), s.Auth.http, s.Auth.credentials)
+	if err := resumed.Resume(ctx); !errors.Is(err, ErrInvalidClient) || resumed.LoggedIn() {
+		t.Errorf("Resume with a revoked client = %v, want ErrInvalidClient", err)
+	}
+	// The stored session is deleted, so it is not tried again.
+	if err := resumed.Resume(ctx); !errors.Is(err, ErrNoSession) {
+		t.Errorf("Resume after the session ended = %v, want ErrNoSession", err)
+	}
+}
+
+func TestRefusedToken(t *testing.T) {
+	s, fake, _ := newTestServices(t)
+	ctx := context.Background()
+	login(t, s.Auth)
+
+	// Tokens ended on the server before they expire are refreshed, and the request is sent again.
+	fake.ExpireSessions()
+	if name, err := s.Auth.Username(ctx); err != nil || name != testUser {
+		t.Fatalf("Username = %q, %v, want %q after the token was refused", name, err, testUser)
+	}
+
+	// Once the client is deleted, the session ends, and whoever is watching it is told why.
+	if err := s.Auth.Remember(); err != nil {
+		t.Fatal(err)
+	}
+	ended := make(chan error, 1)
+	s.Auth.OnSessionEnded(func(err error, remembered bool) {
+		if !remembered {
+			err = fmt.Errorf("remembered session ended as not remembered: %w", err)
+		}
+		ended <- err
+	})
+	fake.RevokeClient(testClient)
+	fake.ExpireSessions()
+	if _, err := s.Auth.Username(ctx); err == nil {
+		t.Error("Username succeeded after the client was deleted")
+	}
+	select {
+	case err := <-ended:
+		if !errors.Is(err, ErrInvalidClient) {
+			t.Errorf("session ended with %v, want ErrInvalidClient", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("not told that the session ended")
+	}
+	if s.Auth.LoggedIn() {
+		t.Error("still logged in after the session ended")
+	}
+	if _, err := s.Auth.credentials.Load(); !errors.Is(err, core.ErrNoSecret) {
+		t.Errorf("stored credentials after the session ended = %v, want them deleted", err)
+	}
+}
+
+func TestRefreshInBackground(t *testing.T) {
+	s, fake, _ := newTestServices(t)
+	fake.TokenLifetime = 1
+	login(t, s.Auth)
+
+	s.Auth.mu.Lock()
+	access := s.Auth.session.access
+	s.Auth.mu.Unlock()
+	s.Auth.refreshIfExpiring(context.Background(), refreshMargin+refreshInterval)
+	s.Auth.mu.Lock()
+	defer s.Auth.mu.Unlock()
+	if s.Auth.session == nil || s.Auth.session.access == access {
+		t.Error("token that was about to expire was not refreshed")
+	}
+}
+
+func TestConcurrentRefresh(t *testing.T) {
+	s, fake, _ := newTestServices(t)
+	fake.TokenLifetime = 1
+	login(t, s.Auth)
+	fake.TokenLifetime = 900
+
+	// Requests for tokens are counted, and held until they are let through.
+	release := make(chan struct{})
+	var requests atomic.Int32
+	base := s.Auth.http.Transport
+	s.Auth.http = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		requests.Add(1)
+		<-release
+		return base.RoundTrip(req)
+	})}
+
+	// Requests that need the token to be refreshed while it is being refreshed wait for it.
+	errs := make(chan error, 3)
+	for i := 0; i < cap(errs); i++ {
+		go func() {
+			_, err := s.Auth.Username(context.Background())
+			errs <- err
+		}()
+	}
+	for requests.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	// Whether the user is logged in can be checked meanwhile.
+	if !s.Auth.LoggedIn() {
+		t.Error("not logged in while the session is refreshed")
+	}
+	close(release)
+	for i := 0; i < cap(errs); i++ {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("session was refreshed %d times, want once", n)
+	}
+}
+
+func TestOldSession(t *testing.T) {
+	// Sessions stored by older versions only have a refresh token.
+	auth := NewAuth(mangodex.NewDexClient(), &http.Client{}, &memoryCredentials{creds: &core.Credentials{RefreshToken: "old"}})
+	if err := auth.Resume(context.Background()); !errors.Is(err, ErrOldSession) {
+		t.Errorf("Resume = %v, want ErrOldSession", err)
+	}
+}
+
+func TestFeed(t *testing.T) {
+	s, fake, _ := newTestServices(t)
+	ctx := context.Background()
+
+	popular, err := s.Feed.Popular(ctx, 10, 0)
+	if err != nil || popular.Total != 2 {
+		t.Fatalf("Popular = %+v, %v, want 2 manga", popular, err)
+	}
+	if author := popular.Data[0].Relationships[0].Attributes.(*mangodex.AuthorAttributes).Name; author != "Someone" {
+		t.Errorf("author = %q, want Someone", author)
+	}
+
+	found, err := s.Feed.Search(ctx, "other", false, 10, 0)
+	if err != nil || len(found.Data) != 1 || found.Data[0].GetTitle("en") != "Other Manga" {
+		t.Errorf("Search = %+v, %v, want Other Manga", found, err)
+	}
+
+	if _, err = s.Feed.Followed(ctx, 10, 0); err == nil {
+		t.Error("got followed manga without logging in")
+	}
+	fake.Follow(testUser, testMangaID)
+	login(t, s.Auth)
+	followed, err := s.Feed.Followed(ctx, 10, 0)
+	if err != nil || len(followed.Data) != 1 || followed.Data[0].ID != testMangaID {
+		t.Errorf("Followed = %+v, %v, want the test manga", followed, err)
+	}
+}
+
+func TestChaptersAndReadMarkers(t *testing.T) {
+	s, fake, _ := newTestServices(t)
+	ctx := context.Background()
+	login(t, s.Auth)
+
+	manga, err := s.Library.GetManga(ctx, testMangaID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chapters, err := s.Library.Chapters(ctx, manga)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := chapterIDs(chapters); !reflect.DeepEqual(ids, []string{"c2", "c1"}) {
+		t.Errorf("Chapters = %v, want the English chapters", ids)
+	}
+
+	if err = s.Library.SetReadMarkers(ctx, testMangaID, []string{"c1"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	markers, err := s.Library.ReadMarkers(ctx, testMangaID)
+	if _, ok := markers["c1"]; err != nil || !ok || len(markers) != 1 || !fake.IsRead(testUser, "c1") {
+		t.Errorf("ReadMarkers = %v, %v, want c1 to be read", markers, err)
+	}
+
+	// Chapters that were read are marked as unread, and the rest as read.
+	read, unRead, err := s.Library.ToggleReadMarkers(ctx, testMangaID, []*mangodex.Chapter{&chapters[0], &chapters[1]},
+		markers)
+	if err != nil || len(read) != 1 || read[0].ID != "c2" || len(unRead) != 1 || unRead[0].ID != "c1" {
+		t.Fatalf("ToggleReadMarkers = %v, %v, %v, want c2 read and c1 unread", read, unRead, err)
+	}
+	if !fake.IsRead(testUser, "c2") || fake.IsRead(testUser, "c1") {
+		t.Error("read markers were not toggled")
+	}
+
+	if err = s.Library.SetFollowing(ctx, testMangaID, true); err != nil || !fake.Follows(testUser, testMangaID) {
+		t.Fatalf("SetFollowing = %v, want the manga to be followed", err)
+	}
+	if following, err := s.Library.IsFollowing(ctx, testMangaID); err != nil || !following {
+		t.Errorf("IsFollowing = %t, %v, want true", following, err)
+	}
+}
+
+func TestDownload(t *testing.T) {
+	s, _, conf := newTestServices(t)
+	ctx := context.Background()
+	manga, err := s.Library.GetManga(ctx, testMangaID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chapters, err := s.Library.Chapters(ctx, manga)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chapter := &chapters[0]
+	if s.Downloads.IsDownloaded(manga, chapter) {
+		t.Fatal("chapter is downloaded before it was saved")
+	}
+	if err = s.Downloads.Save(manga, chapter, func(wait time.Duration) {}); err != nil {
+		t.Fatal(err)
+	}
+	folder := s.Downloads.Folder(manga, chapter)
+	if !s.Downloads.IsDownloaded(manga, chapter) || filepath.Dir(filepath.Dir(folder)) != conf.DownloadDir {
+		t.Fatalf("chapter was not saved in the download folder: %s", folder)
+	}
+	for i, page := range []string{"a.png", "b.png"} {
+		data, err := os.ReadFile(filepath.Join(folder, fmt.Sprintf("%04d.png", i+1)))
+		if err != nil || !bytes.Equal(data, fakedex.PageData(chapter.ID, page)) {
+			t.Errorf("page %d = %q, %v, want %q", i+1, data, err, fakedex.PageData(chapter.ID, page))
+		}
+	}
+
+	// Chapters that cannot be saved do not stop the others from being saved, and are returned.
+	missing := &mangodex.Chapter{ID: "missing"}
+	var saved []string
+	failed := s.Downloads.SaveAll(manga, []*mangodex.Chapter{missing, &chapters[1]}, func(wait time.Duration) {},
+		func(chapter *mangodex.Chapter, err error) {
+			if err == nil {
+				saved = append(saved, chapter.ID)
+			}
+		})
+	if len(failed) != 1 || failed[0] != missing || !reflect.DeepEqual(saved, []string{chapters[1].ID}) {
+		t.Errorf("SaveAll failed to save %v and saved %q, want only the missing chapter to fail", failed, saved)
+	}
+}
+
+func TestDownloadFromAtHomeURL(t *testing.T) {
+	s, fake, conf := newTestServices(t)
+	var pages []string
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pages = append(pages, r.URL.Path)
+		_, _ = w.Write(fakedex.PageData(path.Base(path.Dir(r.URL.Path)), path.Base(r.URL.Path)))
+	}))
+	defer mirror.Close()
+	conf.AtHomeURL = mirror.URL + "/mirror/"
+
+	ctx := context.Background()
+	manga, err := s.Library.GetManga(ctx, testMangaID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chapters, err := s.Library.Chapters(ctx, manga)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Downloads.Save(manga, &chapters[0], func(wait time.Duration) {}); err != nil {
+		t.Fatal(err)
+	}
+	// Only the pages are downloaded from the server in the settings, and MangaDex is not told about them.
+	if want := []string{"/mirror/data/c2/a.png", "/mirror/data/c2/b.png"}; !reflect.DeepEqual(pages, want) {
+		t.Errorf("pages downloaded from the MangaDex@Home URL = %q, want %q", pages, want)
+	}
+	for _, req := range fake.Requests() {
+		if strings.Contains(req, "/data/") || strings.Contains(req, "/report") {
+			t.Errorf("%s was sent to MangaDex", req)
+		}
+	}
+}
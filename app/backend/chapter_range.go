@@ -0,0 +1,193 @@
+package backend
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ChapterInfo : The details of a chapter that a ChapterRange is matched against.
+type ChapterInfo struct {
+	Number     string // Empty if the chapter has no number.
+	Volume     string // Empty if the chapter does not belong to a volume.
+	Language   string
+	Group      string
+	Read       bool
+	Downloaded bool
+}
+
+// ChapterRange : A chapter range expression, such as `1-20, 35, vol:3 unread, group:"Some Group"`.
+// Comma-separated parts are alternatives, and a chapter matches the expression if it matches any of them.
+// Each part is made up of space-separated terms, all of which must match. A term may be negated with `!`.
+type ChapterRange struct {
+	parts [][]rangeTerm
+}
+
+// rangeTerm : A single term of a chapter range expression.
+type rangeTerm struct {
+	negate bool
+	match  func(c *ChapterInfo) bool
+}
+
+// ParseChapterRange : Parse a chapter range expression. Returns an error describing the first invalid term.
+func ParseChapterRange(expr string) (*ChapterRange, error) {
+	r := &ChapterRange{}
+	for _, part := range splitOutsideQuotes(expr, func(c rune) bool { return c == ',' }) {
+		var terms []rangeTerm
+		for _, word := range splitOutsideQuotes(part, func(c rune) bool { return c == ' ' || c == '\t' }) {
+			term, err := parseRangeTerm(word)
+			if err != nil {
+				return nil, err
+			}
+			terms = append(terms, term)
+		}
+		if len(terms) != 0 {
+			r.parts = append(r.parts, terms)
+		}
+	}
+	if len(r.parts) == 0 {
+		return nil, fmt.Errorf("empty chapter range")
+	}
+	return r, nil
+}
+
+// Matches : Check whether a chapter matches the expression.
+func (r *ChapterRange) Matches(c *ChapterInfo) bool {
+	for _, terms := range r.parts {
+		matched := true
+		for _, term := range terms {
+			if term.match(c) == term.negate {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRangeTerm : Parse a single term of a chapter range expression.
+func parseRangeTerm(word string) (rangeTerm, error) {
+	term := rangeTerm{}
+	if strings.HasPrefix(word, "!") {
+		term.negate = true
+		word = word[1:]
+	}
+
+	// Terms with a key, such as `vol:3`.
+	if key, value, ok := strings.Cut(word, ":"); ok {
+		value = strings.Trim(value, `"`)
+		switch strings.ToLower(key) {
+		case "vol", "volume":
+			if strings.EqualFold(value, "none") {
+				term.match = func(c *ChapterInfo) bool { return c.Volume == "" }
+				return term, nil
+			}
+			match, err := parseNumberRange(value)
+			if err != nil {
+				return term, fmt.Errorf("invalid volume in %q: %s", word, err.Error())
+			}
+			term.match = func(c *ChapterInfo) bool { return match(c.Volume) }
+		case "lang", "language":
+			term.match = func(c *ChapterInfo) bool { return strings.EqualFold(c.Language, value) }
+		case "group":
+			term.match = func(c *ChapterInfo) bool {
+				return strings.Contains(strings.ToLower(c.Group), strings.ToLower(value))
+			}
+		default:
+			return term, fmt.Errorf("unknown key %q in %q", key, word)
+		}
+		return term, nil
+	}
+
+	switch strings.ToLower(word) {
+	case "all":
+		term.match = func(c *ChapterInfo) bool { return true }
+	case "read":
+		term.match = func(c *ChapterInfo) bool { return c.Read }
+	case "unread":
+		term.match = func(c *ChapterInfo) bool { return !c.Read }
+	case "downloaded":
+		term.match = func(c *ChapterInfo) bool { return c.Downloaded }
+	default:
+		match, err := parseNumberRange(word)
+		if err != nil {
+			return term, fmt.Errorf("invalid chapter %q: %s", word, err.Error())
+		}
+		term.match = func(c *ChapterInfo) bool { return match(c.Number) }
+	}
+	return term, nil
+}
+
+// parseNumberRange : Parse a number such as `40.5`, or an inclusive range such as `1-20`, `-10` or `10-`.
+// Returns a function checking whether a chapter or volume number is in the range.
+// Chapters and volumes without a numeric number are never in the range.
+func parseNumberRange(s string) (func(num string) bool, error) {
+	from, to, isRange := strings.Cut(s, "-")
+	if !isRange {
+		want, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", s)
+		}
+		return func(num string) bool {
+			got, err := strconv.ParseFloat(num, 64)
+			return err == nil && got == want
+		}, nil
+	}
+
+	var (
+		low, high = math.Inf(-1), math.Inf(1)
+		err       error
+	)
+	if from != "" {
+		if low, err = strconv.ParseFloat(from, 64); err != nil {
+			return nil, fmt.Errorf("%q is not a number", from)
+		}
+	}
+	if to != "" {
+		if high, err = strconv.ParseFloat(to, 64); err != nil {
+			return nil, fmt.Errorf("%q is not a number", to)
+		}
+	}
+	if from == "" && to == "" {
+		return nil, fmt.Errorf("range has no start or end")
+	} else if low > high {
+		return nil, fmt.Errorf("range start is after its end")
+	}
+	return func(num string) bool {
+		got, err := strconv.ParseFloat(num, 64)
+		return err == nil && got >= low && got <= high
+	}, nil
+}
+
+// splitOutsideQuotes : Split a string at each separator that is not within double quotes.
+// Empty fields are removed.
+func splitOutsideQuotes(s string, isSep func(c rune) bool) []string {
+	var (
+		fields  []string
+		current strings.Builder
+		quoted  bool
+	)
+	flush := func() {
+		if field := strings.TrimSpace(current.String()); field != "" {
+			fields = append(fields, field)
+		}
+		current.Reset()
+	}
+	for _, c := range s {
+		switch {
+		case c == '"':
+			quoted = !quoted
+			current.WriteRune(c)
+		case !quoted && isSep(c):
+			flush()
+		default:
+			current.WriteRune(c)
+		}
+	}
+	flush()
+	return fields
+}
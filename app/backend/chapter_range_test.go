@@ -0,0 +1,87 @@
+package backend
+
+import (
+	"strings"
+	"testing"
+)
+
+// testChapters : Chapters to match chapter ranges against, by name.
+var testChapters = map[string]*ChapterInfo{
+	"1":       {Number: "1", Volume: "1", Language: "en", Group: "Group One", Read: true},
+	"2":       {Number: "2", Volume: "1", Language: "en", Group: "Group One", Downloaded: true},
+	"2.5":     {Number: "2.5", Volume: "2", Language: "fr", Group: "Les Traducteurs"},
+	"10":      {Number: "10", Volume: "2", Language: "en", Group: "Some Group", Read: true, Downloaded: true},
+	"oneshot": {Language: "en", Group: "Some Group"},
+}
+
+// matching : Get the names of the test chapters that a chapter range matches, in order.
+func matching(r *ChapterRange) string {
+	var names []string
+	for _, name := range []string{"1", "2", "2.5", "10", "oneshot"} {
+		if r.Matches(testChapters[name]) {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+func TestParseChapterRange(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string // The names of the matching test chapters.
+	}{
+		{expr: "1", want: "1"},
+		{expr: "2.5", want: "2.5"},
+		{expr: "1-2", want: "1,2"},
+		{expr: "2-", want: "2,2.5,10"},
+		{expr: "-2", want: "1,2"},
+		{expr: "all", want: "1,2,2.5,10,oneshot"},
+		// Commas are alternatives, and spaces must all match.
+		{expr: "1, 10", want: "1,10"},
+		{expr: "1-10 unread", want: "2,2.5"},
+		{expr: "read, downloaded", want: "1,2,10"},
+		{expr: "all !read", want: "2,2.5,oneshot"},
+		{expr: "!2-", want: "1,oneshot"},
+		// Terms with a key.
+		{expr: "vol:1", want: "1,2"},
+		{expr: "volume:2-", want: "2.5,10"},
+		{expr: "vol:none", want: "oneshot"},
+		{expr: "lang:FR", want: "2.5"},
+		{expr: "language:en !downloaded", want: "1,oneshot"},
+		{expr: "group:one", want: "1,2"},
+		{expr: `group:"some group" read`, want: "10"},
+		{expr: `!group:"group one", 1`, want: "1,2.5,10,oneshot"},
+		{expr: "  1 ,, 2  ", want: "1,2"},
+	}
+	for _, tt := range tests {
+		r, err := ParseChapterRange(tt.expr)
+		if err != nil {
+			t.Errorf("ParseChapterRange(%q) failed: %v", tt.expr, err)
+			continue
+		}
+		if got := matching(r); got != tt.want {
+			t.Errorf("ParseChapterRange(%q) matches %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParseChapterRangeErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string // Part of the error.
+	}{
+		{expr: "", want: "empty chapter range"},
+		{expr: " , ", want: "empty chapter range"},
+		{expr: "one", want: `invalid chapter "one"`},
+		{expr: "1-x", want: `"x" is not a number`},
+		{expr: "-", want: "range has no start or end"},
+		{expr: "10-1", want: "range start is after its end"},
+		{expr: "vol:x", want: `invalid volume in "vol:x"`},
+		{expr: "1, author:someone", want: `unknown key "author"`},
+	}
+	for _, tt := range tests {
+		if _, err := ParseChapterRange(tt.expr); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ParseChapterRange(%q) = %v, want an error with %q", tt.expr, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package backend
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/darylhjd/mangodex"
+)
+
+// IDRegex : Matches MangaDex resource IDs, which are UUIDs.
+var IDRegex = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// ChapterKey : Key identifying duplicate chapters, which share the same chapter number and language.
+func ChapterKey(chapter *mangodex.Chapter) string {
+	return fmt.Sprintf("%s|%s", chapter.GetChapterNum(), chapter.Attributes.TranslatedLanguage)
+}
+
+// ChapterVolume : Get the volume of a chapter, or an empty string if it does not belong to a volume.
+func ChapterVolume(chapter *mangodex.Chapter) string {
+	if vol := chapter.Attributes.Volume; vol != nil {
+		return *vol
+	}
+	return ""
+}
+
+// PublishDate : Get the date that a chapter was published.
+func PublishDate(chapter *mangodex.Chapter) string {
+	if t, err := time.Parse(time.RFC3339, chapter.Attributes.PublishAt); err == nil {
+		return t.Local().Format("2006-01-02")
+	}
+	return chapter.Attributes.PublishAt
+}
+
+// ScanGroup : Get the ID and name of the scanlation group for a chapter, if any.
+func ScanGroup(chapter *mangodex.Chapter) (string, string) {
+	for _, relation := range chapter.Relationships {
+		if relation.Type == mangodex.ScanlationGroupRel {
+			var name string
+			if attr, ok := relation.Attributes.(*mangodex.ScanlationGroupAttributes); ok {
+				name = attr.Name
+			}
+			return relation.ID, name
+		}
+	}
+	return "", ""
+}
+
+// LastUpdate : Get the date that a manga was last updated.
+func LastUpdate(manga *mangodex.Manga) string {
+	if t, err := time.Parse(time.RFC3339, manga.Attributes.UpdatedAt); err == nil {
+		return t.Local().Format("2006-01-02")
+	}
+	return manga.Attributes.UpdatedAt
+}
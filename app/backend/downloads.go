@@ -0,0 +1,296 @@
+package backend
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/darylhjd/mangodex"
+
+	"github.com/darylhjd/mangadesk/app/core"
+)
+
+const (
+	MaxRateLimitWaits = 3                // How many times to wait out a rate limit for a chapter before giving up.
+	RateLimitWait     = 30 * time.Second // How long to wait when rate limited before retrying.
+)
+
+// Downloads : Saving chapters in the download folder.
+type Downloads struct {
+	client *mangodex.DexClient
+	http   *http.Client // For MangaDex@Home servers.
+	config Config
+}
+
+// NewDownloads : Create the download service, which gets chapter pages with httpClient.
+func NewDownloads(client *mangodex.DexClient, httpClient *http.Client, config Config) *Downloads {
+	return &Downloads{client: client, http: httpClient, config: config}
+}
+
+// CheckFolder : Check that chapters can be saved in the download folder, before starting to download them.
+func (d *Downloads) CheckFolder() error {
+	return core.CheckWritable(d.config().DownloadDir)
+}
+
+// Save : Save a chapter of a manga in the download folder, waiting out any rate limits before trying again.
+// onWait is called before each wait.
+func (d *Downloads) Save(manga *mangodex.Manga, chapter *mangodex.Chapter, onWait func(wait time.Duration)) error {
+	err := d.save(manga, chapter)
+	for waits := 0; err != nil && IsRateLimited(err) && waits < MaxRateLimitWaits; waits++ {
+		log.Printf("Rate limited while saving chapter, waiting %s: %s\n", RateLimitWait, err.Error())
+		onWait(RateLimitWait)
+		time.Sleep(RateLimitWait)
+		err = d.save(manga, chapter)
+	}
+	return err
+}
+
+// SaveAll : Save chapters of a manga as with Save, going on to the next chapter when one cannot be saved. onSaved is
+// called once each chapter is saved, with why it could not be if it was not. The chapters that could not be saved
+// are returned, so that they can be tried again.
+func (d *Downloads) SaveAll(manga *mangodex.Manga, chapters []*mangodex.Chapter, onWait func(wait time.Duration),
+	onSaved func(chapter *mangodex.Chapter, err error)) (failed []*mangodex.Chapter) {
+	for _, chapter := range chapters {
+		err := d.Save(manga, chapter, onWait)
+		if err != nil {
+			core.LogErrorf("Error saving %s - Chapter: %s, %s - %s\n",
+				manga.GetTitle("en"), chapter.GetChapterNum(), chapter.GetTitle(), err.Error())
+			failed = append(failed, chapter)
+		}
+		onSaved(chapter, err)
+	}
+	return failed
+}
+
+// IsRateLimited : Check whether an error from the MangaDex API is because too many requests were made.
+func IsRateLimited(err error) bool {
+	return strings.Contains(err.Error(), "(429)") || strings.Contains(err.Error(), "429 status code")
+}
+
+// save : Save a chapter of a manga in the download folder.
+func (d *Downloads) save(manga *mangodex.Manga, chapter *mangodex.Chapter) error {
+	conf := d.config()
+	server, err := d.atHomeServer(chapter.ID, conf.ForcePort443)
+	if err != nil {
+		return err
+	}
+	pages := server.Chapter.Data
+	if conf.DownloadQuality == "data-saver" {
+		pages = server.Chapter.DataSaver
+	}
+	// Only the pages of this chapter are downloaded from the server in the settings, instead of the one given for it.
+	baseURL, report := server.BaseURL, true
+	if conf.AtHomeURL != "" {
+		baseURL, report = strings.TrimSuffix(conf.AtHomeURL, "/"), false
+	}
+
+	// Create directory to store the current chapter.
+	downloadFolder := d.Folder(manga, chapter)
+	if err = os.MkdirAll(downloadFolder, os.ModePerm); err != nil {
+		return err
+	}
+
+	// Save each page.
+	for num, page := range pages {
+		// Get image data.
+		image, err := d.getPage(strings.Join([]string{baseURL, conf.DownloadQuality, server.Chapter.Hash, page}, "/"),
+			report)
+		if err != nil {
+			return err
+		}
+
+		filename := fmt.Sprintf("%04d%s", num+1, filepath.Ext(page))
+		filePath := filepath.Join(downloadFolder, filename)
+		// Save image
+		if err = ioutil.WriteFile(filePath, image, os.ModePerm); err != nil {
+			return err
+		}
+	}
+
+	// If user wants to save the downloads as a zip, then do so.
+	if conf.AsZip {
+		if err = saveAsZipFolder(downloadFolder); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// atHomeServer : Ask the API for the MangaDex@Home server to get the pages of a chapter from, and their names.
+func (d *Downloads) atHomeServer(chapterID string, forcePort443 bool) (*mangodex.MDHomeServerResponse, error) {
+	u := fmt.Sprintf("%s/%s?forcePort443=%t", mangodex.BaseAPI, fmt.Sprintf(mangodex.GetMDHomeURLPath, chapterID),
+		forcePort443)
+	var r mangodex.MDHomeServerResponse
+	if err := d.client.RequestAndDecode(context.Background(), http.MethodGet, u, nil, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// getPage : Get the data of a page from a MangaDex@Home server. If report is true, MangaDex is told how it went in
+// the background, as it asks clients to do for its servers.
+func (d *Downloads) getPage(pageURL string, report bool) ([]byte, error) {
+	start := time.Now()
+	resp, err := d.http.Get(pageURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var data []byte
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("%d status code", resp.StatusCode)
+	} else {
+		data, err = ioutil.ReadAll(resp.Body)
+	}
+	if report {
+		go d.report(pageReport{
+			URL:      pageURL,
+			Success:  err == nil,
+			Bytes:    len(data),
+			Duration: time.Since(start).Milliseconds(),
+			Cached:   strings.HasPrefix(resp.Header.Get("X-Cache"), "HIT"),
+		})
+	}
+	return data, err
+}
+
+// pageReport : How getting a page from a MangaDex@Home server went.
+type pageReport struct {
+	URL      string `json:"url"`
+	Success  bool   `json:"success"`
+	Bytes    int    `json:"bytes"`
+	Duration int64  `json:"duration"` // In milliseconds.
+	Cached   bool   `json:"cached"`
+}
+
+// report : Tell MangaDex how getting a page went. Problems are ignored, as the page was gotten either way.
+func (d *Downloads) report(r pageReport) {
+	body, err := json.Marshal(r)
+	if err != nil {
+		return
+	}
+	resp, err := d.http.Post(mangodex.MDHomeReportURL, "application/json", bytes.NewReader(body))
+	if err == nil {
+		_ = resp.Body.Close()
+	}
+}
+
+// saveAsZipFolder : This function creates a zip folder to store a chapter download.
+func saveAsZipFolder(chapterFolder string) error {
+	// Create a temporary zip folder to store the zip files. This is because the current images
+	// are also stored in their own zip directory as returned from getDownloadFolder.
+	tempZip := fmt.Sprintf("%s.%s", chapterFolder, "temp")
+
+	var (
+		zipFile *os.File
+		err     error
+	)
+
+	// Create necessary writers
+	if zipFile, err = os.Create(tempZip); err != nil {
+		return err
+	}
+	w := zip.NewWriter(zipFile)
+
+	// Saving the actual files.
+	if err = filepath.WalkDir(chapterFolder, func(path string, d fs.DirEntry, err error) error {
+		// Stop walking immediately if encounter error
+		if err != nil {
+			return err
+		}
+		// Skip if a DirEntry is a folder. By right, this shouldn't happen since any downloads will
+		// just contain PNGs or JPEGs, but it's here just in case.
+		if d.IsDir() {
+			return nil
+		}
+
+		// Open the original image file.
+		fileOriginal, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = fileOriginal.Close()
+		}()
+
+		// Create designated file in zip folder for current image.
+		// Use custom header to set modified timing.
+		// Fixes zip parsing issues in certain situations.
+		fh := zip.FileHeader{
+			Name:     d.Name(),
+			Modified: time.Now(),
+			Method:   zip.Deflate, // Consistent with w.Create() source code.
+		}
+		fileZip, err := w.CreateHeader(&fh)
+		if err != nil {
+			return err
+		}
+
+		// Copy the original file into its designated file in the zip archive.
+		_, err = io.Copy(fileZip, fileOriginal)
+		if err != nil {
+			return err
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	// Close the files.
+	if err = w.Close(); err != nil {
+		return err
+	}
+	if err = zipFile.Close(); err != nil {
+		return err
+	}
+
+	// We remove the current unzipped image folder, and rename the temp zip to the real zip.
+	if err = os.RemoveAll(chapterFolder); err != nil {
+		return err
+	}
+	if err = os.Rename(tempZip, chapterFolder); err != nil {
+		return err
+	}
+	return err
+}
+
+// Folder : Get the download folder for a manga's chapter. The chapter is downloaded if this exists.
+func (d *Downloads) Folder(manga *mangodex.Manga, chapter *mangodex.Chapter) string {
+	conf := d.config()
+	mangaName := manga.GetTitle("en")
+	chapterName := fmt.Sprintf("Chapter %s [%s-%s] %s - %s",
+		chapter.GetChapterNum(), chapter.Attributes.TranslatedLanguage, conf.DownloadQuality,
+		chapter.GetTitle(), strings.SplitN(chapter.ID, "-", 2)[0])
+
+	// Remove invalid characters from the folder name
+	restricted := []string{"<", ">", ":", "/", "|", "?", "*", "\"", "\\", "."}
+	for _, c := range restricted {
+		mangaName = strings.ReplaceAll(mangaName, c, "-")
+		chapterName = strings.ReplaceAll(chapterName, c, "-")
+	}
+
+	folder := filepath.Join(conf.DownloadDir, mangaName, chapterName)
+	// If the user wants to download as a zip, then we check for the presence of the zip folder.
+	if conf.AsZip {
+		folder = fmt.Sprintf("%s.%s", folder, conf.ZipType)
+	}
+	return folder
+}
+
+// IsDownloaded : Check whether a manga's chapter has been downloaded.
+func (d *Downloads) IsDownloaded(manga *mangodex.Manga, chapter *mangodex.Chapter) bool {
+	_, err := os.Stat(d.Folder(manga, chapter))
+	return err == nil
+}
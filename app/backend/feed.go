@@ -0,0 +1,63 @@
+package backend
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+
+	"github.com/darylhjd/mangodex"
+)
+
+// Feed : Lists of manga to browse, such as popular manga, search results and the manga that the user follows.
+type Feed struct {
+	client *mangodex.DexClient
+	config Config
+}
+
+// NewFeed : Create the feed service.
+func NewFeed(client *mangodex.DexClient, config Config) *Feed {
+	return &Feed{client: client, config: config}
+}
+
+// Popular : Get a page of the most followed manga. Explicit manga are included if the user allows them.
+func (f *Feed) Popular(ctx context.Context, limit, offset int) (*mangodex.MangaList, error) {
+	params := listParams(limit, offset, f.config().ExplicitContent)
+	// Sort by popular manga (based on follow count).
+	params.Set("order[followedCount]", "desc")
+	return f.client.Manga.GetMangaListContext(ctx, params)
+}
+
+// Search : Get a page of the manga with a title matching a search term, the most relevant first.
+func (f *Feed) Search(ctx context.Context, term string, explicit bool, limit, offset int) (*mangodex.MangaList, error) {
+	params := listParams(limit, offset, explicit)
+	params.Set("title", term)
+	params.Set("order[relevance]", "desc")
+	return f.client.Manga.GetMangaListContext(ctx, params)
+}
+
+// Followed : Get a page of the manga that the user follows. The user must be logged in.
+func (f *Feed) Followed(ctx context.Context, limit, offset int) (*mangodex.MangaList, error) {
+	return f.client.User.GetUserFollowedMangaListContext(ctx, limit, offset, []string{mangodex.AuthorRel})
+}
+
+// listParams : Helper function to set up query parameters for a page of manga, including their authors.
+func listParams(limit, offset int, explicit bool) url.Values {
+	params := url.Values{}
+
+	// Set limits and offset
+	params.Set("limit", strconv.Itoa(limit))
+	params.Set("offset", strconv.Itoa(offset))
+
+	// Set content ratings
+	ratings := []string{mangodex.Safe, mangodex.Suggestive, mangodex.Erotica}
+	if explicit {
+		ratings = append(ratings, mangodex.Porn)
+	}
+	for _, rating := range ratings {
+		params.Add("contentRating[]", rating)
+	}
+
+	// Include Author relationship
+	params.Set("includes[]", mangodex.AuthorRel)
+	return params
+}
@@ -0,0 +1,233 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"github.com/darylhjd/mangodex"
+)
+
+// chapterPageSize : How many chapters are requested at a time.
+const chapterPageSize = 500
+
+// Library : Manga and their chapters, and the user's read markers and follows for them.
+type Library struct {
+	client *mangodex.DexClient
+	config Config
+}
+
+// NewLibrary : Create the library service.
+func NewLibrary(client *mangodex.DexClient, config Config) *Library {
+	return &Library{client: client, config: config}
+}
+
+// GetManga : Get a manga by its ID, including its authors.
+func (l *Library) GetManga(ctx context.Context, id string) (*mangodex.Manga, error) {
+	if !IDRegex.MatchString(id) {
+		return nil, fmt.Errorf("%q is not a manga ID", id)
+	}
+	params := url.Values{}
+	params.Add("ids[]", id)
+	for _, rating := range []string{mangodex.Safe, mangodex.Suggestive, mangodex.Erotica, mangodex.Porn} {
+		params.Add("contentRating[]", rating)
+	}
+	params.Add("includes[]", mangodex.AuthorRel)
+	list, err := l.client.Manga.GetMangaListContext(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	if len(list.Data) == 0 {
+		return nil, fmt.Errorf("there is no manga with ID %s", id)
+	}
+	return &list.Data[0], nil
+}
+
+// Chapters : Get all chapters for the manga in the user's languages, without those from blocked scanlation groups.
+// For chapters with the same number and language, those from preferred groups come first.
+// Stops with the error of the context if it is cancelled.
+func (l *Library) Chapters(ctx context.Context, manga *mangodex.Manga) ([]mangodex.Chapter, error) {
+	var (
+		params     = l.chapterParams(manga)
+		chapters   []mangodex.Chapter
+		currOffset = 0
+	)
+	for {
+		if err := ctx.Err(); err != nil {
+			return []mangodex.Chapter{}, err
+		}
+		params.Set("offset", strconv.Itoa(currOffset))
+		list, err := l.client.Chapter.GetMangaChaptersContext(ctx, manga.ID, *params)
+		if err != nil {
+			return []mangodex.Chapter{}, err
+		}
+		log.Printf("Got %d of %d chapters\n", currOffset, list.Total)
+		chapters = append(chapters, list.Data...)
+		currOffset += chapterPageSize
+		if currOffset >= list.Total {
+			break
+		}
+	}
+	return l.OrderByGroupPreference(chapters), nil
+}
+
+// chapterParams : Helper function to set up query parameters for getting chapters.
+func (l *Library) chapterParams(manga *mangodex.Manga) *url.Values {
+	conf := l.config()
+	// Set up query parameters.
+	params := url.Values{}
+
+	// Set limits
+	params.Set("limit", strconv.Itoa(chapterPageSize))
+
+	// Set All chapters with user's specified languages
+	for _, lang := range conf.Languages {
+		params.Add("translatedLanguage[]", lang)
+	}
+
+	// Show the latest chapters first.
+	params.Set("order[chapter]", "desc")
+
+	// Show required explicit chapters
+	ratings := []string{mangodex.Safe, mangodex.Suggestive, mangodex.Erotica}
+	if manga.Attributes.ContentRating != nil && *manga.Attributes.ContentRating == mangodex.Porn {
+		ratings = append(ratings, mangodex.Porn)
+	}
+	for _, rating := range ratings {
+		params.Add("contentRating[]", rating)
+	}
+
+	// Exclude chapters from groups that the user has blocked.
+	// Only group IDs are accepted by the API. Groups blocked by name are removed after fetching.
+	for _, group := range conf.BlockedGroups {
+		if IDRegex.MatchString(group) {
+			params.Add("excludedGroups[]", group)
+		}
+	}
+
+	// Also get the scanlation group for the chapter
+	params.Add("includes[]", mangodex.ScanlationGroupRel)
+
+	return &params
+}
+
+// OrderByGroupPreference : Remove chapters from blocked scanlation groups, and order the remaining chapters
+// such that, for chapters with the same number and language, those from preferred groups come first.
+// The original order of the chapter numbers is kept.
+func (l *Library) OrderByGroupPreference(chapters []mangodex.Chapter) []mangodex.Chapter {
+	conf := l.config()
+	var (
+		filtered   []mangodex.Chapter
+		firstIndex = map[string]int{} // Keep track of where each chapter number first appears.
+	)
+	for _, chapter := range chapters {
+		if id, name := ScanGroup(&chapter); conf.IsBlockedGroup(id, name) {
+			continue
+		}
+		key := ChapterKey(&chapter)
+		if _, ok := firstIndex[key]; !ok {
+			firstIndex[key] = len(filtered)
+		}
+		filtered = append(filtered, chapter)
+	}
+
+	sort.SliceStable(filtered, func(i, j int) bool {
+		iKey, jKey := ChapterKey(&filtered[i]), ChapterKey(&filtered[j])
+		if iKey != jKey {
+			return firstIndex[iKey] < firstIndex[jKey]
+		}
+		return l.GroupRank(&filtered[i]) < l.GroupRank(&filtered[j])
+	})
+	return filtered
+}
+
+// GroupRank : Get the preference rank of the chapter's scanlation group.
+// Chapters from groups that are not preferred are ranked after all preferred groups.
+func (l *Library) GroupRank(chapter *mangodex.Chapter) int {
+	conf := l.config()
+	rank := conf.PreferredGroupRank(ScanGroup(chapter))
+	if rank == -1 {
+		return len(conf.PreferredGroups)
+	}
+	return rank
+}
+
+// PreferredChapters : Remove duplicate chapters when one of the duplicates is from a preferred scanlation group,
+// keeping only the chapter from the most preferred group. Duplicates where none are from a preferred group are
+// all kept. Returns the indices of the chapters that are kept, in order.
+func (l *Library) PreferredChapters(chapters []*mangodex.Chapter) []int {
+	best := map[string]int{}
+	for _, chapter := range chapters {
+		if chapter.Attributes.Chapter == nil {
+			continue
+		}
+		key, rank := ChapterKey(chapter), l.GroupRank(chapter)
+		if r, ok := best[key]; !ok || rank < r {
+			best[key] = rank
+		}
+	}
+
+	var preferred []int
+	for i, chapter := range chapters {
+		// Skip this chapter if a duplicate from a more preferred group was given.
+		if chapter.Attributes.Chapter != nil && l.GroupRank(chapter) != best[ChapterKey(chapter)] {
+			continue
+		}
+		preferred = append(preferred, i)
+	}
+	return preferred
+}
+
+// ReadMarkers : Get the IDs of the chapters of a manga that the user has read. The user must be logged in.
+func (l *Library) ReadMarkers(ctx context.Context, mangaID string) (map[string]struct{}, error) {
+	markerResponse, err := l.client.Chapter.GetReadMangaChaptersContext(ctx, mangaID)
+	if err != nil {
+		return nil, err
+	}
+	markers := map[string]struct{}{}
+	for _, marker := range markerResponse.Data {
+		markers[marker] = struct{}{}
+	}
+	return markers, nil
+}
+
+// SetReadMarkers : Mark chapters of a manga as read, and others as unread. The user must be logged in.
+func (l *Library) SetReadMarkers(ctx context.Context, mangaID string, read, unRead []string) error {
+	_, err := l.client.Chapter.SetReadUnreadMangaChaptersContext(ctx, mangaID, read, unRead)
+	return err
+}
+
+// ToggleReadMarkers : Mark chapters of a manga as unread if they are in a set of the IDs of read chapters, and the
+// rest as read. The chapters that are now read, and those that are now unread, are returned. The user must be
+// logged in.
+func (l *Library) ToggleReadMarkers(ctx context.Context, mangaID string, chapters []*mangodex.Chapter,
+	readSet map[string]struct{}) (read, unRead []*mangodex.Chapter, err error) {
+	var readIDs, unReadIDs []string
+	for _, chapter := range chapters {
+		if _, ok := readSet[chapter.ID]; ok {
+			unRead = append(unRead, chapter)
+			unReadIDs = append(unReadIDs, chapter.ID)
+		} else {
+			read = append(read, chapter)
+			readIDs = append(readIDs, chapter.ID)
+		}
+	}
+	if err = l.SetReadMarkers(ctx, mangaID, readIDs, unReadIDs); err != nil {
+		return nil, nil, err
+	}
+	return read, unRead, nil
+}
+
+// IsFollowing : Check whether the user follows a manga. The user must be logged in.
+func (l *Library) IsFollowing(ctx context.Context, mangaID string) (bool, error) {
+	return l.client.Manga.CheckIfMangaFollowedContext(ctx, mangaID)
+}
+
+// SetFollowing : Follow or unfollow a manga. The user must be logged in.
+func (l *Library) SetFollowing(ctx context.Context, mangaID string, follow bool) error {
+	_, err := l.client.Manga.ToggleMangaFollowStatusContext(ctx, mangaID, follow)
+	return err
+}
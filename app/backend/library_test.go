@@ -0,0 +1,74 @@
+package backend
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/darylhjd/mangodex"
+
+	"github.com/darylhjd/mangadesk/app/core"
+)
+
+// testChapter : Create a chapter with a number, language and scanlation group.
+func testChapter(id, num, lang, group string) mangodex.Chapter {
+	return mangodex.Chapter{
+		ID: id,
+		Attributes: mangodex.ChapterAttributes{
+			Chapter:            &num,
+			TranslatedLanguage: lang,
+		},
+		Relationships: []mangodex.Relationship{{
+			ID:         group + "-id",
+			Type:       mangodex.ScanlationGroupRel,
+			Attributes: &mangodex.ScanlationGroupAttributes{Name: group},
+		}},
+	}
+}
+
+func chapterIDs(chapters []mangodex.Chapter) []string {
+	var ids []string
+	for _, chapter := range chapters {
+		ids = append(ids, chapter.ID)
+	}
+	return ids
+}
+
+func TestOrderByGroupPreference(t *testing.T) {
+	library := NewLibrary(nil, StaticConfig(&core.UserConfig{
+		PreferredGroups: []string{"Best", "good"},
+		BlockedGroups:   []string{"Blocked"},
+	}))
+	chapters := []mangodex.Chapter{
+		testChapter("2-other", "2", "en", "Other"),
+		testChapter("2-good", "2", "en", "Good"),
+		testChapter("2-best", "2", "en", "Best"),
+		testChapter("2-fr", "2", "fr", "Other"),
+		testChapter("1-blocked", "1", "en", "Blocked"),
+		testChapter("1-other", "1", "en", "Other"),
+	}
+
+	got := chapterIDs(library.OrderByGroupPreference(chapters))
+	want := []string{"2-best", "2-good", "2-other", "2-fr", "1-other"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("OrderByGroupPreference = %v, want %v", got, want)
+	}
+}
+
+func TestPreferredChapters(t *testing.T) {
+	library := NewLibrary(nil, StaticConfig(&core.UserConfig{PreferredGroups: []string{"Best"}}))
+	chapters := []mangodex.Chapter{
+		testChapter("2-other", "2", "en", "Other"),
+		testChapter("2-best", "2", "en", "Best"),
+		testChapter("1-a", "1", "en", "A"),
+		testChapter("1-b", "1", "en", "B"),
+	}
+	pointers := make([]*mangodex.Chapter, len(chapters))
+	for i := range chapters {
+		pointers[i] = &chapters[i]
+	}
+
+	// Duplicates where none are from a preferred group are all kept.
+	if got, want := library.PreferredChapters(pointers), []int{1, 2, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("PreferredChapters = %v, want %v", got, want)
+	}
+}
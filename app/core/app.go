@@ -2,10 +2,8 @@ package core
 
 import (
 	"fmt"
-	"log"
 	"os"
 
-	"github.com/darylhjd/mangodex"
 	"github.com/rivo/tview"
 )
 
@@ -17,17 +15,17 @@ var (
 
 // MangaDesk : The client for this application.
 type MangaDesk struct {
-	Client *mangodex.DexClient
-
 	TView      *tview.Application
 	PageHolder *tview.Pages
 
 	Config  *UserConfig
 	LogFile *os.File
+
+	fileConfig *UserConfig // The configuration as it is in the configuration file, without any options applied.
 }
 
-// Initialise : Initialise the app. Return error if unable to restore previous session.
-func (m *MangaDesk) Initialise() error {
+// Initialise : Initialise the app.
+func (m *MangaDesk) Initialise() {
 	// Set up logging.
 	if err := m.setUpLogging(); err != nil {
 		fmt.Println("Unable to set up logging...")
@@ -35,19 +33,44 @@ func (m *MangaDesk) Initialise() error {
 		os.Exit(1)
 	}
 
-	// Load user configuration.
+	// Load user configuration. If it cannot be read, the defaults are used until the user fixes it,
+	// and the problem is shown to them once the app is running.
 	if err := m.loadConfiguration(); err != nil {
-		log.Println("Unable to read configuration file. Is it formatted correctly?")
-		log.Println("If in doubt, delete the configuration file to start over!\n\nDetails:")
-		log.Println(err.Error())
-		os.Exit(1)
+		LogErrorf("Unable to read configuration file. Is it formatted correctly? %s\n", err.Error())
 	}
 
 	// Set the page holder as the application root and focus on it.
 	m.TView.SetRoot(m.PageHolder, true).SetFocus(m.PageHolder)
+}
 
-	// Try to restore the last session so the user does not need to log in again.
-	return m.restoreSession()
+// SwitchProfile : Switch to another profile, creating it if it does not exist, and load its configuration as when
+// the app starts.
+func (m *MangaDesk) SwitchProfile(name string) error {
+	if err := UseProfile(name); err != nil {
+		return err
+	}
+	if err := m.loadConfiguration(); err != nil {
+		LogErrorf("Unable to read configuration file. Is it formatted correctly? %s\n", err.Error())
+	}
+	return nil
+}
+
+// InitialiseHeadless : Initialise the app for running a command without the terminal interface. Problems with the
+// configuration are returned, as there is nowhere else to show them.
+func (m *MangaDesk) InitialiseHeadless() ([]*ConfigError, error) {
+	if err := m.setUpLogging(); err != nil {
+		return nil, err
+	}
+	conf, problems, err := readConfiguration()
+	m.fileConfig, m.Config = conf, options.apply(conf)
+	return problems, err
+}
+
+// ShutdownHeadless : Stop all services after running a command without the terminal interface.
+func (m *MangaDesk) ShutdownHeadless() {
+	if err := m.stopLogging(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error while closing log file!")
+	}
 }
 
 // Shutdown : Stop all services such as logging and let the application shut down gracefully.
@@ -1,14 +1,23 @@
 package core
 
 import (
-	"encoding/json"
+	"fmt"
 	"io/ioutil"
+	"log"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
-// configFilePath : The filepath to the configuration file.
-var configFilePath = filepath.Join(getConfDir(), "config.json")
+// configFileNames : The names the configuration file can have, in order of precedence. The first one that exists
+// is used. If there is none, config.json is created.
+var configFileNames = []string{"config.toml", "config.yaml", "config.yml", "config.json"}
+
+// Permissions of the configuration folders and files. Only the user can read them.
+const (
+	configDirPerm  os.FileMode = 0700
+	configFilePerm os.FileMode = 0600
+)
 
 // Defaults for user configuration.
 var (
@@ -17,70 +26,285 @@ var (
 	downloadQuality = "data"
 	zipType         = "zip"
 	guestMode       = false
+	defaultAPIURL   = "https://" + apiHost
 )
 
 // UserConfig : This struct contains te user configurable settings.
 type UserConfig struct {
-	DownloadDir     string   `json:"downloadDir"`
-	Languages       []string `json:"languages"`
-	DownloadQuality string   `json:"downloadQuality"`
-	ExplicitContent bool     `json:"explicitContent"`
-	ForcePort443    bool     `json:"forcePort443"`
-	AsZip           bool     `json:"asZip"`
-	ZipType         string   `json:"zipType"`
-	GuestMode       bool     `json:"guestMode"`
+	Version         int                 `json:"version"`
+	DownloadDir     string              `json:"downloadDir"`
+	Languages       []string            `json:"languages"`
+	DownloadQuality string              `json:"downloadQuality"`
+	ExplicitContent bool                `json:"explicitContent"`
+	ForcePort443    bool                `json:"forcePort443"`
+	AsZip           bool                `json:"asZip"`
+	ZipType         string              `json:"zipType"`
+	GuestMode       bool                `json:"guestMode"`
+	PreferredGroups []string            `json:"preferredGroups"`
+	BlockedGroups   []string            `json:"blockedGroups"`
+	Keybindings     map[string][]string `json:"keybindings"`
+	VimMode         bool                `json:"vimMode"`
+	APIURL          string              `json:"apiUrl"`
+	AtHomeURL       string              `json:"atHomeUrl"`
+	AuthURL         string              `json:"authUrl"`
+	RequestTimeout  int                 `json:"requestTimeout"`
+	UserAgent       string              `json:"userAgent"`
+	Proxy           string              `json:"proxy"`
+	CABundle        string              `json:"caBundle"`
+}
+
+// ConfigError : A problem with the user configuration. Problems that are warnings, such as unknown settings, do
+// not stop the configuration from being used.
+type ConfigError struct {
+	Field   string // The name of the setting in the configuration file, such as `languages`.
+	Path    string // The JSON path of the value with the problem, such as `languages[1]`. Defaults to Field.
+	Line    int    // Where the value is in the configuration file, starting from 1. 0 if it is not in the file.
+	Column  int
+	Message string
+	Warning bool
+}
+
+func (e *ConfigError) Error() string {
+	msg := e.Message
+	if path := e.Path; path != "" || e.Field != "" {
+		if path == "" {
+			path = e.Field
+		}
+		msg = fmt.Sprintf("%s: %s", path, msg)
+	}
+	if e.Line != 0 && e.Column != 0 {
+		msg = fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, msg)
+	} else if e.Line != 0 {
+		msg = fmt.Sprintf("line %d: %s", e.Line, msg)
+	}
+	if e.Warning {
+		msg = "warning: " + msg
+	}
+	return msg
 }
 
 // loadConfiguration : Reads any user configuration settings and will create a default one if it does not exist.
+// If the configuration file cannot be read, the defaults are used and the file is left as it is, so that the user
+// can fix it.
 func (m *MangaDesk) loadConfiguration() error {
-	// Make sure the configuration directory exists.
-	if err := os.MkdirAll(getConfDir(), os.ModePerm); err != nil {
+	conf, problems, err := readConfiguration()
+	m.fileConfig, m.Config = conf, options.apply(conf)
+	if err != nil {
 		return err
 	}
+	// Invalid fields are replaced with their defaults, so we only log any problems.
+	for _, problem := range problems {
+		if problem.Warning {
+			log.Printf("Configuration: %s\n", problem.Error())
+		} else {
+			LogErrorf("Configuration: %s\n", problem.Error())
+		}
+	}
+
+	// Do not replace a file from a newer version of the app, as it may have settings that this version does not know.
+	if conf.Version > configVersion {
+		log.Printf("Configuration file is from a newer version (%d), leaving it as it is.\n", conf.Version)
+		return nil
+	}
+	// Keep a copy of a file from an older version, as it is replaced with the migrated configuration.
+	path := ConfigFile()
+	backup, err := backupConfiguration(path)
+	if err != nil {
+		return err
+	} else if backup != "" {
+		log.Printf("Migrated configuration file to version %d. The old file is kept at %s.\n", configVersion, backup)
+	}
+	// TOML and YAML files are written by the user, so they are only changed when they are migrated.
+	if formatOf(path) != jsonFormat && backup == "" {
+		return nil
+	}
+
+	// Save the config file. This also makes sure the configuration directory exists.
+	return m.saveConfiguration()
+}
+
+// ReloadConfiguration : Read the configuration file again and use it, without changing the file. Options given on
+// the command line still take precedence over it.
+// Returns the configuration used before, and any problems with fields of the new configuration, which are set to
+// their defaults. If the file cannot be read, the configuration is not changed and an error is returned.
+func (m *MangaDesk) ReloadConfiguration() (*UserConfig, []*ConfigError, error) {
+	old := m.Config
+	conf, problems, err := readConfiguration()
+	if err != nil {
+		return old, nil, err
+	}
+	m.fileConfig, m.Config = conf, options.apply(conf)
+	return old, problems, nil
+}
 
-	// Set the current configuration to empty one.
-	m.Config = &UserConfig{}
+// readConfiguration : Read the configuration file, using the defaults for any fields that are missing or invalid.
+// If the file does not exist, the defaults are returned. If it cannot be read, the defaults are returned along
+// with the error.
+func readConfiguration() (*UserConfig, []*ConfigError, error) {
+	path := ConfigFile()
+	confBytes, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		confBytes, err = nil, nil
+	}
+	if err != nil {
+		conf := &UserConfig{}
+		conf.sanitiseConfigurations()
+		return conf, nil, err
+	}
+	conf, problems, err := parseConfiguration(formatOf(path), confBytes)
+	if err != nil {
+		return conf, problems, err
+	}
 
-	// Attempt to read user configuration file.
-	if confBytes, err := ioutil.ReadFile(configFilePath); err == nil {
-		// If no error, attempt unmarshal
-		err = json.Unmarshal(confBytes, m.Config)
-		if err != nil { // Return error if cannot unmarshal.
-			return err
+	// Let the user know about other configuration files, as changes to them would not do anything.
+	for _, other := range ConfigFiles() {
+		if _, err := os.Stat(other); other != path && err == nil {
+			problems = append(problems, &ConfigError{
+				Message: fmt.Sprintf("%s is ignored, as %s is used instead", filepath.Base(other), filepath.Base(path)),
+				Warning: true,
+			})
 		}
 	}
-	// Set defaults
-	m.Config.sanitiseConfigurations()
+	return conf, problems, nil
+}
 
-	// Save the config file.
-	return m.saveConfiguration()
+// ConfigCheck : Finds problems with a part of the configuration that is only understood elsewhere, such as the
+// keybindings, which are for the actions of the terminal interface.
+type ConfigCheck func(conf *UserConfig) []*ConfigError
+
+// configChecks : The checks added with AddConfigCheck.
+var configChecks []ConfigCheck
+
+// AddConfigCheck : Run a check whenever the configuration is checked with CheckConfiguration.
+func AddConfigCheck(check ConfigCheck) {
+	configChecks = append(configChecks, check)
+}
+
+// CheckConfiguration : Read the configuration file and report every problem with it, without using it.
+// The configuration as it would be used is also returned. An error is returned if the file cannot be read at all.
+func CheckConfiguration() (*UserConfig, []*ConfigError, error) {
+	conf, problems, err := readConfiguration()
+	if err != nil {
+		return conf, problems, err
+	}
+	// The download folder is kept even if it cannot be written to, as that may be fixed without changing the setting.
+	if err = CheckWritable(conf.DownloadDir); err != nil {
+		problems = append(problems, &ConfigError{Field: "downloadDir",
+			Message: fmt.Sprintf("downloads cannot be saved here: %s", err.Error())})
+	}
+	for _, check := range configChecks {
+		problems = append(problems, check(conf)...)
+	}
+	return conf, problems, nil
+}
+
+// ConfigFile : Get the path to the configuration file that is used, which is the first of the files in
+// ConfigFiles that exists. If none of them do, this is config.json.
+func ConfigFile() string {
+	if options.ConfigFile != "" {
+		return options.ConfigFile
+	}
+	paths := ConfigFiles()
+	for _, path := range paths {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return paths[len(paths)-1]
+}
+
+// ConfigFiles : Get the paths that the configuration file of the profile in use can have, in order of precedence.
+// If a configuration file is given on the command line, this is only that file.
+func ConfigFiles() []string {
+	if options.ConfigFile != "" {
+		return []string{options.ConfigFile}
+	}
+	paths := make([]string, 0, len(configFileNames))
+	for _, name := range configFileNames {
+		paths = append(paths, filepath.Join(ProfileDir(), name))
+	}
+	return paths
 }
 
-// saveConfiguration : Save user configuration.
+// SetConfiguration : Validate a new user configuration, then apply and save it. If there are any problems with
+// the configuration that are not warnings, it is not applied and the problems are returned. An error is returned
+// if it cannot be saved. Settings that options are given for are used, but not saved.
+func (m *MangaDesk) SetConfiguration(conf *UserConfig) ([]*ConfigError, error) {
+	problems := conf.sanitiseConfigurations()
+	for _, problem := range problems {
+		if !problem.Warning {
+			return problems, nil
+		}
+	}
+	m.fileConfig, m.Config = options.unapply(conf, m.fileConfig), conf
+	return nil, m.saveConfiguration()
+}
+
+// saveConfiguration : Save user configuration as it is in the configuration file, without any options given on the
+// command line, in the format of the file. For TOML and YAML files,
+// only the settings that changed are written, so that the comments in the file are kept.
 func (m *MangaDesk) saveConfiguration() error {
-	// Format JSON properly for user.
-	confBytes, err := json.MarshalIndent(m.Config, "", "\t")
+	path := ConfigFile()
+	current, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	confBytes, err := formatOf(path).encode(current, m.fileConfig)
 	if err != nil {
 		return err
 	}
 
 	// Make sure the configuration directory exists. If it already exists, then nothing is done.
-	if err = os.MkdirAll(getConfDir(), os.ModePerm); err != nil {
+	if err = os.MkdirAll(filepath.Dir(path), configDirPerm); err != nil {
 		return err
 	}
-	return ioutil.WriteFile(configFilePath, confBytes, os.ModePerm)
+	return writeConfigFile(path, confBytes)
+}
+
+// writeConfigFile : Write a configuration file that only the user can read, as it can have secrets such as the
+// password of the proxy. Files written by older versions of the app, which others could read, are fixed too.
+func writeConfigFile(path string, data []byte) error {
+	if err := ioutil.WriteFile(path, data, configFilePerm); err != nil {
+		return err
+	}
+	return os.Chmod(path, configFilePerm)
 }
 
 // sanitiseConfigurations : Sanitises the configuration to ensure validated fields.
-func (c *UserConfig) sanitiseConfigurations() {
+// Invalid fields are set to their defaults, and the problems with them are returned.
+func (c *UserConfig) sanitiseConfigurations() []*ConfigError {
+	var problems []*ConfigError
+	problem := func(path, format string, a ...interface{}) {
+		field, _, _ := strings.Cut(path, "[")
+		problems = append(problems, &ConfigError{Field: field, Path: path, Message: fmt.Sprintf(format, a...)})
+	}
+
+	// Version. Configurations that are not from a file, such as from the settings page, are in the current version.
+	if c.Version == 0 {
+		c.Version = configVersion
+	}
+
 	// Download Directory
 	if c.DownloadDir == "" {
-		c.DownloadDir = downloadDir
+		c.DownloadDir = defaultDownloadDir()
 	}
 	// Expand any environment variables in the path.
 	c.DownloadDir = os.ExpandEnv(c.DownloadDir)
 
-	// Languages
+	// Languages. Remove any empty entries, and any that are not ISO 639-1 language codes.
+	var langs []string
+	for i, lang := range c.Languages {
+		if lang = strings.ToLower(strings.TrimSpace(lang)); lang == "" {
+			continue
+		}
+		if !validLanguage(lang) {
+			problem(fmt.Sprintf("languages[%d]", i),
+				"%q is not a language code, such as en or pt-br; it will be ignored", lang)
+			continue
+		}
+		langs = append(langs, lang)
+	}
+	c.Languages = langs
 	if len(c.Languages) == 0 {
 		c.Languages = languages
 	}
@@ -90,6 +314,9 @@ func (c *UserConfig) sanitiseConfigurations() {
 	// Download Quality
 	// Will automatically set to `data` if invalid or no download quality specified.
 	if c.DownloadQuality != "data" && c.DownloadQuality != "data-saver" {
+		if c.DownloadQuality != "" {
+			problem("downloadQuality", "must be data or data-saver, not %q", c.DownloadQuality)
+		}
 		c.DownloadQuality = downloadQuality
 	}
 
@@ -98,12 +325,111 @@ func (c *UserConfig) sanitiseConfigurations() {
 	// Set default zip download type. Can be `zip` or `cbz`.
 	// Any other invalid entries will default to `zip`.
 	if c.ZipType != "zip" && c.ZipType != "cbz" {
+		if c.ZipType != "" {
+			problem("zipType", "must be zip or cbz, not %q", c.ZipType)
+		}
 		c.ZipType = zipType
 	}
+
+	// Preferred and blocked scanlation groups. Remove any empty entries.
+	c.PreferredGroups = trimEntries(c.PreferredGroups)
+	c.BlockedGroups = trimEntries(c.BlockedGroups)
+
+	// Network settings. Invalid URLs are not used, so that requests are not sent somewhere the user did not intend.
+	if c.APIURL = strings.TrimSpace(c.APIURL); c.APIURL == "" {
+		c.APIURL = defaultAPIURL
+	} else if _, err := parseURL(c.APIURL, []string{"http", "https"}); err != nil {
+		problem("apiUrl", "%s; the MangaDex API is used instead", err.Error())
+		c.APIURL = defaultAPIURL
+	}
+	if c.AtHomeURL = strings.TrimSpace(c.AtHomeURL); c.AtHomeURL != "" {
+		if _, err := parseURL(c.AtHomeURL, []string{"http", "https"}); err != nil {
+			problem("atHomeUrl", "%s; the servers given by MangaDex are used instead", err.Error())
+			c.AtHomeURL = ""
+		}
+	}
+	if c.AuthURL = strings.TrimSpace(c.AuthURL); c.AuthURL != "" {
+		if _, err := parseURL(c.AuthURL, []string{"http", "https"}); err != nil {
+			problem("authUrl", "%s; the server of the API URL is used instead", err.Error())
+			c.AuthURL = ""
+		}
+	}
+	if c.RequestTimeout < 0 {
+		problem("requestTimeout", "must be a whole number of seconds, or 0 for no timeout, not %d", c.RequestTimeout)
+		c.RequestTimeout = 0
+	}
+	c.UserAgent = strings.TrimSpace(c.UserAgent)
+	if c.Proxy = strings.TrimSpace(c.Proxy); c.Proxy != "" {
+		if _, err := parseURL(c.Proxy, proxySchemes); err != nil {
+			problem("proxy", "%s; no proxy is used", err.Error())
+			c.Proxy = ""
+		}
+	}
+	if c.CABundle = os.ExpandEnv(strings.TrimSpace(c.CABundle)); c.CABundle != "" {
+		if _, err := loadCABundle(c.CABundle); err != nil {
+			problem("caBundle", "%s; only the system's certificates are used", err.Error())
+			c.CABundle = ""
+		}
+	}
+
+	// Keybindings. Actions not in the map keep their default keys.
+	if c.Keybindings == nil {
+		c.Keybindings = map[string][]string{}
+	}
+	return problems
+}
+
+// PreferredGroupRank : Get the rank of a scanlation group in the user's preferred groups.
+// A lower rank is more preferred. Groups that are not preferred will return -1.
+// Groups may be specified either by their ID or their name (case-insensitive).
+func (c *UserConfig) PreferredGroupRank(id, name string) int {
+	for rank, group := range c.PreferredGroups {
+		if matchesGroup(group, id, name) {
+			return rank
+		}
+	}
+	return -1
 }
 
-// getConfDir : Find the operating system and determine the configuration directory for the application.
+// IsBlockedGroup : Check whether a scanlation group has been blocked by the user.
+// Groups may be specified either by their ID or their name (case-insensitive).
+func (c *UserConfig) IsBlockedGroup(id, name string) bool {
+	for _, group := range c.BlockedGroups {
+		if matchesGroup(group, id, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesGroup : Check whether a configured group entry refers to the group with the given ID or name.
+func matchesGroup(entry, id, name string) bool {
+	return (id != "" && entry == id) || (name != "" && strings.EqualFold(entry, name))
+}
+
+// trimEntries : Trim whitespace from each entry, removing any that are empty.
+func trimEntries(entries []string) []string {
+	var trimmed []string
+	for _, entry := range entries {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			trimmed = append(trimmed, entry)
+		}
+	}
+	return trimmed
+}
+
+// ConfDir : Get the configuration directory of the application, where files that every profile shares, such as
+// the theme, are kept.
+func ConfDir() string {
+	return getConfDir()
+}
+
+// getConfDir : Find the operating system and determine the configuration directory for the application, unless
+// another one is given on the command line.
 func getConfDir() string {
+	if options.ConfigDir != "" {
+		return options.ConfigDir
+	}
 	// Get the default configuration appDir for the OS.
 	configDir, err := os.UserConfigDir()
 	if err != nil { // If there is an error, then we use the home appDir.
@@ -0,0 +1,556 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// configFormat : A format the configuration file can be written in. Settings are converted to JSON when they are
+// read, so that they are validated and migrated the same way whatever the format of the file.
+type configFormat struct {
+	// decode : Convert the contents of a file to JSON, along with where each value is in the file, by its path
+	// such as `languages[1]`. Positions that are not known are left out.
+	decode func(data []byte) ([]byte, map[string]filePosition, error)
+	// encode : Write a configuration, keeping as much of the current contents of the file as possible, such as
+	// comments. The current contents are empty if the file does not exist yet.
+	encode func(current []byte, conf *UserConfig) ([]byte, error)
+}
+
+var (
+	jsonFormat = &configFormat{decode: decodeJSON, encode: encodeJSON}
+	tomlFormat = &configFormat{decode: decodeTOML, encode: encodeTOML}
+	yamlFormat = &configFormat{decode: decodeYAML, encode: encodeYAML}
+)
+
+// formatOf : Get the format of a configuration file from its extension. Files with unknown extensions are JSON.
+func formatOf(path string) *configFormat {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		return tomlFormat
+	case ".yaml", ".yml":
+		return yamlFormat
+	}
+	return jsonFormat
+}
+
+// configValues : The settings of a configuration by their names in the configuration file, along with the names
+// in the order they are declared in UserConfig.
+func configValues(conf *UserConfig) ([]string, map[string]interface{}) {
+	v := reflect.ValueOf(conf).Elem()
+	names := make([]string, 0, v.NumField())
+	values := make(map[string]interface{}, v.NumField())
+	for i := 0; i < v.NumField(); i++ {
+		name := strings.Split(v.Type().Field(i).Tag.Get("json"), ",")[0]
+		names = append(names, name)
+		values[name] = v.Field(i).Interface()
+	}
+	return names, values
+}
+
+// sameValue : Check whether a value read from a configuration file is the same as a setting.
+func sameValue(old, value interface{}) bool {
+	a, errA := json.Marshal(old)
+	b, errB := json.Marshal(value)
+	return errA == nil && errB == nil && bytes.Equal(a, b)
+}
+
+// isNil : Check whether a setting has no value, such as a list that was never set.
+func isNil(value interface{}) bool {
+	v := reflect.ValueOf(value)
+	return !v.IsValid() || ((v.Kind() == reflect.Slice || v.Kind() == reflect.Map) && v.IsNil())
+}
+
+// orEmpty : Replace a list or map with no value with an empty one, for formats that have no null.
+func orEmpty(value interface{}) interface{} {
+	switch value := value.(type) {
+	case []string:
+		if value == nil {
+			return []string{}
+		}
+	case map[string][]string:
+		if value == nil {
+			return map[string][]string{}
+		}
+	}
+	return value
+}
+
+// decodeJSON : JSON files are used as they are. An empty file has no settings.
+func decodeJSON(data []byte) ([]byte, map[string]filePosition, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return []byte("{}"), nil, nil
+	}
+	positions, err := locateValues(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, positions, nil
+}
+
+// encodeJSON : JSON files are written in full, as JSON has no comments to keep.
+func encodeJSON(_ []byte, conf *UserConfig) ([]byte, error) {
+	// Format JSON properly for user.
+	return json.MarshalIndent(conf, "", "\t")
+}
+
+// yamlErrorLine : Matches the line number in errors from parsing YAML, such as `yaml: line 3: did not find ...`.
+var yamlErrorLine = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// yamlError : Describe an error from parsing YAML, with the line it is on. Only the first of several errors
+// from decoding is described.
+func yamlError(err error) error {
+	msg := err.Error()
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) && len(typeErr.Errors) != 0 {
+		msg = typeErr.Errors[0]
+	}
+	problem := &ConfigError{Message: "invalid YAML: " + strings.TrimPrefix(msg, "yaml: ")}
+	if m := yamlErrorLine.FindStringSubmatch(msg); m != nil {
+		problem.Line, _ = strconv.Atoi(m[1])
+		problem.Message = "invalid YAML: " + m[2]
+	}
+	return problem
+}
+
+// decodeYAML : Convert a YAML file to JSON. Positions are taken from the YAML nodes.
+func decodeYAML(data []byte) ([]byte, map[string]filePosition, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, nil, yamlError(err)
+	}
+	positions := map[string]filePosition{}
+	if len(doc.Content) == 0 { // An empty file has no settings.
+		return []byte("{}"), positions, nil
+	}
+
+	var settings interface{}
+	if err := doc.Decode(&settings); err != nil {
+		return nil, nil, yamlError(err)
+	}
+	settingsJSON, err := json.Marshal(settings)
+	if err != nil {
+		// Such as mappings with keys that are not text, which JSON cannot have.
+		return nil, nil, &ConfigError{Message: "the names of settings must be text"}
+	}
+	locateYAML(doc.Content[0], "", positions)
+	return settingsJSON, positions, nil
+}
+
+// locateYAML : Find where each value under a YAML node is. For the members of mappings, this is where their key is.
+func locateYAML(node *yaml.Node, path string, positions map[string]filePosition) {
+	if _, ok := positions[path]; !ok {
+		positions[path] = filePosition{Line: node.Line, Column: node.Column}
+	}
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			member := key.Value
+			if path != "" {
+				member = path + "." + member
+			}
+			positions[member] = filePosition{Line: key.Line, Column: key.Column}
+			locateYAML(node.Content[i+1], member, positions)
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			locateYAML(item, fmt.Sprintf("%s[%d]", path, i), positions)
+		}
+	}
+}
+
+// encodeYAML : Update the settings in a YAML file that have changed, keeping the comments in the file.
+func encodeYAML(current []byte, conf *UserConfig) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(current, &doc); err != nil {
+		return nil, fmt.Errorf("the configuration file has errors, fix them before changing settings: %s", err.Error())
+	}
+	if len(doc.Content) == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, errors.New("the configuration file must be a mapping of settings")
+	}
+
+	names, values := configValues(conf)
+	for _, name := range names {
+		if err := setYAMLValue(root, name, values[name]); err != nil {
+			return nil, err
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// setYAMLValue : Set the value of a key in a YAML mapping, if it has changed. The comments of the old value are
+// kept, and mappings are updated key by key so that the comments of their keys are kept too.
+func setYAMLValue(mapping *yaml.Node, key string, value interface{}) error {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value != key {
+			continue
+		}
+		old := mapping.Content[i+1]
+		var oldValue interface{}
+		if err := old.Decode(&oldValue); err == nil && sameValue(oldValue, value) {
+			return nil
+		}
+
+		if bindings, ok := value.(map[string][]string); ok && old.Kind == yaml.MappingNode {
+			actions := make([]string, 0, len(bindings))
+			for action := range bindings {
+				actions = append(actions, action)
+			}
+			sort.Strings(actions)
+			for _, action := range actions {
+				if err := setYAMLValue(old, action, bindings[action]); err != nil {
+					return err
+				}
+			}
+			// Remove keybindings that are no longer set.
+			kept := old.Content[:0]
+			for j := 0; j+1 < len(old.Content); j += 2 {
+				if _, ok := bindings[old.Content[j].Value]; ok {
+					kept = append(kept, old.Content[j], old.Content[j+1])
+				}
+			}
+			old.Content = kept
+			return nil
+		}
+
+		node := &yaml.Node{}
+		if err := node.Encode(orEmpty(value)); err != nil {
+			return err
+		}
+		if node.Kind == old.Kind {
+			node.Style |= old.Style & yaml.FlowStyle // Keep lists written as [a, b] on one line.
+		}
+		node.HeadComment, node.LineComment, node.FootComment = old.HeadComment, old.LineComment, old.FootComment
+		mapping.Content[i+1] = node
+		return nil
+	}
+
+	// Settings that are not in the file are added, unless they have no value.
+	if isNil(value) {
+		return nil
+	}
+	node := &yaml.Node{}
+	if err := node.Encode(value); err != nil {
+		return err
+	}
+	mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, node)
+	return nil
+}
+
+// decodeTOML : Convert a TOML file to JSON. Positions are found for keys, but not for the items of lists.
+func decodeTOML(data []byte) ([]byte, map[string]filePosition, error) {
+	var settings map[string]interface{}
+	if _, err := toml.Decode(string(data), &settings); err != nil {
+		return nil, nil, tomlError(data, err)
+	}
+	if settings == nil { // An empty file has no settings.
+		settings = map[string]interface{}{}
+	}
+	settingsJSON, err := json.Marshal(settings)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	positions := map[string]filePosition{}
+	lines := tomlLines(data)
+	entries, tables := scanTOML(lines)
+	for table, line := range tables {
+		positions[table] = filePosition{Line: line + 1, Column: indentOf(lines[line]) + 1}
+	}
+	for _, entry := range entries {
+		path := entry.key
+		if entry.table != "" {
+			path = entry.table + "." + entry.key
+		}
+		positions[path] = filePosition{Line: entry.start + 1, Column: indentOf(lines[entry.start]) + 1}
+	}
+	return settingsJSON, positions, nil
+}
+
+// tomlErrorPrefix : Matches the position at the start of errors from parsing TOML, which is reported separately.
+var tomlErrorPrefix = regexp.MustCompile(`^toml: line \d+( \(last key "[^"]*"\))?: `)
+
+// tomlError : Describe an error from parsing TOML, with where it is in the file.
+func tomlError(data []byte, err error) error {
+	var parseErr toml.ParseError
+	if !errors.As(err, &parseErr) {
+		return &ConfigError{Message: "invalid TOML: " + err.Error()}
+	}
+	problem := &ConfigError{Message: "invalid TOML: " + tomlErrorPrefix.ReplaceAllString(parseErr.Error(), "")}
+	problem.Line, problem.Column = lineColumn(data, parseErr.Position.Start)
+	return problem
+}
+
+// tomlEntry : A key and its value in a TOML file, and the lines they are on.
+type tomlEntry struct {
+	table      string // The table the key is in, or "" for keys before the first table.
+	key        string
+	start, end int // The first and last lines of the entry, which are different for lists over several lines.
+}
+
+var (
+	tomlKey   = regexp.MustCompile(`^\s*("(?:[^"\\]|\\.)*"|'[^']*'|[A-Za-z0-9_-]+)\s*=`)
+	tomlTable = regexp.MustCompile(`^\s*\[\s*("(?:[^"\\]|\\.)*"|'[^']*'|[A-Za-z0-9_-]+)\s*\]\s*(#.*)?$`)
+)
+
+// tomlLines : Split a TOML file into lines, without the final newline.
+func tomlLines(data []byte) []string {
+	text := strings.TrimSuffix(string(data), "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+// scanTOML : Find the keys and tables of a TOML file, along with the lines they are on. Only what the
+// configuration uses is understood: keys, lists and tables. Anything else, such as dotted keys, is skipped.
+func scanTOML(lines []string) ([]tomlEntry, map[string]int) {
+	var entries []tomlEntry
+	tables := map[string]int{}
+	table := ""
+	for i := 0; i < len(lines); i++ {
+		if m := tomlTable.FindStringSubmatch(lines[i]); m != nil {
+			table = unquoteTOMLKey(m[1])
+			tables[table] = i
+			continue
+		}
+		if strings.HasPrefix(strings.TrimSpace(lines[i]), "[") {
+			// Arrays of tables and dotted tables are not settings, so neither are their keys.
+			table = "\x00"
+			continue
+		}
+		m := tomlKey.FindStringSubmatchIndex(lines[i])
+		if m == nil {
+			continue
+		}
+
+		// Values can carry on over more lines until their brackets are closed.
+		entry := tomlEntry{table: table, key: unquoteTOMLKey(lines[i][m[2]:m[3]]), start: i}
+		depth, _ := scanTOMLValue(lines[i][m[1]:])
+		for depth > 0 && i+1 < len(lines) {
+			i++
+			more, _ := scanTOMLValue(lines[i])
+			depth += more
+		}
+		entry.end = i
+		entries = append(entries, entry)
+	}
+	return entries, tables
+}
+
+// scanTOMLValue : Get how many brackets are opened and not closed in part of a TOML value, and where a comment
+// after it starts. The comment is -1 if there is none.
+func scanTOMLValue(s string) (int, int) {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return depth, i
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		}
+	}
+	return depth, -1
+}
+
+// unquoteTOMLKey : Get the name of a TOML key, which may be quoted.
+func unquoteTOMLKey(key string) string {
+	switch {
+	case strings.HasPrefix(key, `"`):
+		if unquoted, err := strconv.Unquote(key); err == nil {
+			return unquoted
+		}
+	case strings.HasPrefix(key, "'"):
+		return strings.Trim(key, "'")
+	}
+	return key
+}
+
+// tomlLine : Write a key and its value as a line of TOML.
+func tomlLine(key string, value interface{}) (string, error) {
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(map[string]interface{}{key: orEmpty(value)}); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// indentOf : Get the length of the whitespace at the start of a line.
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
+
+// encodeTOML : Update the settings in a TOML file that have changed, keeping the comments and layout of the file.
+// Settings are written before the first table, and keybindings in the `[keybindings]` table.
+func encodeTOML(current []byte, conf *UserConfig) ([]byte, error) {
+	var existing map[string]interface{}
+	if _, err := toml.Decode(string(current), &existing); err != nil {
+		return nil, fmt.Errorf("the configuration file has errors, fix them before changing settings: %w",
+			tomlError(current, err))
+	}
+	lines := tomlLines(current)
+	entries, tables := scanTOML(lines)
+	find := func(table, key string) *tomlEntry {
+		for i := range entries {
+			if entries[i].table == table && entries[i].key == key {
+				return &entries[i]
+			}
+		}
+		return nil
+	}
+
+	// Changes to make to the lines of the file, by the line they are at. Entries over several lines are replaced
+	// by their first line, and the rest are removed. New lines are inserted before the line they are at.
+	var (
+		replaced = map[int]string{}
+		removed  = map[int]bool{}
+		inserted = map[int][]string{}
+	)
+	remove := func(entry *tomlEntry) {
+		for i := entry.start; i <= entry.end; i++ {
+			removed[i] = true
+		}
+	}
+	set := func(table, key string, old, value interface{}, at int) error {
+		entry := find(table, key)
+		if entry == nil && isNil(value) {
+			return nil
+		}
+		if entry != nil && sameValue(old, value) {
+			return nil
+		}
+		line, err := tomlLine(key, value)
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			inserted[at] = append(inserted[at], line)
+			return nil
+		}
+		first := lines[entry.start]
+		line = first[:indentOf(first)] + line
+		// Keep any comment after a value on one line. Comments inside values over several lines are lost.
+		if _, comment := scanTOMLValue(first); comment != -1 && entry.start == entry.end {
+			line += " " + first[comment:]
+		}
+		remove(entry)
+		replaced[entry.start] = line
+		return nil
+	}
+
+	// Settings go after the last setting before the first table, or before the first table if there are none.
+	end := len(lines)
+	for _, line := range tables {
+		end = min(end, line)
+	}
+	for _, entry := range entries {
+		if entry.table == "" {
+			end = entry.end + 1
+		}
+	}
+	names, values := configValues(conf)
+	for _, name := range names {
+		if name == "keybindings" {
+			continue
+		}
+		if err := set("", name, existing[name], values[name], end); err != nil {
+			return nil, err
+		}
+	}
+
+	// Keybindings written as an inline table are left as they are if they have not changed. Otherwise they are
+	// moved to a [keybindings] table.
+	oldBindings, _ := existing["keybindings"].(map[string]interface{})
+	inline := find("", "keybindings")
+	if inline != nil && sameValue(oldBindings, conf.Keybindings) {
+		return writeTOML(lines, replaced, removed, inserted), nil
+	}
+	if line, ok := tables["keybindings"]; ok && inline == nil {
+		end = line + 1
+		for _, entry := range entries {
+			if entry.table == "keybindings" {
+				end = entry.end + 1
+				if _, ok := conf.Keybindings[entry.key]; !ok {
+					remove(&entry)
+				}
+			}
+		}
+	} else {
+		if inline != nil {
+			remove(inline)
+			oldBindings = nil
+		}
+		end = len(lines)
+		if len(conf.Keybindings) != 0 {
+			inserted[end] = append(inserted[end], "", "[keybindings]")
+		}
+	}
+	actions := make([]string, 0, len(conf.Keybindings))
+	for action := range conf.Keybindings {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+	for _, action := range actions {
+		if err := set("keybindings", action, oldBindings[action], conf.Keybindings[action], end); err != nil {
+			return nil, err
+		}
+	}
+
+	return writeTOML(lines, replaced, removed, inserted), nil
+}
+
+// writeTOML : Write the lines of a TOML file with changes made to them.
+func writeTOML(lines []string, replaced map[int]string, removed map[int]bool, inserted map[int][]string) []byte {
+	var buf strings.Builder
+	for i := 0; i <= len(lines); i++ {
+		for _, line := range inserted[i] {
+			buf.WriteString(line + "\n")
+		}
+		if i == len(lines) {
+			break
+		}
+		if line, ok := replaced[i]; ok {
+			buf.WriteString(line + "\n")
+		} else if !removed[i] {
+			buf.WriteString(lines[i] + "\n")
+		}
+	}
+	return []byte(buf.String())
+}
@@ -0,0 +1,99 @@
+package core
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseConfigurationFormats(t *testing.T) {
+	for _, fixture := range []string{"v1.json", "commented.toml", "commented.yaml"} {
+		t.Run(fixture, func(t *testing.T) {
+			conf, problems, err := parseConfiguration(formatOf(fixture), readFixture(t, fixture))
+			if err != nil {
+				t.Fatalf("parsing: %s", err.Error())
+			}
+			if len(problems) != 0 {
+				t.Errorf("unexpected problems: %v", problems)
+			}
+			if !reflect.DeepEqual(conf.Languages, []string{"en", "pt-br"}) || !conf.AsZip || conf.ZipType != "cbz" {
+				t.Errorf("got languages %q, asZip %t, zipType %q", conf.Languages, conf.AsZip, conf.ZipType)
+			}
+			if len(conf.Keybindings["universal.search"]) != 1 {
+				t.Errorf("conf.Keybindings = %v, want universal.search to be set", conf.Keybindings)
+			}
+		})
+	}
+}
+
+func TestParseConfigurationFormatsLocatesProblems(t *testing.T) {
+	tests := []struct {
+		name, data string
+		line       int
+	}{
+		{name: "config.toml", data: "version = 1\n\nzipType = \"rar\"\n", line: 3},
+		{name: "config.yaml", data: "version: 1\nlanguages:\n  - en\n  - klingon\n", line: 4},
+		{name: "config.toml", data: "version = 1\nlanguages = [\"en\"]\nzipType = \n", line: 3},
+		{name: "config.yaml", data: "version: 1\nzipType: zip\nzipType: cbz\n", line: 3},
+	}
+	for _, tt := range tests {
+		_, problems, err := parseConfiguration(formatOf(tt.name), []byte(tt.data))
+		if err != nil {
+			problems = append(problems, err.(*ConfigError))
+		}
+		if len(problems) != 1 || problems[0].Line != tt.line {
+			t.Errorf("%s %q: got %v, want one problem on line %d", tt.name, tt.data, problems, tt.line)
+		}
+	}
+}
+
+func TestEncodeConfigurationKeepsComments(t *testing.T) {
+	for _, fixture := range []string{"commented.toml", "commented.yaml"} {
+		t.Run(fixture, func(t *testing.T) {
+			format := formatOf(fixture)
+			current := readFixture(t, fixture)
+			conf, _, err := parseConfiguration(format, current)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			conf.Languages = []string{"en", "fr"}
+			conf.Keybindings = map[string][]string{"universal.settings": {"F3"}}
+			data, err := format.encode(current, conf)
+			if err != nil {
+				t.Fatalf("encoding: %s", err.Error())
+			}
+			for _, comment := range []string{"# mangadesk configuration", "# relative to the working directory"} {
+				if !strings.Contains(string(data), comment) {
+					t.Errorf("comment %q was not kept:\n%s", comment, data)
+				}
+			}
+
+			// The file has the changes, and nothing else changes when it is written again.
+			changed, problems, err := parseConfiguration(format, data)
+			if err != nil || len(problems) != 0 {
+				t.Fatalf("parsing the changed file: %v %v\n%s", err, problems, data)
+			}
+			if !reflect.DeepEqual(changed, conf) {
+				t.Errorf("got %+v, want %+v\n%s", changed, conf, data)
+			}
+			if again, err := format.encode(data, changed); err != nil || string(again) != string(data) {
+				t.Errorf("writing the file again changed it:\n%s\nwant:\n%s", again, data)
+			}
+		})
+	}
+}
+
+func TestEncodeConfigurationNewFile(t *testing.T) {
+	for _, name := range []string{"config.json", "config.toml", "config.yaml"} {
+		format := formatOf(name)
+		conf, _, _ := parseConfiguration(format, nil)
+		data, err := format.encode(nil, conf)
+		if err != nil {
+			t.Fatalf("%s: encoding: %s", name, err.Error())
+		}
+		if parsed, _, err := parseConfiguration(format, data); err != nil || !reflect.DeepEqual(parsed, conf) {
+			t.Errorf("%s: got %+v, %v, want %+v\n%s", name, parsed, err, conf, data)
+		}
+	}
+}
@@ -1,65 +1,98 @@
 package core
 
 import (
-	"fmt"
+	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
-	"time"
+	"strings"
 )
 
-// credFilePath : The filepath to the credentials file.
-var credFilePath = filepath.Join(getConfDir(), "credentials")
+// credentialsKey : The key that the credentials are kept under in the secret store.
+const credentialsKey = "credentials"
 
-// StoreCredentials : Store the refresh token.
-func (m *MangaDesk) StoreCredentials() error {
-	if err := ioutil.WriteFile(credFilePath, []byte(m.Client.Auth.GetRefreshToken()), os.ModePerm); err != nil {
-		log.Println(err)
-		return err
-	}
-	return nil
+// Credentials : What is needed to resume the user's session: the personal API client they logged in with, and the
+// refresh token of the session.
+type Credentials struct {
+	ClientID     string `json:"clientId"`
+	ClientSecret string `json:"clientSecret"`
+	RefreshToken string `json:"refreshToken"`
+}
+
+// credFilePath : The filepath to the plaintext credentials file of older versions of the app, which only the
+// default profile can have.
+func credFilePath() string {
+	return credFilePathOf(Profile())
+}
+
+// credFilePathOf : The filepath to the plaintext credentials file of a profile.
+func credFilePathOf(name string) string {
+	return filepath.Join(profileDir(name), "credentials")
+}
+
+// StoredCredentials : Keeps the user's credentials in a secret store, such as the one from Secrets.
+type StoredCredentials struct {
+	Secrets SecretStore
 }
 
-// DeleteCredentials : Delete saved credentials from the system.
-func (m *MangaDesk) DeleteCredentials() {
-	if err := os.Remove(credFilePath); err != nil {
-		log.Println(err)
+// Load : Read the stored credentials. Credentials in the plaintext file of older versions of the app are moved
+// into the secret store first.
+func (c StoredCredentials) Load() (Credentials, error) {
+	if err := c.migrate(); err != nil {
+		LogErrorf("Unable to move the credentials file into the secret store: %s\n", err.Error())
 	}
+	secret, err := c.Secrets.Get(credentialsKey)
+	if err != nil {
+		return Credentials{}, err
+	}
+	var creds Credentials
+	err = json.Unmarshal([]byte(secret), &creds)
+	return creds, err
 }
 
-// loadCredentials : Load saved credentials into the client if there is.
-func (m *MangaDesk) loadCredentials() error {
-	content, err := ioutil.ReadFile(credFilePath)
+// Store : Store the credentials.
+func (c StoredCredentials) Store(creds Credentials) error {
+	content, err := json.Marshal(creds)
 	if err != nil {
 		return err
 	}
-	m.Client.Auth.SetRefreshToken(string(content))
-	return nil
+	return c.Secrets.Set(credentialsKey, string(content))
+}
+
+// Delete : Delete saved credentials from the system.
+func (c StoredCredentials) Delete() error {
+	return c.Secrets.Delete(credentialsKey)
 }
 
-// restoreSession : Check if the user's credentials have been stored before.
-// If they are, then read it, and attempt to refresh the token.
-// Will return error if any steps fail (no stored credentials, authentication failed).
-func (m *MangaDesk) restoreSession() error {
-	// Skip session restore if user is using guest mode.
-	if m.Config.GuestMode {
+// migrate : Move the credentials in the plaintext file of older versions of the app into the secret store, and
+// delete the file. Credentials already in the store are newer, so they are kept.
+func (c StoredCredentials) migrate() error {
+	content, err := ioutil.ReadFile(credFilePath())
+	if os.IsNotExist(err) {
 		return nil
+	} else if err != nil {
+		return err
 	}
 
-	// Try to read stored credential file.
-	if err := m.loadCredentials(); err != nil { // If error, then user was not originally logged in.
-		fmt.Println("No past session, redirecting to login...")
-		time.Sleep(time.Millisecond * 750)
+	if _, err = c.Secrets.Get(credentialsKey); errors.Is(err, ErrNoSecret) {
+		log.Println("Moving the credentials file into the secret store...")
+		if err = c.Store(parseCredentialFile(content)); err != nil {
+			return err
+		}
+	} else if err != nil {
 		return err
 	}
+	return os.Remove(credFilePath())
+}
 
-	fmt.Println("Attempting session restore...")
-	// Do a refresh of the token to keep it up to date. If the token has already expired, user needs to log in again.
-	if err := m.Client.Auth.RefreshSessionToken(); err != nil {
-		fmt.Println("Session expired. Please login again.")
-		time.Sleep(time.Millisecond * 750)
-		return err
+// parseCredentialFile : Read the content of a plaintext credentials file. Files from before personal API clients
+// only have a refresh token, so they are given without a client.
+func parseCredentialFile(content []byte) Credentials {
+	var creds Credentials
+	if err := json.Unmarshal(content, &creds); err != nil {
+		return Credentials{RefreshToken: strings.TrimSpace(string(content))}
 	}
-	return nil
+	return creds
 }
@@ -2,6 +2,7 @@ package core
 
 import (
 	"fmt"
+	"io"
 	"io/fs"
 	"log"
 	"os"
@@ -15,18 +16,34 @@ const (
 )
 
 // loggingDir : The logging directory to store the logs.
-var loggingDir = filepath.Join(getConfDir(), "logs")
+func loggingDir() string {
+	return filepath.Join(getConfDir(), "logs")
+}
+
+// errorLog : Logs messages about errors, which are logged at the error log level as well as the info level.
+// Other messages are logged with the log package, and only at the info level.
+var errorLog = log.New(io.Discard, "ERROR ", log.Lshortfile|log.LstdFlags|log.Lmsgprefix)
+
+// LogErrorf : Log a message about an error, formatted as with fmt.Printf.
+func LogErrorf(format string, a ...interface{}) {
+	_ = errorLog.Output(2, fmt.Sprintf(format, a...))
+}
 
 // setUpLogging : Set up the logger to log any useful information such as errors when running the application.
-// The log file is stored in the configuration directory.
+// The log file is stored in the configuration directory. Nothing is logged if logging is turned off.
 func (m *MangaDesk) setUpLogging() error {
-	if err := os.MkdirAll(loggingDir, os.ModePerm); err != nil {
+	if options.LogLevel == "off" {
+		log.SetOutput(io.Discard)
+		errorLog.SetOutput(io.Discard)
+		return nil
+	}
+	if err := os.MkdirAll(loggingDir(), configDirPerm); err != nil {
 		return err
 	}
 
 	// Remove old logging files (at least one-month-old)
 	now := time.Now()
-	_ = filepath.Walk(loggingDir, func(path string, info fs.FileInfo, err error) error {
+	_ = filepath.Walk(loggingDir(), func(path string, info fs.FileInfo, err error) error {
 		// Remove files that were modified more than 1 month ago.
 		fileDate := info.ModTime()
 		// Ignore folders, even though there should not be any in this folder.
@@ -38,13 +55,18 @@ func (m *MangaDesk) setUpLogging() error {
 
 	// Create file for current session logging
 	formattedDate := now.Format(dateFormat)
-	logFilePath := filepath.Join(loggingDir, fmt.Sprintf("%s.log", formattedDate))
+	logFilePath := filepath.Join(loggingDir(), fmt.Sprintf("%s.log", formattedDate))
 
 	var err error
-	if m.LogFile, err = os.OpenFile(logFilePath, os.O_CREATE|os.O_RDWR|os.O_APPEND, os.ModePerm); err != nil {
+	if m.LogFile, err = os.OpenFile(logFilePath, os.O_CREATE|os.O_RDWR|os.O_APPEND, configFilePerm); err != nil {
 		return err
 	}
-	log.SetOutput(m.LogFile)
+	errorLog.SetOutput(m.LogFile)
+	if options.LogLevel == "error" {
+		log.SetOutput(io.Discard)
+	} else {
+		log.SetOutput(m.LogFile)
+	}
 	log.SetFlags(log.Lshortfile | log.LstdFlags)
 	log.Printf("Session started at %s\n", formattedDate)
 
@@ -53,5 +75,8 @@ func (m *MangaDesk) setUpLogging() error {
 
 // stopLogging : Closes the log file.
 func (m *MangaDesk) stopLogging() error {
+	if m.LogFile == nil {
+		return nil
+	}
 	return m.LogFile.Close()
 }
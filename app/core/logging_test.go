@@ -0,0 +1,58 @@
+package core
+
+import (
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLogLevels(t *testing.T) {
+	tests := []struct {
+		level string
+		want  []string // The messages that are logged.
+	}{
+		{level: "info", want: []string{"Session started", "opening page", "ERROR saving chapter"}},
+		{level: "error", want: []string{"ERROR saving chapter"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.level, func(t *testing.T) {
+			SetOptions(&Options{ConfigDir: t.TempDir(), LogLevel: tt.level})
+			defer SetOptions(&Options{})
+			m := &MangaDesk{}
+			if err := m.setUpLogging(); err != nil {
+				t.Fatal(err)
+			}
+			defer log.SetOutput(os.Stderr)
+			defer errorLog.SetOutput(io.Discard)
+			log.Println("opening page")
+			LogErrorf("saving chapter: %s\n", "rate limited")
+			if err := m.stopLogging(); err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := ioutil.ReadFile(m.LogFile.Name())
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+			if len(lines) != len(tt.want) {
+				t.Fatalf("logged %q, want %d lines", lines, len(tt.want))
+			}
+			for i, line := range lines {
+				if !strings.Contains(line, tt.want[i]) {
+					t.Errorf("logged %q, want a line with %q", line, tt.want[i])
+				}
+			}
+			info, err := os.Stat(m.LogFile.Name())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if perm := info.Mode().Perm(); perm&0077 != 0 {
+				t.Errorf("log file has permissions %v, want only the user to be able to read it", perm)
+			}
+		})
+	}
+}
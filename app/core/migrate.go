@@ -0,0 +1,97 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+)
+
+// configVersion : The version of the configuration file format. Increase it and add a migration to
+// configMigrations whenever the format changes in a way that files from older versions need to be changed for.
+const configVersion = 1
+
+// configMigrations : Functions that change the settings of a configuration file from one version to the next,
+// by the version they change from.
+var configMigrations = []func(settings map[string]json.RawMessage){
+	0: migrateFromV0,
+}
+
+// migrateFromV0 : Files from before the version field was added. The documentation used to name the languages
+// setting `langauges`, and describe it as comma-separated, so such settings are changed to a list of languages.
+func migrateFromV0(settings map[string]json.RawMessage) {
+	if typo, ok := settings["langauges"]; ok {
+		if _, ok = settings["languages"]; !ok {
+			settings["languages"] = typo
+		}
+		delete(settings, "langauges")
+	}
+
+	var langs string
+	if raw, ok := settings["languages"]; ok && json.Unmarshal(raw, &langs) == nil {
+		list, _ := json.Marshal(strings.Split(langs, ","))
+		settings["languages"] = list
+	}
+}
+
+// migrateConfiguration : Change the contents of a configuration file from an older version to the current
+// version. Returns the new contents, and the version the file was written in. Files that are not valid JSON
+// objects, and files that are already in the current version or newer, are returned as they are.
+func migrateConfiguration(data []byte) ([]byte, int, error) {
+	var settings map[string]json.RawMessage
+	if err := json.Unmarshal(data, &settings); err != nil || settings == nil {
+		return data, configVersion, nil
+	}
+
+	version := 0
+	if raw, ok := settings["version"]; ok {
+		if err := json.Unmarshal(raw, &version); err != nil || version < 0 {
+			return data, configVersion, &ConfigError{
+				Field:   "version",
+				Message: fmt.Sprintf("must be a whole number, not %s", raw),
+			}
+		}
+	}
+	if version >= configVersion {
+		return data, version, nil
+	}
+
+	for v := version; v < configVersion; v++ {
+		configMigrations[v](settings)
+	}
+	settings["version"], _ = json.Marshal(configVersion)
+	migrated, err := json.MarshalIndent(settings, "", "\t")
+	if err != nil {
+		return data, version, err
+	}
+	return migrated, version, nil
+}
+
+// backupConfiguration : Keep a copy of a configuration file from an older version before it is migrated, so that
+// it can be restored if needed. Returns the path of the copy. Nothing is done if the file is in the current version.
+func backupConfiguration(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	settings, _, err := formatOf(path).decode(data)
+	if err != nil {
+		return "", nil
+	}
+	_, version, err := migrateConfiguration(settings)
+	if err != nil || version >= configVersion {
+		return "", nil
+	}
+
+	backup := fmt.Sprintf("%s.v%d.bak", path, version)
+	if _, err = os.Stat(backup); err == nil {
+		// Do not replace an earlier backup, which may have settings that this file does not.
+		backup = fmt.Sprintf("%s.v%d.%s.bak", path, version, time.Now().Format("20060102-150405"))
+	}
+	return backup, writeConfigFile(backup, data)
+}
@@ -0,0 +1,125 @@
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+// readFixture : Read a configuration file from the testdata folder.
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "config", name))
+	if err != nil {
+		t.Fatalf("reading fixture %s: %s", name, err.Error())
+	}
+	return data
+}
+
+func TestMigrateConfiguration(t *testing.T) {
+	tests := []struct {
+		fixture   string
+		version   int      // The version the file was written in.
+		languages []string // The languages after migrating and parsing the file.
+		asZip     bool
+		zipType   string
+		warnings  int
+	}{
+		{fixture: "v0.json", version: 0, languages: []string{"en", "pt-br"}, asZip: true, zipType: "cbz"},
+		{fixture: "v0_langauges.json", version: 0, languages: []string{"en", "pt-br"}, zipType: "zip"},
+		{fixture: "v1.json", version: 1, languages: []string{"en", "pt-br"}, asZip: true, zipType: "cbz"},
+		{fixture: "newer.json", version: 99, languages: []string{"en"}, zipType: "zip", warnings: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			data := readFixture(t, tt.fixture)
+			migrated, version, err := migrateConfiguration(data)
+			if err != nil {
+				t.Fatalf("migrating: %s", err.Error())
+			}
+			if version != tt.version {
+				t.Errorf("version = %d, want %d", version, tt.version)
+			}
+
+			// Migrating again does nothing, as the file is then in the current version.
+			if again, _, _ := migrateConfiguration(migrated); tt.version < configVersion && string(again) != string(migrated) {
+				t.Errorf("migrating twice changed the file:\n%s\nwant:\n%s", again, migrated)
+			}
+
+			conf, problems, err := parseConfiguration(jsonFormat, data)
+			if err != nil {
+				t.Fatalf("parsing: %s", err.Error())
+			}
+			warnings := 0
+			for _, problem := range problems {
+				if !problem.Warning {
+					t.Errorf("unexpected problem: %s", problem.Error())
+				}
+				warnings++
+			}
+			if warnings != tt.warnings {
+				t.Errorf("got %d warnings, want %d: %v", warnings, tt.warnings, problems)
+			}
+			if want := max(tt.version, configVersion); conf.Version != want {
+				t.Errorf("conf.Version = %d, want %d", conf.Version, want)
+			}
+			if !reflect.DeepEqual(conf.Languages, tt.languages) {
+				t.Errorf("conf.Languages = %q, want %q", conf.Languages, tt.languages)
+			}
+			if conf.AsZip != tt.asZip || conf.ZipType != tt.zipType {
+				t.Errorf("conf.AsZip, conf.ZipType = %t, %q, want %t, %q",
+					conf.AsZip, conf.ZipType, tt.asZip, tt.zipType)
+			}
+		})
+	}
+}
+
+func TestMigrateConfigurationInvalidVersion(t *testing.T) {
+	data := []byte("{\n\t\"version\": \"one\"\n}")
+	_, problems, err := parseConfiguration(jsonFormat, data)
+	if err == nil {
+		t.Fatalf("expected an error, got problems %v", problems)
+	}
+	problem, ok := err.(*ConfigError)
+	if !ok || problem.Field != "version" || problem.Line != 2 {
+		t.Errorf("got error %v, want a problem with version on line 2", err)
+	}
+}
+
+func TestBackupConfiguration(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+
+	// Files in the current version are not backed up.
+	if err := ioutil.WriteFile(path, readFixture(t, "v1.json"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if backup, err := backupConfiguration(path); err != nil || backup != "" {
+		t.Errorf("backing up current version: got %q, %v, want no backup", backup, err)
+	}
+
+	// Files from older versions are copied as they are, without replacing earlier backups.
+	old := readFixture(t, "v0.json")
+	if err := ioutil.WriteFile(path, old, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	first, err := backupConfiguration(path)
+	if err != nil || first != path+".v0.bak" {
+		t.Fatalf("got %q, %v, want %q", first, err, path+".v0.bak")
+	}
+	second, err := backupConfiguration(path)
+	if err != nil || second == "" || second == first {
+		t.Fatalf("got %q, %v, want a second backup", second, err)
+	}
+	for _, backup := range []string{first, second} {
+		if data, err := ioutil.ReadFile(backup); err != nil || string(data) != string(old) {
+			t.Errorf("backup %s does not match the original file: %v", backup, err)
+		}
+		if info, err := os.Stat(backup); err == nil && runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+			t.Errorf("backup %s permissions = %o, want 600", backup, info.Mode().Perm())
+		}
+	}
+}
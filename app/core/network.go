@@ -0,0 +1,262 @@
+package core
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The hosts of the MangaDex API, which clients such as mangodex.DexClient always send requests to, and of the
+// authentication server that users log in with.
+const (
+	apiHost  = "api.mangadex.org"
+	authHost = "auth.mangadex.org"
+)
+
+// proxySchemes : The kinds of proxy that can be used.
+var proxySchemes = []string{"http", "https", "socks5", "socks5h"}
+
+var (
+	// network : Sends requests with the network settings. Until they are applied, requests are sent with the
+	// default transport.
+	network = &networkTransport{}
+	// httpClient : The client that sends requests with the network settings, given to everything that can be given
+	// a client of its own.
+	httpClient = &http.Client{Transport: network}
+	// dexTransport : The default transport once the network settings are applied, as mangodex.DexClient cannot be
+	// given a client or transport, and always uses the default one. Only requests for the MangaDex API, which it
+	// sends, are sent with the network settings. Other requests are sent as they were before.
+	dexTransport http.RoundTripper = apiTransport{}
+	networkMutex sync.Mutex
+)
+
+// HTTPClient : Get the client that sends requests with the network settings.
+func HTTPClient() *http.Client {
+	return httpClient
+}
+
+// ApplyNetworkSettings : Make requests use the network settings of a configuration, such as the proxy and the API
+// URL. These are requests sent with HTTPClient, and requests for the MangaDex API sent with the default transport.
+// Nothing is done in offline mode, as no requests are sent at all. If the settings cannot be used, the current ones
+// are kept and an error is returned.
+func ApplyNetworkSettings(conf *UserConfig) error {
+	if Offline() {
+		return nil
+	}
+	networkMutex.Lock()
+	defer networkMutex.Unlock()
+
+	base := http.DefaultTransport
+	if base == dexTransport {
+		base = network.base
+	}
+	transport, err := NewTransport(base, conf)
+	if err != nil {
+		return err
+	}
+	network.set(transport, base)
+	http.DefaultTransport = dexTransport
+	return nil
+}
+
+// Authoriser : Sends a request with whatever authorises it, such as the user's access token, using next to send it.
+type Authoriser func(req *http.Request, next http.RoundTripper) (*http.Response, error)
+
+// AuthoriseRequests : Send every request with the network settings through an authoriser, such as to add the
+// user's access token to requests for the API. It is kept when the network settings change.
+func AuthoriseRequests(authorise Authoriser) {
+	network.mu.Lock()
+	defer network.mu.Unlock()
+	network.authorise = authorise
+}
+
+// NewTransport : Create a transport that sends requests with the network settings of a configuration, using
+// base for anything the settings do not change. A proxy or CA bundle can only be used if base is an
+// *http.Transport, such as the default one.
+func NewTransport(base http.RoundTripper, conf *UserConfig) (http.RoundTripper, error) {
+	if conf.Proxy != "" || conf.CABundle != "" {
+		std, ok := base.(*http.Transport)
+		if !ok {
+			return nil, errors.New("a proxy or CA bundle cannot be used with a custom transport")
+		}
+		std = std.Clone()
+		if conf.Proxy != "" {
+			proxy, err := parseURL(conf.Proxy, proxySchemes)
+			if err != nil {
+				return nil, fmt.Errorf("invalid proxy: %w", err)
+			}
+			std.Proxy = http.ProxyURL(proxy)
+		}
+		if conf.CABundle != "" {
+			pool, err := loadCABundle(conf.CABundle)
+			if err != nil {
+				return nil, err
+			}
+			if std.TLSClientConfig == nil {
+				std.TLSClientConfig = &tls.Config{}
+			}
+			std.TLSClientConfig.RootCAs = pool
+		}
+		base = std
+	}
+
+	t := &settingsTransport{
+		base:      base,
+		userAgent: conf.UserAgent,
+		timeout:   time.Duration(conf.RequestTimeout) * time.Second,
+	}
+	var err error
+	if conf.APIURL != "" && conf.APIURL != defaultAPIURL {
+		if t.apiURL, err = parseURL(conf.APIURL, []string{"http", "https"}); err != nil {
+			return nil, fmt.Errorf("invalid API URL: %w", err)
+		}
+	}
+	// Without a URL of its own, logging in goes to the same server as the API, so that the user's password and
+	// client secret are not sent to MangaDex while the app is pointed at a mirror or a mock.
+	if conf.AuthURL != "" {
+		if t.authURL, err = parseURL(conf.AuthURL, []string{"http", "https"}); err != nil {
+			return nil, fmt.Errorf("invalid authentication URL: %w", err)
+		}
+	} else {
+		t.authURL = t.apiURL
+	}
+	return t, nil
+}
+
+// networkTransport : Sends requests with a transport that can be replaced while the app is running.
+type networkTransport struct {
+	mu        sync.RWMutex
+	transport http.RoundTripper // Nil to use the default transport.
+	base      http.RoundTripper // The default transport from before the network settings were applied.
+	authorise Authoriser        // Nil to send requests as they are.
+}
+
+func (t *networkTransport) set(transport, base http.RoundTripper) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.transport, t.base = transport, base
+}
+
+func (t *networkTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.RLock()
+	transport, authorise := t.transport, t.authorise
+	t.mu.RUnlock()
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	if authorise != nil {
+		return authorise(req, transport)
+	}
+	return transport.RoundTrip(req)
+}
+
+// apiTransport : Sends requests for the MangaDex API with the network settings, and others as they were before they
+// were applied.
+type apiTransport struct{}
+
+func (apiTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if strings.EqualFold(req.URL.Hostname(), apiHost) {
+		return network.RoundTrip(req)
+	}
+	network.mu.RLock()
+	base := network.base
+	network.mu.RUnlock()
+	return base.RoundTrip(req)
+}
+
+// settingsTransport : Sends requests with the network settings applied.
+type settingsTransport struct {
+	base      http.RoundTripper
+	apiURL    *url.URL // Nil to use the MangaDex API.
+	authURL   *url.URL // Nil to use the MangaDex authentication server.
+	userAgent string
+	timeout   time.Duration // 0 for no timeout.
+}
+
+func (t *settingsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// The request is copied, as it must not be changed, and clients may share its headers between requests.
+	req = req.Clone(req.Context())
+	if t.apiURL != nil && strings.EqualFold(req.URL.Hostname(), apiHost) {
+		redirect(req, t.apiURL)
+	} else if t.authURL != nil && strings.EqualFold(req.URL.Hostname(), authHost) {
+		redirect(req, t.authURL)
+	}
+	if t.userAgent != "" {
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+	if t.timeout == 0 {
+		return t.base.RoundTrip(req)
+	}
+
+	// The timeout also covers reading the response, so it only ends once the body is closed.
+	ctx, cancel := context.WithTimeout(req.Context(), t.timeout)
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("request timed out after %s: %w", t.timeout, err)
+		}
+		return nil, err
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelBody : A response body that ends the timeout of its request when it is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// redirect : Send a request to another server, with the path of the server's URL before the request's path.
+func redirect(req *http.Request, to *url.URL) {
+	req.URL.Scheme, req.URL.Host = to.Scheme, to.Host
+	req.URL.Path = strings.TrimSuffix(to.Path, "/") + req.URL.Path
+	req.URL.RawPath = ""
+	req.Host = to.Host
+}
+
+// parseURL : Parse a URL, which must have a host and one of the schemes.
+func parseURL(rawURL string, schemes []string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if !contains(schemes, strings.ToLower(u.Scheme)) {
+		return nil, fmt.Errorf("must start with %s://", strings.Join(schemes, ":// or "))
+	}
+	if u.Host == "" {
+		return nil, errors.New("has no host")
+	}
+	return u, nil
+}
+
+// loadCABundle : Read a file of PEM certificates, and get the system's certificates with them added.
+func loadCABundle(path string) (*x509.CertPool, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read CA bundle: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+	return pool, nil
+}
@@ -0,0 +1,188 @@
+package core
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// get : Send a GET request with a transport, and get the body of the response.
+func get(t *testing.T, transport http.RoundTripper, url string) (string, error) {
+	t.Helper()
+	resp, err := (&http.Client{Transport: transport}).Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	return string(body), err
+}
+
+func TestTransportRedirects(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s", r.URL.Path, r.UserAgent())
+	}))
+	defer server.Close()
+
+	transport, err := NewTransport(http.DefaultTransport, &UserConfig{
+		APIURL:    server.URL + "/mirror/",
+		UserAgent: "mangadesk-test",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		url, want string
+	}{
+		{url: "https://api.mangadex.org/manga/1", want: "/mirror/manga/1 mangadesk-test"},
+		{url: server.URL + "/other", want: "/other mangadesk-test"}, // Other requests are left as they are.
+		// Without a URL of its own, logging in goes to the server of the API.
+		{url: "https://auth.mangadex.org/realms/token", want: "/mirror/realms/token mangadesk-test"},
+	}
+	for _, tt := range tests {
+		if got, err := get(t, transport, tt.url); err != nil || got != tt.want {
+			t.Errorf("GET %s = %q, %v, want %q", tt.url, got, err, tt.want)
+		}
+	}
+
+	transport, err = NewTransport(http.DefaultTransport, &UserConfig{AuthURL: server.URL + "/login"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "/login/realms/token "
+	got, err := get(t, transport, "https://auth.mangadex.org/realms/token")
+	if err != nil || !strings.HasPrefix(got, want) {
+		t.Errorf("GET with an authentication URL = %q, %v, want %q", got, err, want)
+	}
+}
+
+func TestTransportTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			select {
+			case <-time.After(5 * time.Second):
+			case <-r.Context().Done():
+			}
+		}
+	}))
+	defer server.Close()
+
+	transport, err := NewTransport(http.DefaultTransport, &UserConfig{RequestTimeout: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = get(t, transport, server.URL+"/fast"); err != nil {
+		t.Errorf("fast request failed: %v", err)
+	}
+	if _, err = get(t, transport, server.URL+"/slow"); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("slow request = %v, want it to time out", err)
+	}
+}
+
+func TestTransportProxy(t *testing.T) {
+	// A request for a plain HTTP URL is sent to an HTTP proxy with the whole URL.
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "proxied %s", r.URL.String())
+	}))
+	defer proxy.Close()
+
+	transport, err := NewTransport(http.DefaultTransport, &UserConfig{
+		APIURL: "http://mirror.example",
+		Proxy:  proxy.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "proxied http://mirror.example/manga"
+	if got, err := get(t, transport, "https://api.mangadex.org/manga"); err != nil || got != want {
+		t.Errorf("GET = %q, %v, want %q", got, err, want)
+	}
+
+	// Proxies need a transport that they can be set on.
+	if _, err = NewTransport(roundTripFunc(nil), &UserConfig{Proxy: proxy.URL}); err == nil {
+		t.Error("proxy was used with a custom transport")
+	}
+}
+
+func TestApplyNetworkSettings(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s", r.URL.Path, r.UserAgent())
+	}))
+	defer server.Close()
+	original := http.DefaultTransport
+	defer func() {
+		http.DefaultTransport = original
+		network.set(nil, nil)
+	}()
+
+	if err := ApplyNetworkSettings(&UserConfig{APIURL: server.URL, UserAgent: "mangadesk-test"}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		transport http.RoundTripper
+		url, want string
+	}{
+		{name: "client", transport: HTTPClient().Transport, url: "https://api.mangadex.org/manga",
+			want: "/manga mangadesk-test"},
+		{name: "client", transport: HTTPClient().Transport, url: server.URL + "/other", want: "/other mangadesk-test"},
+		// The default transport only sends requests for the API with the settings, as the client for MangaDex does.
+		{name: "default transport", transport: http.DefaultTransport, url: "https://api.mangadex.org/manga",
+			want: "/manga mangadesk-test"},
+		{name: "default transport", transport: http.DefaultTransport, url: server.URL + "/other",
+			want: "/other Go-http-client/1.1"},
+	}
+	for _, tt := range tests {
+		if got, err := get(t, tt.transport, tt.url); err != nil || got != tt.want {
+			t.Errorf("GET %s with the %s = %q, %v, want %q", tt.url, tt.name, got, err, tt.want)
+		}
+	}
+
+	// New settings replace the old ones, instead of being applied on top of them.
+	if err := ApplyNetworkSettings(&UserConfig{APIURL: server.URL + "/v2"}); err != nil {
+		t.Fatal(err)
+	}
+	want := "/v2/manga Go-http-client/1.1"
+	if got, err := get(t, http.DefaultTransport, "https://api.mangadex.org/manga"); err != nil || got != want {
+		t.Errorf("GET with new settings = %q, %v, want %q", got, err, want)
+	}
+}
+
+func TestSanitiseNetworkSettings(t *testing.T) {
+	conf := &UserConfig{
+		APIURL:         "api.mangadex.org",
+		AtHomeURL:      "ftp://uploads.example",
+		AuthURL:        "auth.example",
+		RequestTimeout: -5,
+		Proxy:          "socks4://127.0.0.1:1080",
+		CABundle:       "testdata/does-not-exist.pem",
+	}
+	problems := conf.sanitiseConfigurations()
+	var fields []string
+	for _, problem := range problems {
+		fields = append(fields, problem.Field)
+	}
+	if got, want := strings.Join(fields, ","), "apiUrl,atHomeUrl,authUrl,requestTimeout,proxy,caBundle"; got != want {
+		t.Errorf("problems with %s, want %s", got, want)
+	}
+	if conf.APIURL != defaultAPIURL || conf.AtHomeURL != "" || conf.AuthURL != "" || conf.RequestTimeout != 0 ||
+		conf.Proxy != "" || conf.CABundle != "" {
+		t.Errorf("invalid settings were kept: %+v", conf)
+	}
+
+	conf = &UserConfig{Proxy: "socks5://127.0.0.1:1080"}
+	if problems = conf.sanitiseConfigurations(); len(problems) != 0 {
+		t.Errorf("problems with a SOCKS5 proxy: %v", problems)
+	}
+}
+
+// roundTripFunc : A transport that is a function.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,153 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Options : Settings given on the command line or in environment variables. They take precedence over the
+// configuration file for as long as the app is running, but are never saved to it.
+type Options struct {
+	ConfigDir   string // The folder for the configuration, credentials, theme and logs, instead of the default one.
+	ConfigFile  string // The configuration file to use, instead of finding one in the configuration folder.
+	DownloadDir string
+	Languages   []string
+	Quality     string
+	Guest       *bool // Nil if guest mode is not given, in which case the configuration file decides.
+	Offline     bool  // Do not contact MangaDex at all. This implies guest mode.
+	LogLevel    string
+	SecretStore string // Where to keep secrets, from SecretStores. Empty to choose automatically.
+	Profile     string // The profile to start with. Empty for the one that was used last.
+	Passphrase  string // The passphrase of the secret file. Empty to ask for it when it is needed.
+}
+
+// LogLevels : The levels that logging can be set to, from logging the most to the least.
+var LogLevels = []string{"info", "error", "off"}
+
+// options : The options the app was started with.
+var options = &Options{}
+
+// SetOptions : Use options given on the command line or in environment variables. This must be done before the
+// app is initialised, as the options decide where the configuration is read from.
+func SetOptions(opts *Options) {
+	options = opts
+	// The profile and secret store depend on the options, such as the configuration folder.
+	profileMutex.Lock()
+	profile = ""
+	profileMutex.Unlock()
+	secretsMutex.Lock()
+	secrets = nil
+	secretsMutex.Unlock()
+	if opts.Offline {
+		// The network settings are not applied in offline mode, so every client uses the default transport, and this
+		// stops any request from being sent.
+		http.DefaultTransport = offlineTransport{}
+	}
+}
+
+// Offline : Check whether the app was started in offline mode.
+func Offline() bool {
+	return options.Offline
+}
+
+// Validate : Check that the options have valid values. Languages are trimmed and lowercased.
+func (o *Options) Validate() error {
+	for i, lang := range o.Languages {
+		o.Languages[i] = strings.ToLower(strings.TrimSpace(lang))
+		if !validLanguage(o.Languages[i]) {
+			return fmt.Errorf("%q is not a language code, such as en or pt-br", lang)
+		}
+	}
+	if o.Quality != "" && o.Quality != "data" && o.Quality != "data-saver" {
+		return fmt.Errorf("download quality must be data or data-saver, not %q", o.Quality)
+	}
+	if o.LogLevel != "" && !contains(LogLevels, o.LogLevel) {
+		return fmt.Errorf("log level must be one of %s, not %q", strings.Join(LogLevels, ", "), o.LogLevel)
+	}
+	if o.Profile != "" {
+		if err := ValidateProfileName(o.Profile); err != nil {
+			return fmt.Errorf("invalid profile %q: %w", o.Profile, err)
+		}
+	}
+	if o.SecretStore != "" && !contains(SecretStores, o.SecretStore) {
+		return fmt.Errorf("secret store must be one of %s, not %q", strings.Join(SecretStores, ", "),
+			o.SecretStore)
+	}
+	return nil
+}
+
+// OverriddenSettings : Get the names of the settings in the configuration file that options are given for.
+func OverriddenSettings() []string {
+	var names []string
+	if options.DownloadDir != "" {
+		names = append(names, "downloadDir")
+	}
+	if len(options.Languages) != 0 {
+		names = append(names, "languages")
+	}
+	if options.Quality != "" {
+		names = append(names, "downloadQuality")
+	}
+	if options.Guest != nil || options.Offline {
+		names = append(names, "guestMode")
+	}
+	return names
+}
+
+// apply : Get a copy of a configuration with the options used instead of the settings they are given for.
+func (o *Options) apply(conf *UserConfig) *UserConfig {
+	c := *conf
+	if o.DownloadDir != "" {
+		c.DownloadDir = o.DownloadDir
+	}
+	if len(o.Languages) != 0 {
+		c.Languages = o.Languages
+	}
+	if o.Quality != "" {
+		c.DownloadQuality = o.Quality
+	}
+	if o.Guest != nil {
+		c.GuestMode = *o.Guest
+	}
+	if o.Offline {
+		c.GuestMode = true
+	}
+	return &c
+}
+
+// unapply : Get a copy of a configuration with the settings that options are given for set back to their values
+// in the configuration file, so that the options are not saved to it.
+func (o *Options) unapply(conf, file *UserConfig) *UserConfig {
+	c := *conf
+	if o.DownloadDir != "" {
+		c.DownloadDir = file.DownloadDir
+	}
+	if len(o.Languages) != 0 {
+		c.Languages = file.Languages
+	}
+	if o.Quality != "" {
+		c.DownloadQuality = file.DownloadQuality
+	}
+	if o.Guest != nil || o.Offline {
+		c.GuestMode = file.GuestMode
+	}
+	return &c
+}
+
+// offlineTransport : Refuses every request, so that MangaDex is not contacted in offline mode.
+type offlineTransport struct{}
+
+func (offlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return nil, fmt.Errorf("offline mode, not connecting to %s", req.URL.Host)
+}
+
+// contains : Check whether a list has an item.
+func contains(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
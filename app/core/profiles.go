@@ -0,0 +1,134 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultProfile : The profile used unless another one is chosen. Its files are kept directly in the configuration
+// folder, where versions of the app from before profiles kept them.
+const DefaultProfile = "default"
+
+// profileNamePattern : What profile names can be, so that they can be used as folder names.
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,31}$`)
+
+var (
+	// profile : The profile in use. Empty until it is first needed, when the one to start with is chosen.
+	profile      string
+	profileMutex sync.Mutex
+)
+
+// Profile : Get the name of the profile in use. The app starts with the profile given in the options, or else the
+// one that was used last.
+func Profile() string {
+	profileMutex.Lock()
+	defer profileMutex.Unlock()
+	if profile == "" {
+		profile = startingProfile()
+	}
+	return profile
+}
+
+// startingProfile : Get the profile that the app starts with.
+func startingProfile() string {
+	if options.Profile != "" {
+		return options.Profile
+	}
+	content, err := ioutil.ReadFile(lastProfilePath())
+	if err != nil {
+		return DefaultProfile
+	}
+	name := strings.TrimSpace(string(content))
+	if ValidateProfileName(name) != nil {
+		return DefaultProfile
+	}
+	if _, err = os.Stat(profileDir(name)); err != nil {
+		return DefaultProfile
+	}
+	return name
+}
+
+// UseProfile : Switch to another profile, creating it if it does not exist. Its configuration and secrets are
+// used from then on, though the configuration is only read when it is next loaded. The app starts with the
+// profile the next time it is run, unless another one is given in the options.
+func UseProfile(name string) error {
+	if err := ValidateProfileName(name); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(profileDir(name), configDirPerm); err != nil {
+		return fmt.Errorf("unable to create profile: %w", err)
+	}
+	if err := ioutil.WriteFile(lastProfilePath(), []byte(name), 0600); err != nil {
+		LogErrorf("Unable to remember the profile: %s\n", err.Error())
+	}
+
+	profileMutex.Lock()
+	profile = name
+	profileMutex.Unlock()
+	// Each profile has its own secrets.
+	secretsMutex.Lock()
+	secrets = nil
+	secretsMutex.Unlock()
+	log.Printf("Using profile %s.\n", name)
+	return nil
+}
+
+// Profiles : Get the names of every profile, sorted, with the default profile first.
+func Profiles() []string {
+	var names []string
+	entries, err := ioutil.ReadDir(filepath.Join(getConfDir(), "profiles"))
+	if err != nil && !os.IsNotExist(err) {
+		LogErrorf("Unable to list profiles: %s\n", err.Error())
+	}
+	for _, entry := range entries {
+		if entry.IsDir() && ValidateProfileName(entry.Name()) == nil && entry.Name() != DefaultProfile {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return append([]string{DefaultProfile}, names...)
+}
+
+// ValidateProfileName : Check that a profile name can be used.
+func ValidateProfileName(name string) error {
+	if !profileNamePattern.MatchString(name) {
+		return errors.New("profile names must be up to 32 letters, numbers, dashes or underscores, " +
+			"starting with a letter or number")
+	}
+	return nil
+}
+
+// ProfileDir : Get the folder of the profile in use, where its configuration and secrets are kept.
+func ProfileDir() string {
+	return profileDir(Profile())
+}
+
+// profileDir : Get the folder of a profile.
+func profileDir(name string) string {
+	if name == DefaultProfile {
+		return getConfDir()
+	}
+	return filepath.Join(getConfDir(), "profiles", name)
+}
+
+// lastProfilePath : The filepath to the file that has the name of the profile that was used last.
+func lastProfilePath() string {
+	return filepath.Join(getConfDir(), "profile")
+}
+
+// defaultDownloadDir : Get the folder that downloads are saved in by default. Profiles other than the default
+// one keep their downloads in their own folder.
+func defaultDownloadDir() string {
+	if Profile() == DefaultProfile {
+		return downloadDir
+	}
+	return filepath.Join(ProfileDir(), "downloads")
+}
@@ -0,0 +1,103 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zalando/go-keyring"
+)
+
+func TestProfiles(t *testing.T) {
+	confDir := t.TempDir()
+	SetOptions(&Options{ConfigDir: confDir})
+	defer SetOptions(&Options{})
+
+	// The default profile keeps its files where they were before profiles.
+	if got := Profile(); got != DefaultProfile {
+		t.Fatalf("Profile = %q, want %q", got, DefaultProfile)
+	}
+	if got, want := ConfigFile(), filepath.Join(confDir, "config.json"); got != want {
+		t.Errorf("ConfigFile = %q, want %q", got, want)
+	}
+
+	for _, name := range []string{"", "../escape", "has space", strings.Repeat("a", 33), "-dash"} {
+		if err := UseProfile(name); err == nil {
+			t.Errorf("UseProfile(%q) succeeded, want an error", name)
+		}
+	}
+	if err := UseProfile("work"); err != nil {
+		t.Fatal(err)
+	}
+	workDir := filepath.Join(confDir, "profiles", "work")
+	if got, want := ConfigFile(), filepath.Join(workDir, "config.json"); got != want {
+		t.Errorf("ConfigFile = %q, want %q", got, want)
+	}
+	if got, want := secretFilePath(), filepath.Join(workDir, "secrets"); got != want {
+		t.Errorf("secretFilePath = %q, want %q", got, want)
+	}
+	conf := &UserConfig{}
+	conf.sanitiseConfigurations()
+	if want := filepath.Join(workDir, "downloads"); conf.DownloadDir != want {
+		t.Errorf("default download folder = %q, want %q", conf.DownloadDir, want)
+	}
+	if got := strings.Join(Profiles(), ","); got != "default,work" {
+		t.Errorf("Profiles = %s, want default,work", got)
+	}
+
+	// The app starts with the profile that was used last, unless another one is given.
+	SetOptions(&Options{ConfigDir: confDir})
+	if got := Profile(); got != "work" {
+		t.Errorf("Profile after restarting = %q, want work", got)
+	}
+	SetOptions(&Options{ConfigDir: confDir, Profile: "home"})
+	if got := Profile(); got != "home" {
+		t.Errorf("Profile with the option = %q, want home", got)
+	}
+}
+
+func TestProfileSecrets(t *testing.T) {
+	keyring.MockInit()
+	SetOptions(&Options{ConfigDir: t.TempDir()})
+	defer SetOptions(&Options{})
+
+	// Each profile has its own credentials.
+	creds := map[string]Credentials{
+		DefaultProfile: {ClientID: "home-client", RefreshToken: "home"},
+		"work":         {ClientID: "work-client", RefreshToken: "work"},
+	}
+	for _, name := range []string{DefaultProfile, "work"} {
+		if err := UseProfile(name); err != nil {
+			t.Fatal(err)
+		}
+		if err := (StoredCredentials{Secrets: Secrets()}).Store(creds[name]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{DefaultProfile, "work"} {
+		if err := UseProfile(name); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := (StoredCredentials{Secrets: Secrets()}).Load(); err != nil || got != creds[name] {
+			t.Errorf("credentials of %s = %+v, %v, want %+v", name, got, err, creds[name])
+		}
+	}
+
+	// Every profile's secrets are wiped.
+	if err := WipeSecrets(); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{DefaultProfile, "work"} {
+		if err := UseProfile(name); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := (StoredCredentials{Secrets: Secrets()}).Load(); !errors.Is(err, ErrNoSecret) {
+			t.Errorf("credentials of %s after wiping = %v, want ErrNoSecret", name, err)
+		}
+	}
+	if _, err := os.Stat(lastProfilePath()); err != nil {
+		t.Errorf("last used profile was not remembered: %v", err)
+	}
+}
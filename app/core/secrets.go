@@ -0,0 +1,472 @@
+package core
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/zalando/go-keyring"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/term"
+)
+
+// keyringService : The name that the app's secrets are kept under in the system keyring.
+const keyringService = "mangadesk"
+
+// SecretStores : The kinds of secret store that can be used. With auto, the system keyring is used if there is
+// one, and an encrypted file otherwise.
+var SecretStores = []string{"auto", "keyring", "file"}
+
+var (
+	// ErrNoSecret : There is no secret with the key.
+	ErrNoSecret = errors.New("no secret stored")
+	// ErrWrongPassphrase : The secret file cannot be decrypted with the passphrase.
+	ErrWrongPassphrase = errors.New("wrong passphrase for the secret file")
+)
+
+// SecretStore : Keeps secrets, such as the user's credentials, between runs of the app.
+type SecretStore interface {
+	Get(key string) (string, error) // Returns ErrNoSecret if there is no secret with the key.
+	Set(key, secret string) error
+	Delete(key string) error // Deleting a secret that does not exist is not an error.
+	Clear() error            // Delete every secret in the store.
+}
+
+var (
+	// secrets : The secret store of the app, created when it is first used. Nil until then.
+	secrets      SecretStore
+	secretsMutex sync.Mutex
+)
+
+// Secrets : Get the secret store chosen by the options. If the store is chosen automatically, the encrypted secret
+// file is used when the system keyring cannot be. If the system keyring was chosen but cannot be used, the secret file
+// is not used instead, and the store fails with why.
+func Secrets() SecretStore {
+	secretsMutex.Lock()
+	defer secretsMutex.Unlock()
+	if secrets == nil {
+		secrets = newSecretStore(options.SecretStore)
+	}
+	return secrets
+}
+
+// newSecretStore : Create a secret store of a kind, from SecretStores.
+func newSecretStore(kind string) SecretStore {
+	if kind != "file" {
+		store := NewKeyringStore(ProfileDir())
+		err := store.check()
+		if err == nil {
+			log.Println("Keeping secrets in the system keyring.")
+			return store
+		} else if kind == "keyring" {
+			LogErrorf("Unable to use the system keyring: %s\n", err.Error())
+			return failedStore{err: fmt.Errorf("the system keyring cannot be used: %w", err)}
+		}
+	}
+	log.Println("Keeping secrets in the secret file.")
+	return NewSecretFile(secretFilePath(), passphrase)
+}
+
+// WipeSecrets : Delete every secret the app stored for every profile, wherever it was stored, along with the
+// plaintext credentials files of older versions. Secret files are deleted without asking for their passphrase.
+func WipeSecrets() error {
+	var errs []error
+	for _, name := range Profiles() {
+		if store := NewKeyringStore(profileDir(name)); store.check() == nil {
+			if err := store.Clear(); err != nil {
+				errs = append(errs, fmt.Errorf("unable to clear the system keyring: %w", err))
+			}
+		}
+		for _, path := range []string{secretFilePathOf(name), credFilePathOf(name)} {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				errs = append(errs, err)
+			}
+		}
+	}
+	secretsMutex.Lock()
+	secrets = nil // The secret file may have been deleted, along with the key it was read with.
+	secretsMutex.Unlock()
+	return errors.Join(errs...)
+}
+
+// PassphrasePrompt : Asks the user for the passphrase of the secret file, or for a new one if create is true.
+type PassphrasePrompt func(create bool) (string, error)
+
+var (
+	// passphrasePrompt : How the passphrase is asked for when it is not given in the options.
+	passphrasePrompt PassphrasePrompt = PromptPassphrase
+	passphraseMutex  sync.Mutex
+)
+
+// SetPassphrasePrompt : Ask for the passphrase of the secret file some other way, such as while the terminal
+// interface is suspended.
+func SetPassphrasePrompt(prompt PassphrasePrompt) {
+	passphraseMutex.Lock()
+	defer passphraseMutex.Unlock()
+	passphrasePrompt = prompt
+}
+
+// passphrase : Get the passphrase of the secret file, from the options or by asking the user.
+func passphrase(create bool) (string, error) {
+	if options.Passphrase != "" {
+		return options.Passphrase, nil
+	}
+	passphraseMutex.Lock()
+	prompt := passphrasePrompt
+	passphraseMutex.Unlock()
+	return prompt(create)
+}
+
+// PromptPassphrase : Ask for the passphrase of the secret file on the terminal. A new passphrase is asked for
+// twice, so that it is not mistyped.
+func PromptPassphrase(create bool) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", errors.New("no passphrase for the secret file, set MANGADESK_PASSPHRASE")
+	}
+	ask := func(prompt string) (string, error) {
+		fmt.Fprint(os.Stderr, prompt)
+		defer fmt.Fprintln(os.Stderr)
+		pass, err := term.ReadPassword(fd)
+		return string(pass), err
+	}
+	if !create {
+		return ask("Passphrase for your stored credentials: ")
+	}
+	pass, err := ask("Choose a passphrase to encrypt your stored credentials with: ")
+	if err != nil || pass == "" {
+		return pass, err
+	}
+	again, err := ask("Enter the passphrase again: ")
+	if err != nil {
+		return "", err
+	} else if again != pass {
+		return "", errors.New("the passphrases do not match")
+	}
+	return pass, nil
+}
+
+// secretFilePath : The filepath to the encrypted secret file of the profile in use.
+func secretFilePath() string {
+	return secretFilePathOf(Profile())
+}
+
+// secretFilePathOf : The filepath to the encrypted secret file of a profile.
+func secretFilePathOf(name string) string {
+	return filepath.Join(profileDir(name), "secrets")
+}
+
+// failedStore : A secret store that cannot be used, which fails with why.
+type failedStore struct {
+	err error
+}
+
+func (s failedStore) Get(string) (string, error) { return "", s.err }
+func (s failedStore) Set(string, string) error   { return s.err }
+func (s failedStore) Delete(string) error        { return s.err }
+func (s failedStore) Clear() error               { return s.err }
+
+// KeyringStore : Keeps secrets in the system keyring, such as the Secret Service, macOS Keychain or Windows
+// Credential Manager. Secrets of different profiles and configuration folders are kept apart.
+type KeyringStore struct {
+	namespace string
+}
+
+// NewKeyringStore : Create a store for the secrets of a namespace, such as the folder of a profile.
+func NewKeyringStore(namespace string) *KeyringStore {
+	return &KeyringStore{namespace: namespace}
+}
+
+// indexKey : The key of the list of every key in the namespace, as keyrings cannot list them.
+const indexKey = "index"
+
+// check : Check that the system keyring can be used.
+func (s *KeyringStore) check() error {
+	_, err := keyring.Get(keyringService, s.account(indexKey))
+	if errors.Is(err, keyring.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// account : The name a secret is kept under in the keyring.
+func (s *KeyringStore) account(key string) string {
+	return s.namespace + ":" + key
+}
+
+func (s *KeyringStore) Get(key string) (string, error) {
+	secret, err := keyring.Get(keyringService, s.account(key))
+	if errors.Is(err, keyring.ErrNotFound) {
+		return "", ErrNoSecret
+	}
+	return secret, err
+}
+
+func (s *KeyringStore) Set(key, secret string) error {
+	keys, err := s.keys()
+	if err != nil {
+		return err
+	}
+	if err = keyring.Set(keyringService, s.account(key), secret); err != nil {
+		return err
+	}
+	if contains(keys, key) {
+		return nil
+	}
+	return s.setKeys(append(keys, key))
+}
+
+func (s *KeyringStore) Delete(key string) error {
+	if err := keyring.Delete(keyringService, s.account(key)); err != nil && !errors.Is(err, keyring.ErrNotFound) {
+		return err
+	}
+	keys, err := s.keys()
+	if err != nil {
+		return err
+	}
+	var left []string
+	for _, k := range keys {
+		if k != key {
+			left = append(left, k)
+		}
+	}
+	return s.setKeys(left)
+}
+
+func (s *KeyringStore) Clear() error {
+	keys, err := s.keys()
+	if err != nil {
+		return err
+	}
+	for _, key := range append(keys, indexKey) {
+		if err = keyring.Delete(keyringService, s.account(key)); err != nil && !errors.Is(err, keyring.ErrNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// keys : Get the keys of every secret in the namespace.
+func (s *KeyringStore) keys() ([]string, error) {
+	index, err := keyring.Get(keyringService, s.account(indexKey))
+	if errors.Is(err, keyring.ErrNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var keys []string
+	err = json.Unmarshal([]byte(index), &keys)
+	return keys, err
+}
+
+// setKeys : Change the keys of every secret in the namespace.
+func (s *KeyringStore) setKeys(keys []string) error {
+	if len(keys) == 0 {
+		err := keyring.Delete(keyringService, s.account(indexKey))
+		if errors.Is(err, keyring.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	index, err := json.Marshal(keys)
+	if err != nil {
+		return err
+	}
+	return keyring.Set(keyringService, s.account(indexKey), string(index))
+}
+
+// scrypt parameters for deriving the key of the secret file from its passphrase.
+const (
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	secretKeyLen = 32 // For AES-256.
+)
+
+// SecretFile : Keeps secrets in a file that only the user can read, encrypted with a key derived from a
+// passphrase.
+type SecretFile struct {
+	path       string
+	passphrase func(create bool) (string, error) // Asks for the passphrase, or a new one if the file is new.
+
+	mu   sync.Mutex
+	pass string // Empty until the passphrase is asked for.
+	salt []byte // The salt that key was derived with.
+	key  []byte
+}
+
+// secretFileContent : The content of the secret file. The secrets are encrypted with AES-GCM.
+type secretFileContent struct {
+	Salt  []byte `json:"salt"`
+	Nonce []byte `json:"nonce"`
+	Data  []byte `json:"data"`
+}
+
+// NewSecretFile : Create a store for secrets in a file, encrypted with a passphrase. The passphrase is only asked
+// for when the file is first read or written.
+func NewSecretFile(path string, passphrase func(create bool) (string, error)) *SecretFile {
+	return &SecretFile{path: path, passphrase: passphrase}
+}
+
+func (f *SecretFile) Get(key string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	values, err := f.read()
+	if err != nil {
+		return "", err
+	}
+	secret, ok := values[key]
+	if !ok {
+		return "", ErrNoSecret
+	}
+	return secret, nil
+}
+
+func (f *SecretFile) Set(key, secret string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	values, err := f.read()
+	if err != nil {
+		return err
+	}
+	values[key] = secret
+	return f.write(values)
+}
+
+func (f *SecretFile) Delete(key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, err := os.Stat(f.path); os.IsNotExist(err) {
+		return nil
+	}
+	values, err := f.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := values[key]; !ok {
+		return nil
+	}
+	delete(values, key)
+	if len(values) == 0 {
+		return os.Remove(f.path)
+	}
+	return f.write(values)
+}
+
+func (f *SecretFile) Clear() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.salt, f.key = nil, nil
+	if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// read : Read and decrypt the secrets in the file. There are none if the file does not exist, in which case the
+// passphrase is not asked for. The lock must be held.
+func (f *SecretFile) read() (map[string]string, error) {
+	data, err := ioutil.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return map[string]string{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var content secretFileContent
+	if err = json.Unmarshal(data, &content); err != nil {
+		return nil, fmt.Errorf("unable to read the secret file: %w", err)
+	}
+	gcm, err := f.cipher(content.Salt, false)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, content.Nonce, content.Data, nil)
+	if err != nil {
+		f.pass, f.salt, f.key = "", nil, nil // So that it can be entered again.
+		return nil, ErrWrongPassphrase
+	}
+	values := map[string]string{}
+	err = json.Unmarshal(plain, &values)
+	return values, err
+}
+
+// write : Encrypt the secrets, and replace the file with them. The file can only be read by the user. The lock
+// must be held.
+func (f *SecretFile) write(values map[string]string) error {
+	plain, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	salt, create := f.salt, f.salt == nil
+	if create {
+		salt = make([]byte, 16)
+		if _, err = rand.Read(salt); err != nil {
+			return err
+		}
+	}
+	gcm, err := f.cipher(salt, create)
+	if err != nil {
+		return err
+	}
+	content := secretFileContent{Salt: salt, Nonce: make([]byte, gcm.NonceSize())}
+	if _, err = rand.Read(content.Nonce); err != nil {
+		return err
+	}
+	content.Data = gcm.Seal(nil, content.Nonce, plain, nil)
+	data, err := json.Marshal(content)
+	if err != nil {
+		return err
+	}
+
+	// The file is replaced at once, so that the secrets are not lost if writing fails.
+	if err = os.MkdirAll(filepath.Dir(f.path), configDirPerm); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(f.path), ".secrets-*") // Only the user can read it.
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
+
+// cipher : Get the cipher for a salt, deriving the key from the passphrase if it was not derived with the salt
+// already. The passphrase is asked for the first time. The lock must be held.
+func (f *SecretFile) cipher(salt []byte, create bool) (cipher.AEAD, error) {
+	if f.key == nil || string(f.salt) != string(salt) {
+		if f.pass == "" {
+			pass, err := f.passphrase(create)
+			if err != nil {
+				return nil, err
+			} else if pass == "" {
+				return nil, errors.New("no passphrase given for the secret file")
+			}
+			f.pass = pass
+		}
+		key, err := scrypt.Key([]byte(f.pass), salt, scryptN, scryptR, scryptP, secretKeyLen)
+		if err != nil {
+			return nil, err
+		}
+		f.salt, f.key = salt, key
+	}
+	block, err := aes.NewCipher(f.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
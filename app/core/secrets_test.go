@@ -0,0 +1,167 @@
+package core
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/zalando/go-keyring"
+)
+
+// fixedPassphrase : Gives a passphrase without asking for it, counting how many times it was asked for.
+func fixedPassphrase(pass string, asked *int) func(bool) (string, error) {
+	return func(bool) (string, error) {
+		*asked++
+		return pass, nil
+	}
+}
+
+func TestSecretFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets")
+	var asked int
+	file := NewSecretFile(path, fixedPassphrase("correct horse", &asked))
+
+	if _, err := file.Get("token"); !errors.Is(err, ErrNoSecret) || asked != 0 {
+		t.Fatalf("Get before storing = %v, asked %d times, want ErrNoSecret without asking", err, asked)
+	}
+	if err := file.Set("token", "very secret"); err != nil {
+		t.Fatal(err)
+	}
+	if secret, err := file.Get("token"); err != nil || secret != "very secret" {
+		t.Errorf("Get = %q, %v, want the stored secret", secret, err)
+	}
+	if asked != 1 {
+		t.Errorf("passphrase asked for %d times, want once", asked)
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), "very secret") {
+		t.Error("secret file is not encrypted")
+	}
+	if info, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	} else if perm := info.Mode().Perm(); runtime.GOOS != "windows" && perm != 0600 {
+		t.Errorf("secret file permissions = %o, want 600", perm)
+	}
+
+	// The file cannot be read with another passphrase.
+	wrong := NewSecretFile(path, fixedPassphrase("wrong horse", &asked))
+	if _, err = wrong.Get("token"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Get with the wrong passphrase = %v, want ErrWrongPassphrase", err)
+	}
+
+	if err = file.Delete("token"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("secret file with no secrets left was kept: %v", err)
+	}
+}
+
+func TestKeyringStore(t *testing.T) {
+	keyring.MockInit()
+	store := NewKeyringStore("/config")
+	other := NewKeyringStore("/other-config")
+	if err := store.check(); err != nil {
+		t.Fatalf("keyring cannot be used: %v", err)
+	}
+	for _, key := range []string{"credentials", "other"} {
+		if err := store.Set(key, "secret "+key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := other.Set("credentials", "not mine"); err != nil {
+		t.Fatal(err)
+	}
+	if secret, err := store.Get("credentials"); err != nil || secret != "secret credentials" {
+		t.Errorf("Get = %q, %v, want the stored secret", secret, err)
+	}
+
+	// Clearing only deletes the secrets of the namespace.
+	if err := store.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"credentials", "other"} {
+		if _, err := store.Get(key); !errors.Is(err, ErrNoSecret) {
+			t.Errorf("Get %s after clearing = %v, want ErrNoSecret", key, err)
+		}
+	}
+	if secret, err := other.Get("credentials"); err != nil || secret != "not mine" {
+		t.Errorf("secret of another namespace = %q, %v, want it kept", secret, err)
+	}
+}
+
+func TestUnusableKeyring(t *testing.T) {
+	unusable := errors.New("no keyring here")
+	keyring.MockInitWithError(unusable)
+	defer keyring.MockInit()
+	SetOptions(&Options{ConfigDir: t.TempDir()})
+	defer SetOptions(&Options{})
+
+	// The secret file is only used instead if the store is chosen automatically.
+	if _, ok := newSecretStore("auto").(*SecretFile); !ok {
+		t.Error("secret file not used when there is no keyring")
+	}
+	store := newSecretStore("keyring")
+	if _, err := store.Get(credentialsKey); !errors.Is(err, unusable) {
+		t.Errorf("Get with an unusable keyring = %v, want the keyring's error", err)
+	}
+	if err := store.Set(credentialsKey, "secret"); !errors.Is(err, unusable) {
+		t.Errorf("Set with an unusable keyring = %v, want the keyring's error", err)
+	}
+	if _, err := os.Stat(secretFilePath()); !os.IsNotExist(err) {
+		t.Errorf("secret file used when the keyring was chosen: %v", err)
+	}
+}
+
+func TestMigrateCredentialFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    Credentials
+	}{
+		{name: "token", content: "old-refresh-token\n", want: Credentials{RefreshToken: "old-refresh-token"}},
+		{
+			name:    "json",
+			content: `{"clientId":"client","clientSecret":"secret","refreshToken":"token"}`,
+			want:    Credentials{ClientID: "client", ClientSecret: "secret", RefreshToken: "token"},
+		},
+	}
+	keyring.MockInit() // WipeSecrets also clears the keyring.
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetOptions(&Options{ConfigDir: t.TempDir()})
+			defer SetOptions(&Options{})
+			if err := ioutil.WriteFile(credFilePath(), []byte(tt.content), os.ModePerm); err != nil {
+				t.Fatal(err)
+			}
+
+			var asked int
+			store := StoredCredentials{Secrets: NewSecretFile(secretFilePath(), fixedPassphrase("pass", &asked))}
+			if creds, err := store.Load(); err != nil || creds != tt.want {
+				t.Errorf("Load = %+v, %v, want %+v", creds, err, tt.want)
+			}
+			if _, err := os.Stat(credFilePath()); !os.IsNotExist(err) {
+				t.Errorf("plaintext credentials file was kept: %v", err)
+			}
+			if _, err := store.Secrets.Get(credentialsKey); err != nil {
+				t.Errorf("credentials were not moved into the secret store: %v", err)
+			}
+
+			// Every secret is deleted, even without the passphrase.
+			if err := WipeSecrets(); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(secretFilePath()); !os.IsNotExist(err) {
+				t.Errorf("secret file was kept: %v", err)
+			}
+		})
+	}
+}
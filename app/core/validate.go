@@ -0,0 +1,381 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// iso6391 : The two-letter ISO 639-1 language codes. MangaDex language codes start with one of these.
+var iso6391 = toSet(strings.Fields(`
+	aa ab ae af ak am an ar as av ay az ba be bg bh bi bm bn bo br bs ca ce ch co cr cs cu cv cy da de dv dz ee el
+	en eo es et eu fa ff fi fj fo fr fy ga gd gl gn gu gv ha he hi ho hr ht hu hy hz ia id ie ig ii ik io is it iu
+	ja jv ka kg ki kj kk kl km kn ko kr ks ku kv kw ky la lb lg li ln lo lt lu lv mg mh mi mk ml mn mr ms mt my na
+	nb nd ne ng nl nn no nr nv ny oc oj om or os pa pi pl ps pt qu rm rn ro ru rw sa sc sd se sg si sk sl sm sn so
+	sq sr ss st su sv sw ta te tg th ti tk tl tn to tr ts tt tw ty ug uk ur uz ve vi vo wa wo xh yi yo za zh zu`))
+
+// configFields : The fields of UserConfig, by their names in the configuration file.
+var configFields = func() map[string]int {
+	fields := map[string]int{}
+	t := reflect.TypeOf(UserConfig{})
+	for i := 0; i < t.NumField(); i++ {
+		fields[strings.Split(t.Field(i).Tag.Get("json"), ",")[0]] = i
+	}
+	return fields
+}()
+
+// filePosition : Where a value is in a configuration file. Both start at 1.
+type filePosition struct {
+	Line, Column int
+}
+
+// parseConfiguration : Parse the contents of a configuration file, using the defaults for any fields that are
+// missing or invalid. Every problem found is returned, with where it is in the file. An error is returned if the
+// file is not valid in its format, in which case the defaults are returned.
+func parseConfiguration(format *configFormat, data []byte) (*UserConfig, []*ConfigError, error) {
+	settings, positions, err := format.decode(data)
+	if err != nil {
+		conf := &UserConfig{}
+		conf.sanitiseConfigurations()
+		return conf, nil, err
+	}
+	return parseSettings(settings, positions)
+}
+
+// parseSettings : Parse the settings of a configuration file, converted to JSON. Problems are located using the
+// positions of the values in the file, if they are known.
+func parseSettings(data []byte, positions map[string]filePosition) (*UserConfig, []*ConfigError, error) {
+	conf := &UserConfig{}
+
+	// Files from older versions are migrated first. Their problems are found in the migrated contents, so where
+	// they are in the file is not known.
+	var problems []*ConfigError
+	migrated, version, err := migrateConfiguration(data)
+	if err != nil {
+		var problem *ConfigError
+		if errors.As(err, &problem) {
+			problem.locate(positions)
+		}
+		conf.sanitiseConfigurations()
+		return conf, nil, err
+	}
+	if version > configVersion {
+		problems = append(problems, &ConfigError{
+			Field:   "version",
+			Message: "the file is from a newer version of mangadesk, settings that are not known are ignored",
+			Warning: true,
+		})
+	} else if version < configVersion {
+		data, positions = migrated, nil
+	}
+
+	// Decode each setting on its own, so that a problem with one does not hide problems with the others.
+	var members map[string]json.RawMessage
+	if err = json.Unmarshal(data, &members); err != nil {
+		conf.sanitiseConfigurations()
+		return conf, nil, &ConfigError{Message: "the configuration must be a set of settings, such as {} in JSON"}
+	}
+	names := make([]string, 0, len(members))
+	for name := range members {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		index, ok := configFields[name]
+		if !ok {
+			problem := &ConfigError{Field: name, Message: "unknown setting, it will be ignored", Warning: true}
+			if suggestion := closestField(name); suggestion != "" {
+				problem.Message = fmt.Sprintf("unknown setting, did you mean %q?", suggestion)
+			}
+			problems = append(problems, problem)
+			continue
+		}
+		problems = append(problems, decodeField(name, members[name], reflect.ValueOf(conf).Elem().Field(index))...)
+	}
+
+	problems = append(problems, conf.sanitiseConfigurations()...)
+	for _, problem := range problems {
+		problem.locate(positions)
+	}
+	// Report problems in the order they are in the file. Problems that are not in the file are reported last.
+	sort.SliceStable(problems, func(i, j int) bool {
+		a, b := problems[i], problems[j]
+		if (a.Line == 0) != (b.Line == 0) {
+			return b.Line == 0
+		}
+		return a.Line < b.Line || (a.Line == b.Line && a.Column < b.Column)
+	})
+	return conf, problems, nil
+}
+
+// decodeField : Decode the value of a setting into a field of UserConfig. Each item of a list is decoded on its
+// own, so that only the items with problems are left out.
+func decodeField(name string, raw json.RawMessage, field reflect.Value) []*ConfigError {
+	var items []json.RawMessage
+	if field.Kind() != reflect.Slice || json.Unmarshal(raw, &items) != nil {
+		if err := json.Unmarshal(raw, field.Addr().Interface()); err != nil {
+			field.Set(reflect.Zero(field.Type()))
+			return []*ConfigError{{Field: name, Message: describeDecodeError(err)}}
+		}
+		return nil
+	}
+
+	var problems []*ConfigError
+	list := reflect.MakeSlice(field.Type(), 0, len(items))
+	for i, item := range items {
+		elem := reflect.New(field.Type().Elem())
+		if err := json.Unmarshal(item, elem.Interface()); err != nil {
+			problems = append(problems, &ConfigError{
+				Field:   name,
+				Path:    fmt.Sprintf("%s[%d]", name, i),
+				Message: describeDecodeError(err) + "; it will be ignored",
+			})
+			continue
+		}
+		list = reflect.Append(list, elem.Elem())
+	}
+	field.Set(list)
+	return problems
+}
+
+// locateValues : Find where each value is in a JSON document, by its path such as `languages[1]`. For the members
+// of objects, this is where their key is. A *ConfigError with the position is returned if the JSON is invalid.
+func locateValues(data []byte) (map[string]filePosition, error) {
+	offsets := map[string]int{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	var walk func(path string) error
+	walk = func(path string) error {
+		start := skipSpace(data, int(dec.InputOffset()))
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if _, ok := offsets[path]; !ok {
+			offsets[path] = start
+		}
+		switch tok {
+		case json.Delim('{'):
+			for dec.More() {
+				keyStart := skipSpace(data, int(dec.InputOffset()))
+				key, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				member := key.(string)
+				if path != "" {
+					member = path + "." + member
+				}
+				offsets[member] = keyStart
+				if err = walk(member); err != nil {
+					return err
+				}
+			}
+			_, err = dec.Token()
+		case json.Delim('['):
+			for i := 0; dec.More(); i++ {
+				if err = walk(fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+			_, err = dec.Token()
+		}
+		return err
+	}
+
+	err := walk("")
+	if err == nil {
+		// Anything after the document is also invalid.
+		offset := skipSpace(data, int(dec.InputOffset()))
+		if _, err = dec.Token(); err == io.EOF {
+			positions := make(map[string]filePosition, len(offsets))
+			for path, offset := range offsets {
+				line, column := lineColumn(data, offset)
+				positions[path] = filePosition{Line: line, Column: column}
+			}
+			return positions, nil
+		}
+		problem := &ConfigError{Message: "unexpected content after the configuration"}
+		problem.Line, problem.Column = lineColumn(data, offset)
+		return nil, problem
+	}
+
+	problem := &ConfigError{Message: describeDecodeError(err)}
+	var syntaxErr *json.SyntaxError
+	offset := len(data)
+	if errors.As(err, &syntaxErr) {
+		offset = int(syntaxErr.Offset)
+	}
+	problem.Line, problem.Column = lineColumn(data, offset)
+	return nil, problem
+}
+
+// locate : Set where the value with the problem is in the configuration file, if it is in the file.
+func (e *ConfigError) locate(positions map[string]filePosition) {
+	if e.Path == "" {
+		e.Path = e.Field
+	}
+	position, ok := positions[e.Path]
+	if !ok {
+		position, ok = positions[e.Field]
+	}
+	if ok {
+		e.Line, e.Column = position.Line, position.Column
+	}
+}
+
+// describeDecodeError : Describe an error from decoding JSON in terms the user would understand.
+func describeDecodeError(err error) string {
+	var (
+		typeErr   *json.UnmarshalTypeError
+		syntaxErr *json.SyntaxError
+	)
+	switch {
+	case errors.As(err, &typeErr):
+		return fmt.Sprintf("must be %s, not %s", describeType(typeErr.Type), typeErr.Value)
+	case errors.As(err, &syntaxErr):
+		return fmt.Sprintf("invalid JSON: %s", syntaxErr.Error())
+	case errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF):
+		return "invalid JSON: the file ends too early, is a bracket or quote missing?"
+	}
+	return fmt.Sprintf("invalid JSON: %s", err.Error())
+}
+
+// describeType : Describe the kind of value that a setting takes.
+func describeType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "text"
+	case reflect.Bool:
+		return "true or false"
+	case reflect.Int:
+		return "a whole number"
+	case reflect.Slice:
+		return fmt.Sprintf("a list of %s", describeType(t.Elem()))
+	case reflect.Map:
+		return "an object"
+	}
+	return t.String()
+}
+
+// closestField : Find the setting with a name close to a name that is not a setting, such as `langauges`.
+// Returns an empty string if there is none.
+func closestField(name string) string {
+	var (
+		closest string
+		best    = 3 // Only names closer than this are suggested.
+	)
+	for field := range configFields {
+		if d := editDistance(strings.ToLower(name), strings.ToLower(field)); d < best ||
+			(d == best && closest != "" && field < closest) {
+			closest, best = field, d
+		}
+	}
+	return closest
+}
+
+// editDistance : The number of single character edits needed to change one string into another.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// validLanguage : Check whether a language code is one that MangaDex uses, which is an ISO 639-1 code that may be
+// followed by a region or script, such as `pt-br` or `ja-ro`.
+func validLanguage(code string) bool {
+	lang, region, hasRegion := strings.Cut(code, "-")
+	if _, ok := iso6391[lang]; !ok {
+		return false
+	}
+	if !hasRegion {
+		return true
+	}
+	if len(region) < 2 || len(region) > 4 {
+		return false
+	}
+	for _, r := range region {
+		if r < 'a' || r > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckWritable : Check that files can be created in a directory. If the directory does not exist yet, the
+// closest parent that does is checked instead, as the directory is created when it is needed.
+func CheckWritable(dir string) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	for {
+		info, err := os.Stat(dir)
+		if err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("%s is not a folder", dir)
+			}
+			break
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return err
+		}
+		dir = parent
+	}
+
+	f, err := os.CreateTemp(dir, ".mangadesk-check-*")
+	if err != nil {
+		return fmt.Errorf("cannot create files in %s", dir)
+	}
+	_ = f.Close()
+	return os.Remove(f.Name())
+}
+
+// lineColumn : Get the line and column of an offset in a file. Both start at 1.
+func lineColumn(data []byte, offset int) (int, int) {
+	offset = min(max(offset, 0), len(data))
+	line := bytes.Count(data[:offset], []byte("\n")) + 1
+	column := offset - bytes.LastIndexByte(data[:offset], '\n')
+	return line, column
+}
+
+// skipSpace : Skip whitespace and separators from an offset in a JSON document.
+func skipSpace(data []byte, offset int) int {
+	for offset < len(data) && strings.IndexByte(" \t\r\n,:", data[offset]) != -1 {
+		offset++
+	}
+	return offset
+}
+
+// toSet : Create a set from a list of strings.
+func toSet(items []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(items))
+	for _, item := range items {
+		set[item] = struct{}{}
+	}
+	return set
+}
@@ -0,0 +1,90 @@
+package core
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseConfigurationProblems(t *testing.T) {
+	tests := []struct {
+		name, data string
+		field      string // The field with the problem.
+		message    string // Part of the message.
+		line       int
+	}{
+		{
+			name:    "misspelt setting",
+			data:    "{\n  \"version\": 1,\n  \"langauges\": [\"en\"]\n}",
+			field:   "langauges",
+			message: `unknown setting, did you mean "languages"?`,
+			line:    3,
+		},
+		{
+			name:    "unknown setting",
+			data:    "{\n  \"version\": 1,\n  \"colourScheme\": \"dark\"\n}",
+			field:   "colourScheme",
+			message: "unknown setting, it will be ignored",
+			line:    3,
+		},
+		{
+			name:    "invalid download quality",
+			data:    "{\n  \"version\": 1,\n  \"downloadQuality\": \"best\"\n}",
+			field:   "downloadQuality",
+			message: `must be data or data-saver, not "best"`,
+			line:    3,
+		},
+		{
+			name:    "invalid language",
+			data:    "{\n  \"version\": 1,\n  \"languages\": [\"en\", \"english\"]\n}",
+			field:   "languages",
+			message: `"english" is not a language code`,
+			line:    3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, problems, err := parseConfiguration(jsonFormat, []byte(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(problems) != 1 {
+				t.Fatalf("got problems %v, want one", problems)
+			}
+			problem := problems[0]
+			if problem.Field != tt.field || !strings.Contains(problem.Message, tt.message) || problem.Line != tt.line {
+				t.Errorf("got problem with %s on line %d: %q, want one with %s on line %d: %q",
+					problem.Field, problem.Line, problem.Message, tt.field, tt.line, tt.message)
+			}
+		})
+	}
+}
+
+func TestSanitiseLanguages(t *testing.T) {
+	conf := &UserConfig{Languages: []string{" EN ", "pt-BR", "", "ja-ro"}}
+	if problems := conf.sanitiseConfigurations(); len(problems) != 0 {
+		t.Errorf("unexpected problems: %v", problems)
+	}
+	if want := []string{"en", "pt-br", "ja-ro"}; !reflect.DeepEqual(conf.Languages, want) {
+		t.Errorf("languages = %q, want %q", conf.Languages, want)
+	}
+}
+
+func TestCheckWritable(t *testing.T) {
+	dir := t.TempDir()
+	if err := CheckWritable(filepath.Join(dir, "not", "created")); err != nil {
+		t.Errorf("folder that is not created yet: %v, want it to be writable", err)
+	}
+	file := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(file, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckWritable(file); err == nil {
+		t.Error("file was checked as a writable folder")
+	}
+	if entries, _ := ioutil.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("checking left files behind: %v", entries)
+	}
+}
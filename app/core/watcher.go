@@ -0,0 +1,47 @@
+package core
+
+import (
+	"os"
+	"time"
+)
+
+// WatchFile : Check a file for changes at every interval, calling onChange whenever it is created, modified or
+// removed. A file is taken to have changed when its modification time or size changes.
+// Returns a function that stops watching the file.
+func WatchFile(path string, interval time.Duration, onChange func()) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		last := statFile(path)
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if current := statFile(path); current != last {
+					last = current
+					onChange()
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// fileState : The modification time and size of a file, used to tell when it has changed.
+type fileState struct {
+	modTime time.Time
+	size    int64
+	exists  bool
+}
+
+// statFile : Get the state of a file. A file that cannot be read is taken not to exist.
+func statFile(path string) fileState {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileState{}
+	}
+	return fileState{modTime: info.ModTime(), size: info.Size(), exists: true}
+}
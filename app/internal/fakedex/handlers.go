@@ -0,0 +1,398 @@
+package fakedex
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Paths of the authentication server, for personal API clients.
+const (
+	tokenPath  = "POST /realms/mangadex/protocol/openid-connect/token"
+	logoutPath = "POST /realms/mangadex/protocol/openid-connect/logout"
+)
+
+// Paths of the API, as mangodex.DexClient requests them.
+const (
+	userPath         = "GET /user/me"
+	followedListPath = "GET /user/follows/manga"
+	followedPath     = "GET /user/follows/manga/{id}"
+	followPath       = "POST /manga/{id}/follow"
+	unfollowPath     = "DELETE /manga/{id}/follow"
+	mangaListPath    = "GET /manga"
+	feedPath         = "GET /manga/{id}/feed"
+	readMarkersPath  = "GET /manga/{id}/read"
+	setReadPath      = "POST /manga/{id}/read"
+	atHomePath       = "GET /at-home/server/{id}"
+	pagePath         = "GET /data/{hash}/{page}"
+	dataSaverPath    = "GET /data-saver/{hash}/{page}"
+	reportPath       = "POST /report"
+)
+
+// Limits of lists, as the API has.
+const (
+	defaultListLimit = 10
+	maxListLimit     = 100
+	maxChapterLimit  = 500
+)
+
+// routes : Set up the handlers for the API.
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(tokenPath, s.token)
+	mux.HandleFunc(logoutPath, s.logout)
+	mux.HandleFunc(userPath, s.authenticated(s.user))
+	mux.HandleFunc(followedListPath, s.authenticated(s.followedList))
+	mux.HandleFunc(followedPath, s.authenticated(s.followed))
+	mux.HandleFunc(followPath, s.authenticated(s.setFollow(true)))
+	mux.HandleFunc(unfollowPath, s.authenticated(s.setFollow(false)))
+	mux.HandleFunc(mangaListPath, s.mangaList)
+	mux.HandleFunc(feedPath, s.feed)
+	mux.HandleFunc(readMarkersPath, s.authenticated(s.readMarkers))
+	mux.HandleFunc(setReadPath, s.authenticated(s.setRead))
+	mux.HandleFunc(atHomePath, s.atHome)
+	mux.HandleFunc(pagePath, s.page)
+	mux.HandleFunc(dataSaverPath, s.page)
+	mux.HandleFunc(reportPath, func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"result": "ok"})
+	})
+
+	// Record every request, so that tests can check what was sent.
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, fmt.Sprintf("%s %s", r.Method, r.URL.Path))
+		s.mu.Unlock()
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// authenticated : Only call a handler if the request has a valid session, with the user it is for.
+func (s *Server) authenticated(h func(w http.ResponseWriter, r *http.Request, username string)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		s.mu.Lock()
+		username, ok := s.sessions[token]
+		s.mu.Unlock()
+		if !ok {
+			writeError(w, http.StatusUnauthorized, "unauthorized_http_exception", "Token is not valid")
+			return
+		}
+		h(w, r, username)
+	}
+}
+
+// newTokens : Give out an access and a refresh token for a user of a client. The lock must be held.
+func (s *Server) newTokens(username, clientID string) map[string]interface{} {
+	s.tokens++
+	access := fmt.Sprintf("access-%d", s.tokens)
+	refresh := fmt.Sprintf("refresh-%d", s.tokens)
+	s.sessions[access] = username
+	s.refresh[refresh] = session{username: username, clientID: clientID}
+	return map[string]interface{}{
+		"access_token":       access,
+		"refresh_token":      refresh,
+		"token_type":         "Bearer",
+		"expires_in":         s.TokenLifetime,
+		"refresh_expires_in": 7776000,
+	}
+}
+
+// token : Give out tokens for the password and refresh token grants, as the authentication server does.
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clientID := r.PostForm.Get("client_id")
+	secret, ok := s.clients[clientID]
+	if !ok || secret != r.PostForm.Get("client_secret") {
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client", "Invalid client or Invalid client credentials")
+		return
+	}
+
+	switch r.PostForm.Get("grant_type") {
+	case "password":
+		username := r.PostForm.Get("username")
+		if password, ok := s.users[username]; !ok || password != r.PostForm.Get("password") {
+			writeOAuthError(w, http.StatusUnauthorized, "invalid_grant", "Invalid user credentials")
+			return
+		}
+		writeJSON(w, http.StatusOK, s.newTokens(username, clientID))
+	case "refresh_token":
+		token := r.PostForm.Get("refresh_token")
+		session, ok := s.refresh[token]
+		if !ok || session.clientID != clientID {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "Token is not active")
+			return
+		}
+		delete(s.refresh, token)
+		writeJSON(w, http.StatusOK, s.newTokens(session.username, clientID))
+	default:
+		writeOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "Unsupported grant_type")
+	}
+}
+
+// logout : End the session of a refresh token.
+func (s *Server) logout(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if secret, ok := s.clients[r.PostForm.Get("client_id")]; !ok || secret != r.PostForm.Get("client_secret") {
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client", "Invalid client or Invalid client credentials")
+		return
+	}
+	if _, ok := s.refresh[r.PostForm.Get("refresh_token")]; !ok {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "Session not active")
+		return
+	}
+	delete(s.refresh, r.PostForm.Get("refresh_token"))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) user(w http.ResponseWriter, _ *http.Request, username string) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"result":   "ok",
+		"response": "entity",
+		"data": map[string]interface{}{
+			"id":         "user-" + username,
+			"type":       "user",
+			"attributes": map[string]interface{}{"username": username, "roles": []string{"ROLE_USER"}},
+		},
+	})
+}
+
+func (s *Server) followedList(w http.ResponseWriter, r *http.Request, username string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var followed []*Manga
+	for _, manga := range s.manga {
+		if s.follows[username][manga.ID] {
+			followed = append(followed, manga)
+		}
+	}
+	writeMangaList(w, r, followed)
+}
+
+func (s *Server) followed(w http.ResponseWriter, r *http.Request, username string) {
+	if !s.Follows(username, r.PathValue("id")) {
+		writeError(w, http.StatusNotFound, "not_found_http_exception", "Manga is not followed")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"result": "ok"})
+}
+
+// setFollow : Follow or unfollow a manga.
+func (s *Server) setFollow(follow bool) func(w http.ResponseWriter, r *http.Request, username string) {
+	return func(w http.ResponseWriter, r *http.Request, username string) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.findManga(r.PathValue("id")) == nil {
+			writeError(w, http.StatusNotFound, "not_found_http_exception", "Manga does not exist")
+			return
+		}
+		setFlag(s.follows, username, r.PathValue("id"), follow)
+		writeJSON(w, http.StatusOK, map[string]interface{}{"result": "ok"})
+	}
+}
+
+func (s *Server) mangaList(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	ids := q["ids[]"]
+	title := strings.ToLower(q.Get("title"))
+	ratings := q["contentRating[]"]
+	if len(ratings) == 0 {
+		ratings = []string{"safe", "suggestive", "erotica"}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var list []*Manga
+	for _, manga := range s.manga {
+		if (len(ids) == 0 || contains(ids, manga.ID)) &&
+			strings.Contains(strings.ToLower(manga.Title), title) &&
+			contains(ratings, manga.ContentRating) {
+			list = append(list, manga)
+		}
+	}
+	writeMangaList(w, r, list)
+}
+
+func (s *Server) feed(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	langs := q["translatedLanguage[]"]
+	excluded := q["excludedGroups[]"]
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.findManga(r.PathValue("id")) == nil {
+		writeError(w, http.StatusNotFound, "not_found_http_exception", "Manga does not exist")
+		return
+	}
+	var chapters []interface{}
+	for _, chapter := range s.chapters {
+		if chapter.MangaID == r.PathValue("id") &&
+			(len(langs) == 0 || contains(langs, chapter.Language)) &&
+			(chapter.GroupID == "" || !contains(excluded, chapter.GroupID)) {
+			chapters = append(chapters, chapterJSON(chapter))
+		}
+	}
+	writeList(w, r, chapters, maxChapterLimit)
+}
+
+func (s *Server) readMarkers(w http.ResponseWriter, r *http.Request, username string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	read := []string{}
+	for _, chapter := range s.chapters {
+		if chapter.MangaID == r.PathValue("id") && s.read[username][chapter.ID] {
+			read = append(read, chapter.ID)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"result": "ok", "data": read})
+}
+
+func (s *Server) setRead(w http.ResponseWriter, r *http.Request, username string) {
+	var body struct {
+		Read   []string `json:"chapterIdsRead"`
+		Unread []string `json:"chapterIdsUnread"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "validation_exception", err.Error())
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range body.Read {
+		setFlag(s.read, username, id, true)
+	}
+	for _, id := range body.Unread {
+		setFlag(s.read, username, id, false)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"result": "ok"})
+}
+
+func (s *Server) atHome(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.RateLimit > 0 {
+		s.RateLimit--
+		writeError(w, http.StatusTooManyRequests, "rate_limit_exceeded", "Too many requests")
+		return
+	}
+	chapter := s.findChapter(r.PathValue("id"))
+	if chapter == nil {
+		writeError(w, http.StatusNotFound, "not_found_http_exception", "Chapter does not exist")
+		return
+	}
+	pages := append([]string{}, chapter.Pages...)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"result":  "ok",
+		"baseUrl": s.URL,
+		"chapter": map[string]interface{}{"hash": chapter.ID, "data": pages, "dataSaver": pages},
+	})
+}
+
+func (s *Server) page(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	chapter := s.findChapter(r.PathValue("hash"))
+	if chapter == nil || !contains(chapter.Pages, r.PathValue("page")) {
+		http.NotFound(w, r)
+		return
+	}
+	_, _ = w.Write(PageData(chapter.ID, r.PathValue("page")))
+}
+
+// findManga : Get a manga by its ID, or nil if there is none. The lock must be held.
+func (s *Server) findManga(id string) *Manga {
+	for _, manga := range s.manga {
+		if manga.ID == id {
+			return manga
+		}
+	}
+	return nil
+}
+
+// findChapter : Get a chapter by its ID, or nil if there is none. The lock must be held.
+func (s *Server) findChapter(id string) *Chapter {
+	for _, chapter := range s.chapters {
+		if chapter.ID == id {
+			return chapter
+		}
+	}
+	return nil
+}
+
+// writeMangaList : Write a page of a list of manga, as given by the limit and offset of the request.
+func writeMangaList(w http.ResponseWriter, r *http.Request, list []*Manga) {
+	data := make([]interface{}, len(list))
+	for i, manga := range list {
+		data[i] = mangaJSON(manga)
+	}
+	writeList(w, r, data, maxListLimit)
+}
+
+// writeList : Write a page of a list, as given by the limit and offset of the request.
+func writeList(w http.ResponseWriter, r *http.Request, data []interface{}, maxLimit int) {
+	limit, offset := defaultListLimit, 0
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil {
+		limit = l
+	}
+	if o, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil {
+		offset = o
+	}
+	if limit < 0 || limit > maxLimit || offset < 0 {
+		writeError(w, http.StatusBadRequest, "validation_exception", "limit or offset is out of range")
+		return
+	}
+
+	page := []interface{}{}
+	if offset < len(data) {
+		page = data[offset:min(offset+limit, len(data))]
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"result":   "ok",
+		"response": "collection",
+		"data":     page,
+		"limit":    limit,
+		"offset":   offset,
+		"total":    len(data),
+	})
+}
+
+// writeError : Write an error, as the API does.
+func writeError(w http.ResponseWriter, status int, title, detail string) {
+	writeJSON(w, status, map[string]interface{}{
+		"result": "error",
+		"errors": []map[string]interface{}{{"status": status, "title": title, "detail": detail}},
+	})
+}
+
+// writeOAuthError : Write an error of the authentication server.
+func writeOAuthError(w http.ResponseWriter, status int, code, description string) {
+	writeJSON(w, status, map[string]interface{}{"error": code, "error_description": description})
+}
+
+// writeJSON : Write a response as JSON.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// contains : Check whether a list has an item.
+func contains(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package fakedex
+
+// mangaJSON : A manga as the API gives it, including its author.
+func mangaJSON(manga *Manga) map[string]interface{} {
+	tags := make([]interface{}, len(manga.Tags))
+	for i, tag := range manga.Tags {
+		tags[i] = map[string]interface{}{
+			"id":         "tag-" + tag,
+			"type":       "tag",
+			"attributes": map[string]interface{}{"name": map[string]string{"en": tag}, "group": "genre"},
+		}
+	}
+	var relationships []interface{}
+	if manga.Author != "" {
+		relationships = append(relationships, map[string]interface{}{
+			"id":         "author-" + manga.Author,
+			"type":       "author",
+			"attributes": map[string]interface{}{"name": manga.Author},
+		})
+	}
+	return map[string]interface{}{
+		"id":   manga.ID,
+		"type": "manga",
+		"attributes": map[string]interface{}{
+			"title":         map[string]string{"en": manga.Title},
+			"altTitles":     []interface{}{},
+			"description":   map[string]string{"en": manga.Description},
+			"status":        manga.Status,
+			"contentRating": manga.ContentRating,
+			"tags":          tags,
+			"updatedAt":     manga.UpdatedAt,
+		},
+		"relationships": relationships,
+	}
+}
+
+// chapterJSON : A chapter as the API gives it, including its scanlation group.
+func chapterJSON(chapter *Chapter) map[string]interface{} {
+	relationships := []interface{}{
+		map[string]interface{}{"id": chapter.MangaID, "type": "manga"},
+	}
+	if chapter.GroupID != "" {
+		relationships = append(relationships, map[string]interface{}{
+			"id":         chapter.GroupID,
+			"type":       "scanlation_group",
+			"attributes": map[string]interface{}{"name": chapter.Group},
+		})
+	}
+	return map[string]interface{}{
+		"id":   chapter.ID,
+		"type": "chapter",
+		"attributes": map[string]interface{}{
+			"title":              chapter.Title,
+			"volume":             nullable(chapter.Volume),
+			"chapter":            nullable(chapter.Number),
+			"translatedLanguage": chapter.Language,
+			"pages":              len(chapter.Pages),
+			"publishAt":          chapter.PublishAt,
+		},
+		"relationships": relationships,
+	}
+}
+
+// nullable : Give an empty string as null, as the API does for values that are not set.
+func nullable(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,235 @@
+// Package fakedex : An in-process fake of the MangaDex API, for testing the app without contacting MangaDex.
+// It keeps users, manga, chapters, follows and read markers in memory, and serves chapter pages as MangaDex@Home
+// would. Clients are pointed at it with Transport, or by replacing the default transport with Intercept.
+package fakedex
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Hosts : The MangaDex hosts that requests are sent to the fake for, instead of the real ones.
+var Hosts = []string{"api.mangadex.org", "api.mangadex.network", "auth.mangadex.org"}
+
+// Manga : A manga served by the fake.
+type Manga struct {
+	ID            string
+	Title         string
+	Description   string
+	Author        string
+	Status        string // Such as mangodex.OngoingStatus. Defaults to ongoing.
+	ContentRating string // Such as mangodex.Safe. Defaults to safe.
+	Tags          []string
+	UpdatedAt     string // In RFC 3339, such as 2021-12-31T09:35:27+00:00.
+}
+
+// Chapter : A chapter of a manga served by the fake.
+type Chapter struct {
+	ID        string
+	MangaID   string
+	Number    string // Empty for a oneshot.
+	Volume    string // Empty if the chapter does not belong to a volume.
+	Title     string
+	Language  string // Defaults to en.
+	GroupID   string
+	Group     string // The name of the scanlation group.
+	PublishAt string // In RFC 3339, such as 2021-12-31T09:35:27+00:00.
+	Pages     []string
+}
+
+// Server : The fake MangaDex API. It is safe to use from several goroutines.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	users    map[string]string  // Passwords, by username.
+	clients  map[string]string  // Secrets of personal API clients, by client ID.
+	sessions map[string]string  // Usernames, by access token.
+	refresh  map[string]session // Sessions, by refresh token.
+	tokens   int                // The number of tokens given out, so that each one is different.
+	manga    []*Manga
+	chapters []*Chapter
+	follows  map[string]map[string]bool // The IDs of followed manga, by username.
+	read     map[string]map[string]bool // The IDs of read chapters, by username.
+	requests []string                   // Every request received, as `METHOD /path`.
+
+	// TokenLifetime : How many seconds access tokens are said to be valid for. They are valid until
+	// ExpireSessions is called, whatever it is.
+	TokenLifetime int
+
+	// RateLimit : The number of requests for MangaDex@Home servers to refuse as rate limited, before answering
+	// them again.
+	RateLimit int
+}
+
+// NewServer : Start a fake MangaDex API. Close it when done.
+func NewServer() *Server {
+	s := &Server{
+		users:    map[string]string{},
+		clients:  map[string]string{},
+		sessions: map[string]string{},
+		refresh:  map[string]session{},
+		follows:  map[string]map[string]bool{},
+		read:     map[string]map[string]bool{},
+	}
+	s.TokenLifetime = 900
+	s.Server = httptest.NewServer(s.routes())
+	return s
+}
+
+// AddUser : Add a user who can log in with a password.
+func (s *Server) AddUser(username, password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[username] = password
+}
+
+// AddClient : Add a personal API client that users can log in with.
+func (s *Server) AddClient(clientID, secret string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clients[clientID] = secret
+}
+
+// RevokeClient : Delete a personal API client, as its owner can. Its sessions can no longer be refreshed.
+func (s *Server) RevokeClient(clientID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.clients, clientID)
+}
+
+// AddManga : Add manga. They are listed in the order they are added.
+func (s *Server) AddManga(manga ...Manga) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range manga {
+		m := manga[i]
+		if m.Status == "" {
+			m.Status = "ongoing"
+		}
+		if m.ContentRating == "" {
+			m.ContentRating = "safe"
+		}
+		s.manga = append(s.manga, &m)
+	}
+}
+
+// AddChapters : Add chapters. They are listed in the order they are added.
+func (s *Server) AddChapters(chapters ...Chapter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range chapters {
+		c := chapters[i]
+		if c.Language == "" {
+			c.Language = "en"
+		}
+		s.chapters = append(s.chapters, &c)
+	}
+}
+
+// Follow : Make a user follow a manga.
+func (s *Server) Follow(username, mangaID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	setFlag(s.follows, username, mangaID, true)
+}
+
+// Follows : Check whether a user follows a manga.
+func (s *Server) Follows(username, mangaID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.follows[username][mangaID]
+}
+
+// MarkRead : Mark a chapter as read by a user.
+func (s *Server) MarkRead(username, chapterID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	setFlag(s.read, username, chapterID, true)
+}
+
+// IsRead : Check whether a user has read a chapter.
+func (s *Server) IsRead(username, chapterID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.read[username][chapterID]
+}
+
+// ExpireSessions : End every session, as if their access tokens expired. Refresh tokens can still be used.
+func (s *Server) ExpireSessions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions = map[string]string{}
+}
+
+// Requests : Get every request received so far, as `METHOD /path`.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// PageData : Get the data served for a page of a chapter, to compare downloads against.
+func PageData(chapterID, page string) []byte {
+	return []byte(fmt.Sprintf("fake page %s/%s", chapterID, page))
+}
+
+// Transport : Get a transport that sends requests for MangaDex to the fake, and every other request to base.
+// If base is nil, the default transport is used.
+func (s *Server) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	target, _ := url.Parse(s.URL)
+	return &transport{target: target, base: base}
+}
+
+// Intercept : Send every request for MangaDex made with the default transport to the fake, as clients such as
+// mangodex.DexClient use it. Returns a function that restores the default transport.
+func (s *Server) Intercept() (restore func()) {
+	original := http.DefaultTransport
+	http.DefaultTransport = s.Transport(original)
+	return func() {
+		http.DefaultTransport = original
+	}
+}
+
+// session : A session that can be refreshed.
+type session struct {
+	username string
+	clientID string
+}
+
+// transport : Rewrites requests for MangaDex to a fake.
+type transport struct {
+	target *url.URL
+	base   http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for _, host := range Hosts {
+		if strings.EqualFold(req.URL.Hostname(), host) {
+			req = req.Clone(req.Context())
+			req.URL.Scheme, req.URL.Host = t.target.Scheme, t.target.Host
+			req.Host = t.target.Host
+			break
+		}
+	}
+	return t.base.RoundTrip(req)
+}
+
+// setFlag : Set or clear a flag of a user, such as whether they follow a manga.
+func setFlag(flags map[string]map[string]bool, username, id string, value bool) {
+	if flags[username] == nil {
+		flags[username] = map[string]bool{}
+	}
+	if value {
+		flags[username][id] = true
+	} else {
+		delete(flags[username], id)
+	}
+}
@@ -0,0 +1,569 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/darylhjd/mangodex"
+
+	"github.com/darylhjd/mangadesk/app/backend"
+	"github.com/darylhjd/mangadesk/app/core"
+)
+
+// Exit codes of commands.
+const (
+	exitOK       = 0
+	exitError    = 1 // The command failed, such as when MangaDex cannot be reached.
+	exitUsage    = 2 // The command was not used correctly.
+	exitLoggedIn = 3 // The command needs the user to be logged in.
+)
+
+// mangaRecord : A manga, as printed by commands.
+type mangaRecord struct {
+	ID         string   `json:"id"`
+	Title      string   `json:"title"`
+	Status     string   `json:"status"`
+	LastUpdate string   `json:"lastUpdate"`
+	Tags       []string `json:"tags"`
+}
+
+// chapterRecord : A chapter, as printed by commands.
+type chapterRecord struct {
+	ID         string `json:"id"`
+	Chapter    string `json:"chapter"`
+	Volume     string `json:"volume"`
+	Title      string `json:"title"`
+	Language   string `json:"language"`
+	Group      string `json:"group"`
+	Published  string `json:"published"`
+	Read       *bool  `json:"read,omitempty"` // Nil if the user is not logged in, as it is not known.
+	Downloaded bool   `json:"downloaded"`
+	Folder     string `json:"folder,omitempty"` // Where the chapter was saved, for downloads.
+	Error      string `json:"error,omitempty"`  // Why the chapter could not be downloaded, for downloads.
+}
+
+// searchCommand : Search for manga by title.
+func searchCommand(args []string) int {
+	fs := newFlagSet()
+	asJSON := fs.Bool("json", false, "")
+	limit := fs.Int("limit", 10, "")
+	terms, err := parseCommand(fs, args)
+	if err != nil || len(terms) == 0 {
+		return usageError(err, "search needs a title to search for")
+	}
+	if *limit < 1 || *limit > 100 {
+		return usageError(nil, "--limit must be between 1 and 100")
+	}
+	s, code, ok := startHeadless()
+	if !ok {
+		return code
+	}
+	defer core.App.ShutdownHeadless()
+
+	term := strings.Join(terms, " ")
+	list, err := s.Feed.Search(context.Background(), term, core.App.Config.ExplicitContent, *limit, 0)
+	if err != nil {
+		return commandError("searching for manga", err)
+	}
+	return printManga(*asJSON, list.Data)
+}
+
+// followsCommand : List the manga that the user follows.
+func followsCommand(args []string) int {
+	fs := newFlagSet()
+	asJSON := fs.Bool("json", false, "")
+	if rest, err := parseCommand(fs, args); err != nil || len(rest) != 0 {
+		return usageError(err, "follows takes no arguments")
+	}
+	s, code, ok := startHeadless()
+	if !ok {
+		return code
+	}
+	defer core.App.ShutdownHeadless()
+	if code, ok := logIn(s, true); !ok {
+		return code
+	}
+
+	const pageSize = 100
+	var followed []mangodex.Manga
+	for offset := 0; ; offset += pageSize {
+		list, err := s.Feed.Followed(context.Background(), pageSize, offset)
+		if err != nil {
+			return commandError("getting followed manga", err)
+		}
+		followed = append(followed, list.Data...)
+		if offset+pageSize >= list.Total {
+			break
+		}
+	}
+	return printManga(*asJSON, followed)
+}
+
+// logoutCommand : Log out, ending the session on MangaDex and deleting the stored one. With --all, every secret the
+// app stored is deleted instead, wherever it was stored, without needing the passphrase of the secret file.
+func logoutCommand(args []string) int {
+	fs := newFlagSet()
+	all := fs.Bool("all", false, "")
+	if rest, err := parseCommand(fs, args); err != nil || len(rest) != 0 {
+		return usageError(err, "logout takes no arguments")
+	}
+	if *all {
+		if err := core.WipeSecrets(); err != nil {
+			return commandError("deleting stored secrets", err)
+		}
+		fmt.Println("Deleted every stored secret.")
+		return exitOK
+	}
+
+	s, code, ok := startHeadless()
+	if !ok {
+		return code
+	}
+	defer core.App.ShutdownHeadless()
+	ctx := context.Background()
+	err := s.Auth.Resume(ctx)
+	switch {
+	case errors.Is(err, backend.ErrNoSession):
+		fmt.Println("You are not logged in.")
+		return exitOK
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", err.Error())
+	}
+	if err = s.Auth.Logout(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", err.Error())
+	}
+	fmt.Println("Logged out.")
+	return exitOK
+}
+
+// chaptersCommand : List the chapters of a manga in the user's languages.
+func chaptersCommand(args []string) int {
+	fs := newFlagSet()
+	asJSON := fs.Bool("json", false, "")
+	langs := fs.String("lang", "", "")
+	expr := fs.String("chapters", "", "")
+	ids, err := parseCommand(fs, args)
+	if err != nil || len(ids) != 1 {
+		return usageError(err, "chapters needs one manga ID")
+	}
+	chapterRange, code, ok := parseRange(*expr, false)
+	if !ok {
+		return code
+	}
+	s, code, ok := startHeadless()
+	if !ok {
+		return code
+	}
+	defer core.App.ShutdownHeadless()
+	if code, ok := setLanguages(*langs); !ok {
+		return code
+	}
+	if code, ok := logIn(s, false); !ok {
+		return code
+	}
+
+	manga, _, records, code, ok := findChapters(s, ids[0], chapterRange, s.Auth.LoggedIn())
+	if !ok {
+		return code
+	}
+	if *asJSON {
+		return printJSON(records)
+	}
+	rows := make([][]string, len(records))
+	for i, record := range records {
+		rows[i] = []string{record.Chapter, record.Volume, record.Title, record.Language, record.Group,
+			record.Published, yesNo(record.Read), yesNo(&record.Downloaded)}
+	}
+	fmt.Println(manga.GetTitle("en"))
+	return printTable([]string{"CHAPTER", "VOLUME", "TITLE", "LANGUAGE", "GROUP", "PUBLISHED", "READ", "DOWNLOADED"},
+		rows)
+}
+
+// downloadCommand : Download chapters of a manga. Chapters from preferred groups are downloaded instead of their
+// duplicates, as when downloading from the app.
+func downloadCommand(args []string) int {
+	fs := newFlagSet()
+	asJSON := fs.Bool("json", false, "")
+	langs := fs.String("lang", "", "")
+	expr := fs.String("chapters", "", "")
+	ids, err := parseCommand(fs, args)
+	if err != nil || len(ids) != 1 {
+		return usageError(err, "download needs one manga ID")
+	}
+	chapterRange, code, ok := parseRange(*expr, true)
+	if !ok {
+		return code
+	}
+	s, code, ok := startHeadless()
+	if !ok {
+		return code
+	}
+	defer core.App.ShutdownHeadless()
+	if code, ok := setLanguages(*langs); !ok {
+		return code
+	}
+	if code, ok := logIn(s, false); !ok {
+		return code
+	}
+
+	manga, chapters, records, code, ok := findChapters(s, ids[0], chapterRange, s.Auth.LoggedIn())
+	if !ok {
+		return code
+	}
+	preferred := s.Library.PreferredChapters(chapters)
+	if err = s.Downloads.CheckFolder(); err != nil {
+		return commandError("checking the download folder", err)
+	}
+
+	var downloaded []*chapterRecord
+	failed := 0
+	for n, i := range preferred {
+		chapter, record := chapters[i], records[i]
+		fmt.Fprintf(os.Stderr, "Downloading chapter %s [%s] (%d of %d)...\n",
+			record.Chapter, record.Language, n+1, len(preferred))
+		err := s.Downloads.Save(manga, chapter, func(wait time.Duration) {
+			fmt.Fprintf(os.Stderr, "Rate limited, waiting %s before trying again...\n", wait)
+		})
+		if err != nil {
+			core.LogErrorf("Error saving %s - Chapter: %s - %s\n", manga.GetTitle("en"), record.Chapter, err.Error())
+			record.Error = err.Error()
+			failed++
+		} else {
+			record.Downloaded = true
+			record.Folder = s.Downloads.Folder(manga, chapter)
+		}
+		downloaded = append(downloaded, record)
+	}
+
+	code = exitOK
+	if failed != 0 {
+		fmt.Fprintf(os.Stderr, "Failed to download %d of %d chapter(s).\n", failed, len(downloaded))
+		code = exitError
+	}
+	if *asJSON {
+		if c := printJSON(downloaded); c != exitOK {
+			return c
+		}
+		return code
+	}
+	rows := make([][]string, len(downloaded))
+	for i, record := range downloaded {
+		result := record.Folder
+		if record.Error != "" {
+			result = "error: " + record.Error
+		}
+		rows[i] = []string{record.Chapter, record.Language, record.Group, result}
+	}
+	if c := printTable([]string{"CHAPTER", "LANGUAGE", "GROUP", "RESULT"}, rows); c != exitOK {
+		return c
+	}
+	return code
+}
+
+// markReadCommand : Mark chapters of a manga as read, or as unread.
+func markReadCommand(args []string) int {
+	fs := newFlagSet()
+	asJSON := fs.Bool("json", false, "")
+	langs := fs.String("lang", "", "")
+	expr := fs.String("chapters", "", "")
+	unread := fs.Bool("unread", false, "")
+	ids, err := parseCommand(fs, args)
+	if err != nil || len(ids) != 1 {
+		return usageError(err, "mark-read needs one manga ID")
+	}
+	chapterRange, code, ok := parseRange(*expr, true)
+	if !ok {
+		return code
+	}
+	s, code, ok := startHeadless()
+	if !ok {
+		return code
+	}
+	defer core.App.ShutdownHeadless()
+	if code, ok := setLanguages(*langs); !ok {
+		return code
+	}
+	if code, ok := logIn(s, true); !ok {
+		return code
+	}
+
+	manga, _, records, code, ok := findChapters(s, ids[0], chapterRange, true)
+	if !ok {
+		return code
+	}
+	chapterIDs := make([]string, len(records))
+	for i, record := range records {
+		chapterIDs[i] = record.ID
+		read := !*unread
+		record.Read = &read
+	}
+	if len(chapterIDs) != 0 {
+		read, unRead := chapterIDs, []string(nil)
+		if *unread {
+			read, unRead = nil, chapterIDs
+		}
+		if err = s.Library.SetReadMarkers(context.Background(), manga.ID, read, unRead); err != nil {
+			return commandError("updating read markers", err)
+		}
+	}
+
+	if *asJSON {
+		return printJSON(records)
+	}
+	status := "read"
+	if *unread {
+		status = "unread"
+	}
+	fmt.Printf("Marked %d chapter(s) of %s as %s.\n", len(records), manga.GetTitle("en"), status)
+	return exitOK
+}
+
+// findChapters : Get a manga, and those of its chapters in the user's languages that are in a chapter range.
+// Chapters from blocked groups are left out. Read markers are only fetched if the user is logged in.
+// The records of the chapters are returned along with them, in the same order.
+func findChapters(s *backend.Services, id string, chapterRange *backend.ChapterRange, loggedIn bool) (
+	*mangodex.Manga, []*mangodex.Chapter, []*chapterRecord, int, bool) {
+	ctx := context.Background()
+	manga, err := s.Library.GetManga(ctx, id)
+	if err != nil {
+		return nil, nil, nil, commandError("getting manga", err), false
+	}
+	chapters, err := s.Library.Chapters(ctx, manga)
+	if err != nil {
+		return nil, nil, nil, commandError("getting chapters", err), false
+	}
+
+	var markers map[string]struct{}
+	if loggedIn {
+		if markers, err = s.Library.ReadMarkers(ctx, manga.ID); err != nil {
+			return nil, nil, nil, commandError("getting read markers", err), false
+		}
+	}
+
+	var matched []*mangodex.Chapter
+	var records []*chapterRecord
+	for i := range chapters {
+		chapter := &chapters[i]
+		_, group := backend.ScanGroup(chapter)
+		record := &chapterRecord{
+			ID:        chapter.ID,
+			Chapter:   chapter.GetChapterNum(),
+			Volume:    backend.ChapterVolume(chapter),
+			Title:     chapter.GetTitle(),
+			Language:  chapter.Attributes.TranslatedLanguage,
+			Group:     group,
+			Published: backend.PublishDate(chapter),
+		}
+		record.Downloaded = s.Downloads.IsDownloaded(manga, chapter)
+		if markers != nil {
+			_, read := markers[chapter.ID]
+			record.Read = &read
+		}
+
+		info := &backend.ChapterInfo{
+			Volume:     record.Volume,
+			Language:   record.Language,
+			Group:      group,
+			Read:       record.Read != nil && *record.Read,
+			Downloaded: record.Downloaded,
+		}
+		if num := chapter.Attributes.Chapter; num != nil {
+			info.Number = *num
+		}
+		if chapterRange == nil || chapterRange.Matches(info) {
+			matched = append(matched, chapter)
+			records = append(records, record)
+		}
+	}
+	return manga, matched, records, exitOK, true
+}
+
+// printManga : Print a list of manga, as JSON or as a table.
+func printManga(asJSON bool, list []mangodex.Manga) int {
+	records := make([]mangaRecord, len(list))
+	rows := make([][]string, len(list))
+	for i := range list {
+		manga := &list[i]
+		record := mangaRecord{
+			ID:         manga.ID,
+			Title:      manga.GetTitle("en"),
+			LastUpdate: backend.LastUpdate(manga),
+			Tags:       []string{},
+		}
+		if manga.Attributes.Status != nil {
+			record.Status = *manga.Attributes.Status
+		}
+		for _, tag := range manga.Attributes.Tags {
+			record.Tags = append(record.Tags, tag.GetName("en"))
+		}
+		records[i] = record
+		rows[i] = []string{record.ID, record.Title, record.Status, record.LastUpdate}
+	}
+	if asJSON {
+		return printJSON(records)
+	}
+	return printTable([]string{"ID", "TITLE", "STATUS", "LAST UPDATE"}, rows)
+}
+
+// printJSON : Print the result of a command as JSON.
+func printJSON(v interface{}) int {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return commandError("printing result", err)
+	}
+	return exitOK
+}
+
+// printTable : Print the result of a command as a table.
+func printTable(header []string, rows [][]string) int {
+	if len(rows) == 0 {
+		fmt.Println("No results.")
+		return exitOK
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	if err := w.Flush(); err != nil {
+		return commandError("printing result", err)
+	}
+	return exitOK
+}
+
+// yesNo : Show a flag in a table. Flags that are not known are shown as `-`.
+func yesNo(b *bool) string {
+	switch {
+	case b == nil:
+		return "-"
+	case *b:
+		return "Y"
+	}
+	return ""
+}
+
+// newFlagSet : Create the flags for a command. Errors are printed along with the usage by usageError.
+func newFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("mangadesk", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return fs
+}
+
+// parseCommand : Parse the flags of a command, which may come before or after its arguments, such as
+// `download <manga-id> --chapters 1-10`. Returns the arguments.
+func parseCommand(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if args = fs.Args(); len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// parseRange : Parse the --chapters flag of a command, which must be given if it is required.
+func parseRange(expr string, required bool) (*backend.ChapterRange, int, bool) {
+	if expr == "" {
+		if required {
+			return nil, usageError(nil, "--chapters is required, such as --chapters 1-10"), false
+		}
+		return nil, exitOK, true
+	}
+	chapterRange, err := backend.ParseChapterRange(expr)
+	if err != nil {
+		return nil, usageError(nil, fmt.Sprintf("--chapters: %s", err.Error())), false
+	}
+	return chapterRange, exitOK, true
+}
+
+// setLanguages : Use the languages given with --lang, if any, instead of the configured ones.
+func setLanguages(langs string) (int, bool) {
+	if langs == "" {
+		return exitOK, true
+	}
+	opts := &core.Options{Languages: strings.Split(langs, ",")}
+	if err := opts.Validate(); err != nil {
+		return usageError(nil, fmt.Sprintf("--lang: %s", err.Error())), false
+	}
+	core.App.Config.Languages = opts.Languages
+	return exitOK, true
+}
+
+// startHeadless : Set up the app to run a command without the terminal interface, and create the services that
+// the command uses. Problems with the configuration are printed as warnings, as the defaults are used for them.
+func startHeadless() (*backend.Services, int, bool) {
+	core.App = &core.MangaDesk{}
+	problems, err := core.App.InitialiseHeadless()
+	if core.App.Config == nil {
+		fmt.Fprintf(os.Stderr, "Unable to set up logging: %s\n", err.Error())
+		return nil, exitError, false
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: the configuration file cannot be used, so the defaults are used: %s\n",
+			err.Error())
+	}
+	for _, problem := range problems {
+		fmt.Fprintf(os.Stderr, "Warning: %s: %s\n", core.ConfigFile(), problem.Error())
+	}
+	if err = core.ApplyNetworkSettings(core.App.Config); err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to apply network settings: %s\n", err.Error())
+		return nil, exitError, false
+	}
+	// The configuration does not change while a command runs, other than by its own flags.
+	s := backend.New(mangodex.NewDexClient(), core.HTTPClient(), backend.StaticConfig(core.App.Config),
+		core.StoredCredentials{Secrets: core.Secrets()})
+	core.AuthoriseRequests(s.Auth.Authorise)
+	return s, exitOK, true
+}
+
+// logIn : Resume the session that the user logged in with in the app, unless they use guest mode.
+// If the command needs the user to be logged in, it fails if they are not.
+func logIn(s *backend.Services, required bool) (int, bool) {
+	if core.App.Config.GuestMode {
+		if required {
+			fmt.Fprintln(os.Stderr, "This command needs you to be logged in, but guest mode is on.")
+			return exitLoggedIn, false
+		}
+		return exitOK, true
+	}
+	err := s.Auth.Resume(context.Background())
+	switch {
+	case err == nil:
+		return exitOK, true
+	case required:
+		fmt.Fprintf(os.Stderr, "This command needs you to be logged in: %s\n", err.Error())
+		return exitLoggedIn, false
+	case !errors.Is(err, backend.ErrNoSession):
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", err.Error())
+	}
+	return exitOK, true
+}
+
+// usageError : Print why a command was not used correctly, along with the usage. Returns the exit code.
+func usageError(err error, msg string) int {
+	if err != nil && !errors.Is(err, flag.ErrHelp) {
+		msg = err.Error()
+	}
+	fmt.Fprintf(os.Stderr, "%s\n\n%s", msg, usage)
+	return exitUsage
+}
+
+// commandError : Print why a command failed. Returns the exit code.
+func commandError(doing string, err error) int {
+	core.LogErrorf("Error %s: %s\n", doing, err.Error())
+	fmt.Fprintf(os.Stderr, "Error %s: %s\n", doing, err.Error())
+	return exitError
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/darylhjd/mangadesk/app/core"
+)
+
+// usage : How to use the commands that can be given on the command line.
+const usage = `Usage:
+  mangadesk [options]                Start the app.
+  mangadesk [options] config check   Check the configuration file for problems.
+  mangadesk [options] search TITLE [--limit N]
+                                     Search for manga by title.
+  mangadesk [options] chapters MANGA-ID [--chapters RANGE]
+                                     List the chapters of a manga.
+  mangadesk [options] download MANGA-ID --chapters RANGE
+                                     Download chapters of a manga.
+  mangadesk [options] follows        List the manga you follow.
+  mangadesk [options] mark-read MANGA-ID --chapters RANGE [--unread]
+                                     Mark chapters of a manga as read, or as unread.
+  mangadesk [options] logout [--all] Log out, and delete your stored session. With --all, delete every
+                                     secret the app stored for every profile, such as when you forgot
+                                     your passphrase.
+
+Commands print a table, or JSON with --json. Commands about chapters take --lang CODES to use those languages.
+RANGE is a chapter range, such as 1-10 or "vol:3 unread". The exit code is 0 on success, 1 on failure,
+2 if the command is not used correctly, and 3 if the command needs you to be logged in and you are not.
+
+Options:
+  --config FILE        Use this configuration file, in JSON, TOML or YAML.   MANGADESK_CONFIG
+  --config-dir DIR     Keep the profiles, theme and logs in this folder      MANGADESK_CONFIG_DIR
+                       instead of the default one.
+  --download-dir DIR   Save downloads in this folder.                        MANGADESK_DOWNLOAD_DIR
+  --lang CODES         Languages of chapters, separated by commas.           MANGADESK_LANG
+  --quality QUALITY    Download quality, data or data-saver.                 MANGADESK_QUALITY
+  --guest              Use guest mode. Use --guest=false to log in.          MANGADESK_GUEST
+  --offline            Do not contact MangaDex. This implies --guest.        MANGADESK_OFFLINE
+  --log-level LEVEL    What to log: info, error or off.                      MANGADESK_LOG_LEVEL
+  --profile NAME       Use this profile, with its own login, configuration   MANGADESK_PROFILE
+                       and downloads. It is created if it does not exist.
+  --secret-store STORE Where to keep your credentials: auto, keyring or      MANGADESK_SECRET_STORE
+                       file. With auto, the system keyring is used if there
+                       is one, and a file encrypted with a passphrase
+                       otherwise. The passphrase is asked for when it is     MANGADESK_PASSPHRASE
+                       needed, unless it is given in the environment.
+  --version            Print the version and exit.
+  --help               Print this help and exit.
+
+Options take precedence over their environment variables, which take precedence over the configuration file.
+Options are used while the app is running, but are never saved to the configuration file.
+`
+
+// commands : The commands that can be given on the command line, by name.
+var commands = map[string]func(args []string) int{
+	"config":    configCommand,
+	"search":    searchCommand,
+	"chapters":  chaptersCommand,
+	"download":  downloadCommand,
+	"follows":   followsCommand,
+	"mark-read": markReadCommand,
+	"logout":    logoutCommand,
+}
+
+// RunCommand : Run a command given on the command line, instead of starting the app. Returns the exit code.
+func RunCommand(args []string) int {
+	command, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %q.\n\n%s", args[0], usage)
+		return exitUsage
+	}
+	return command(args[1:])
+}
+
+// configCommand : Run a command about the configuration file.
+func configCommand(args []string) int {
+	if len(args) != 1 || args[0] != "check" {
+		fmt.Fprint(os.Stderr, usage)
+		return exitUsage
+	}
+	return configCheck()
+}
+
+// configCheck : Print every problem with the configuration file. Fails with exitError if there are any problems that
+// are not warnings.
+func configCheck() int {
+	path := core.ConfigFile()
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		fmt.Printf("There is no configuration file at %s, so the defaults are used.\n", path)
+		return exitOK
+	}
+
+	_, problems, err := core.CheckConfiguration()
+	if err != nil {
+		fmt.Printf("%s: %s\n", path, err.Error())
+		fmt.Println("The configuration file cannot be used, so the defaults are used until it is fixed.")
+		return exitError
+	}
+
+	var errorCount, warningCount int
+	for _, problem := range problems {
+		fmt.Printf("%s: %s\n", path, problem.Error())
+		if problem.Warning {
+			warningCount++
+		} else {
+			errorCount++
+		}
+	}
+
+	if errorCount == 0 && warningCount == 0 {
+		fmt.Printf("No problems found in %s.\n", path)
+		return exitOK
+	}
+	fmt.Printf("\nFound %d error(s) and %d warning(s).\n", errorCount, warningCount)
+	if errorCount != 0 {
+		return exitError
+	}
+	return exitOK
+}
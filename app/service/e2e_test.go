@@ -0,0 +1,261 @@
+package service
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/darylhjd/mangodex"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+
+	"github.com/darylhjd/mangadesk/app/core"
+	"github.com/darylhjd/mangadesk/app/internal/fakedex"
+)
+
+const (
+	testMangaID = "a96676e5-8ae2-425e-b549-7f15dd34a6d8"
+	testUser    = "reader"
+	testPwd     = "hunter2"
+	testClient  = "personal-client-reader"
+	testSecret  = "client-secret"
+
+	// waitTimeout : How long to wait for text to be shown on the screen.
+	waitTimeout = 10 * time.Second
+)
+
+// testApp : The app running on a simulated terminal, against a fake MangaDex API.
+type testApp struct {
+	t           *testing.T
+	app         *tview.Application
+	screen      tcell.SimulationScreen
+	fake        *fakedex.Server
+	configDir   string
+	downloadDir string
+}
+
+// startTestApp : Start the app on a simulated terminal, with a fake MangaDex API that has a user, a followed
+// manga and its chapters.
+func startTestApp(t *testing.T) *testApp {
+	t.Helper()
+	fake := fakedex.NewServer()
+	t.Cleanup(fake.Close)
+	fake.AddUser(testUser, testPwd)
+	fake.AddClient(testClient, testSecret)
+	fake.AddManga(
+		fakedex.Manga{ID: testMangaID, Title: "Test Manga", Author: "Someone", Tags: []string{"Comedy"}},
+		fakedex.Manga{ID: "4f3a8e6e-9a27-4c55-9d33-0d3c52f5b0b1", Title: "Other Manga"},
+	)
+	fake.AddChapters(
+		fakedex.Chapter{ID: "c3", MangaID: testMangaID, Number: "3", Volume: "1", Title: "Three",
+			GroupID: "g1", Group: "Group One", Pages: []string{"a.png"}},
+		fakedex.Chapter{ID: "c2", MangaID: testMangaID, Number: "2", Volume: "1", Title: "Two",
+			GroupID: "g1", Group: "Group One", Pages: []string{"a.png", "b.png"}},
+		fakedex.Chapter{ID: "c1", MangaID: testMangaID, Number: "1", Volume: "1", Title: "One",
+			GroupID: "g1", Group: "Group One", Pages: []string{"a.png"}},
+	)
+	fake.Follow(testUser, testMangaID)
+
+	// The options keep the app away from the user's own configuration, keyring and downloads.
+	ta := &testApp{t: t, fake: fake, configDir: t.TempDir(), downloadDir: t.TempDir()}
+	core.SetOptions(&core.Options{ConfigDir: ta.configDir, DownloadDir: ta.downloadDir, LogLevel: "off",
+		SecretStore: "file", Passphrase: "test passphrase"})
+	t.Cleanup(func() {
+		core.SetOptions(&core.Options{})
+	})
+	t.Cleanup(fake.Intercept())
+
+	ta.screen = tcell.NewSimulationScreen("")
+	ta.app = tview.NewApplication().SetScreen(ta.screen)
+	ta.screen.SetSize(160, 50)
+	start(ta.app, mangodex.NewDexClient)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := ta.app.Run(); err != nil {
+			t.Error(err)
+		}
+	}()
+	t.Cleanup(func() {
+		ta.app.Stop()
+		<-done
+		core.App.ShutdownHeadless()
+	})
+	return ta
+}
+
+// keys : Press keys, such as tcell.KeyEnter or tcell.KeyCtrlG.
+func (ta *testApp) keys(keys ...tcell.Key) {
+	for _, key := range keys {
+		mod := tcell.ModNone
+		if key >= tcell.KeyCtrlA && key <= tcell.KeyCtrlZ {
+			mod = tcell.ModCtrl
+		}
+		ta.app.QueueEvent(tcell.NewEventKey(key, 0, mod))
+	}
+}
+
+// typeText : Type text into the input that has focus.
+func (ta *testApp) typeText(text string) {
+	for _, r := range text {
+		ta.app.QueueEvent(tcell.NewEventKey(tcell.KeyRune, r, tcell.ModNone))
+	}
+}
+
+// screenText : Get the text shown on the screen, one line per row.
+func (ta *testApp) screenText() string {
+	text := make(chan string)
+	ta.app.QueueUpdate(func() {
+		cells, width, _ := ta.screen.GetContents()
+		var b strings.Builder
+		for i, cell := range cells {
+			if len(cell.Runes) == 0 {
+				b.WriteRune(' ')
+			} else {
+				b.WriteString(string(cell.Runes))
+			}
+			if (i+1)%width == 0 {
+				b.WriteRune('\n')
+			}
+		}
+		text <- b.String()
+	})
+	return <-text
+}
+
+// waitFor : Wait until the screen shows some text, failing the test if it is not shown in time.
+func (ta *testApp) waitFor(text string) {
+	ta.t.Helper()
+	deadline := time.Now().Add(waitTimeout)
+	for {
+		screen := ta.screenText()
+		if strings.Contains(screen, text) {
+			return
+		}
+		if time.Now().After(deadline) {
+			ta.t.Fatalf("timed out waiting for %q, screen is:\n%s", text, screen)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// logIn : Log in on the login page.
+func (ta *testApp) logIn() {
+	ta.t.Helper()
+	ta.waitFor("Login to MangaDex")
+	ta.typeText(testClient)
+	ta.keys(tcell.KeyTab)
+	ta.typeText(testSecret)
+	ta.keys(tcell.KeyTab)
+	ta.typeText(testUser)
+	ta.keys(tcell.KeyTab)
+	ta.typeText(testPwd)
+	ta.keys(tcell.KeyTab, tcell.KeyTab, tcell.KeyEnter) // Skip Remember Me, and press Login.
+	ta.waitFor("Welcome to MangaDex, " + testUser + "!")
+}
+
+// downloadedPages : Get the contents of every file in the download folder, sorted.
+func (ta *testApp) downloadedPages() []string {
+	ta.t.Helper()
+	var pages []string
+	err := filepath.WalkDir(ta.downloadDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		pages = append(pages, string(data))
+		return err
+	})
+	if err != nil {
+		ta.t.Fatal(err)
+	}
+	sort.Strings(pages)
+	return pages
+}
+
+func TestAppLoginAndDownload(t *testing.T) {
+	ta := startTestApp(t)
+	ta.logIn()
+
+	// The followed manga is listed on the main page.
+	ta.waitFor("Test Manga")
+	ta.keys(tcell.KeyEnter)
+	ta.waitFor("Manga Information")
+	ta.waitFor("Group One")
+
+	// Select chapters 1 and 2, and download them.
+	ta.keys(tcell.KeyCtrlG)
+	ta.waitFor("Select Chapters")
+	ta.typeText("1-2")
+	ta.keys(tcell.KeyEnter)
+	ta.waitFor("Selected 2 chapter(s).")
+	ta.keys(tcell.KeyEnter)
+	ta.waitFor("Download chapter(s)?")
+	ta.keys(tcell.KeyEnter)
+	ta.waitFor("Finished downloading 2 chapter(s) of Test Manga.")
+
+	want := []string{
+		string(fakedex.PageData("c1", "a.png")),
+		string(fakedex.PageData("c2", "a.png")),
+		string(fakedex.PageData("c2", "b.png")),
+	}
+	if got := ta.downloadedPages(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("downloaded pages = %q, want %q", got, want)
+	}
+}
+
+func TestAppGuestSearch(t *testing.T) {
+	ta := startTestApp(t)
+	ta.waitFor("Login to MangaDex")
+	for i := 0; i < 6; i++ { // Move past the fields and the Login button, to the Guest button.
+		ta.keys(tcell.KeyTab)
+	}
+	ta.keys(tcell.KeyEnter)
+	ta.waitFor("Welcome to MangaDex, Guest!")
+
+	ta.keys(tcell.KeyCtrlS)
+	ta.waitFor("Search Manga:")
+	ta.typeText("other")
+	ta.keys(tcell.KeyTab, tcell.KeyTab, tcell.KeyEnter) // Skip Explicit Content, and press Search.
+	ta.waitFor("Other Manga")
+
+	ta.keys(tcell.KeyEnter)
+	ta.waitFor("Manga Information")
+	ta.waitFor("No chapters!")
+	if strings.Contains(ta.screenText(), "Test Manga") {
+		t.Error("search showed manga that do not match")
+	}
+}
+
+func TestAppSwitchProfile(t *testing.T) {
+	ta := startTestApp(t)
+	ta.waitFor("Login to MangaDex")
+
+	// Create a profile, which starts logged out.
+	ta.keys(tcell.KeyF3)
+	ta.waitFor("Switch profile?")
+	ta.keys(tcell.KeyRight, tcell.KeyEnter) // Skip the default profile, and press New.
+	ta.waitFor("New Profile")
+	ta.typeText("work")
+	ta.keys(tcell.KeyEnter)
+	ta.waitFor("Profile: work")
+	ta.waitFor("Login to MangaDex")
+	ta.logIn()
+	if _, err := os.Stat(filepath.Join(ta.configDir, "profiles", "work", "config.json")); err != nil {
+		t.Errorf("profile has no configuration of its own: %v", err)
+	}
+
+	// The default profile was not logged in, so switching back to it shows the login page again.
+	ta.keys(tcell.KeyF3)
+	ta.waitFor("Switch profile?")
+	ta.keys(tcell.KeyEnter)
+	ta.waitFor("Login to MangaDex")
+	if strings.Contains(ta.screenText(), "Profile: work") {
+		t.Error("still using the work profile")
+	}
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/darylhjd/mangadesk/app/core"
+)
+
+// ParseOptions : Read the options given on the command line and in environment variables, and use them.
+// Returns the arguments left after the options, which are a command to run if there are any. If the app should exit
+// instead, such as when the version is asked for or the options are invalid, exit is true along with the exit code.
+func ParseOptions(args []string) (rest []string, code int, exit bool) {
+	opts, version, rest, err := parseOptions(args, os.Getenv)
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		fmt.Print(usage)
+		return nil, exitOK, true
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "%s\n\n%s", err.Error(), usage)
+		return nil, exitUsage, true
+	case version:
+		fmt.Println(core.AppVersion)
+		return nil, exitOK, true
+	}
+	core.SetOptions(opts)
+	return rest, exitOK, false
+}
+
+// parseOptions : Parse the options given on the command line, using the environment variables for any that are
+// not given. Also returns whether the version was asked for, and the arguments left after the options.
+func parseOptions(args []string, getenv func(string) string) (*core.Options, bool, []string, error) {
+	opts := &core.Options{}
+	var guest, offline optionalBool
+	for env, value := range map[string]*optionalBool{"MANGADESK_GUEST": &guest, "MANGADESK_OFFLINE": &offline} {
+		if s := getenv(env); s != "" {
+			if err := value.Set(s); err != nil {
+				return nil, false, nil, fmt.Errorf("%s must be true or false, not %q", env, s)
+			}
+		}
+	}
+
+	fs := flag.NewFlagSet("mangadesk", flag.ContinueOnError)
+	fs.SetOutput(io.Discard) // Errors are printed along with the usage by the caller.
+	fs.StringVar(&opts.ConfigFile, "config", getenv("MANGADESK_CONFIG"), "")
+	fs.StringVar(&opts.ConfigDir, "config-dir", getenv("MANGADESK_CONFIG_DIR"), "")
+	fs.StringVar(&opts.DownloadDir, "download-dir", getenv("MANGADESK_DOWNLOAD_DIR"), "")
+	langs := fs.String("lang", getenv("MANGADESK_LANG"), "")
+	fs.StringVar(&opts.Quality, "quality", getenv("MANGADESK_QUALITY"), "")
+	fs.Var(&guest, "guest", "")
+	fs.Var(&offline, "offline", "")
+	fs.StringVar(&opts.LogLevel, "log-level", getenv("MANGADESK_LOG_LEVEL"), "")
+	fs.StringVar(&opts.SecretStore, "secret-store", getenv("MANGADESK_SECRET_STORE"), "")
+	fs.StringVar(&opts.Profile, "profile", getenv("MANGADESK_PROFILE"), "")
+	version := fs.Bool("version", false, "")
+	if err := fs.Parse(args); err != nil {
+		return nil, false, nil, err
+	}
+
+	if *langs != "" {
+		opts.Languages = strings.Split(*langs, ",")
+	}
+	opts.Guest = guest.value
+	opts.Offline = offline.value != nil && *offline.value
+	opts.Passphrase = getenv("MANGADESK_PASSPHRASE") // Not an option, so that it is not shown in the process list.
+	if err := opts.Validate(); err != nil {
+		return nil, false, nil, err
+	}
+	return opts, *version, fs.Args(), nil
+}
+
+// optionalBool : A flag that is true or false if it is given, and nil otherwise.
+type optionalBool struct {
+	value *bool
+}
+
+func (b *optionalBool) String() string {
+	if b.value == nil {
+		return ""
+	}
+	return strconv.FormatBool(*b.value)
+}
+
+func (b *optionalBool) Set(s string) error {
+	value, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	b.value = &value
+	return nil
+}
+
+// IsBoolFlag : Allow the flag to be given without a value, such as --guest, to mean true.
+func (b *optionalBool) IsBoolFlag() bool {
+	return true
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/darylhjd/mangadesk/app/core"
+)
+
+// fakeEnv : Environment variables for parsing options with, instead of the real ones.
+func fakeEnv(env map[string]string) func(string) string {
+	return func(name string) string {
+		return env[name]
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		want    core.Options
+		rest    []string
+		version bool
+		err     string // Part of the error, if there should be one.
+	}{
+		{name: "none"},
+		{
+			name: "flags",
+			args: []string{"--download-dir", "/manga", "--lang=en,pt-br", "--quality", "data-saver", "--guest"},
+			want: core.Options{DownloadDir: "/manga", Languages: []string{"en", "pt-br"}, Quality: "data-saver",
+				Guest: &yes},
+		},
+		{
+			name: "environment",
+			env: map[string]string{"MANGADESK_DOWNLOAD_DIR": "/manga", "MANGADESK_LANG": "fr",
+				"MANGADESK_GUEST": "false", "MANGADESK_PASSPHRASE": "secret", "MANGADESK_PROFILE": "work"},
+			want: core.Options{DownloadDir: "/manga", Languages: []string{"fr"}, Guest: &no, Passphrase: "secret",
+				Profile: "work"},
+		},
+		{
+			name: "flags over environment",
+			args: []string{"--lang", "de", "--guest=false", "--log-level", "off"},
+			env: map[string]string{"MANGADESK_LANG": "fr", "MANGADESK_GUEST": "true",
+				"MANGADESK_LOG_LEVEL": "error", "MANGADESK_QUALITY": "data"},
+			want: core.Options{Languages: []string{"de"}, Guest: &no, LogLevel: "off", Quality: "data"},
+		},
+		{
+			name: "offline",
+			env:  map[string]string{"MANGADESK_OFFLINE": "1"},
+			want: core.Options{Offline: true},
+		},
+		{
+			// Flags after the command are the command's own.
+			name: "command",
+			args: []string{"--guest", "download", "id", "--lang", "en", "--chapters", "1-10"},
+			env:  map[string]string{"MANGADESK_LANG": "fr"},
+			want: core.Options{Languages: []string{"fr"}, Guest: &yes},
+			rest: []string{"download", "id", "--lang", "en", "--chapters", "1-10"},
+		},
+		{name: "version", args: []string{"--version"}, version: true},
+		{name: "unknown flag", args: []string{"--colour"}, err: "flag provided but not defined: -colour"},
+		{name: "invalid flag", args: []string{"--quality", "best"}, err: `not "best"`},
+		{name: "invalid environment", env: map[string]string{"MANGADESK_GUEST": "maybe"},
+			err: "MANGADESK_GUEST must be true or false"},
+		{name: "invalid language", env: map[string]string{"MANGADESK_LANG": "english"},
+			err: `"english" is not a language code`},
+		{name: "invalid profile", args: []string{"--profile", "../work"}, err: "invalid profile"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, version, rest, err := parseOptions(tt.args, fakeEnv(tt.env))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("parseOptions = %v, want an error with %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*opts, tt.want) {
+				t.Errorf("options = %+v, want %+v", *opts, tt.want)
+			}
+			if len(rest) != 0 || len(tt.rest) != 0 {
+				if !reflect.DeepEqual(rest, tt.rest) {
+					t.Errorf("arguments left = %q, want %q", rest, tt.rest)
+				}
+			}
+			if version != tt.version {
+				t.Errorf("version = %t, want %t", version, tt.version)
+			}
+		})
+	}
+}
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		args     []string
+		chapters string
+		rest     []string
+	}{
+		{args: []string{"id", "--chapters", "1-10"}, chapters: "1-10", rest: []string{"id"}},
+		{args: []string{"--chapters=1-10", "id"}, chapters: "1-10", rest: []string{"id"}},
+		{args: []string{"one", "--chapters", "vol:3 unread", "two"}, chapters: "vol:3 unread",
+			rest: []string{"one", "two"}},
+		{args: []string{"id", "--", "--chapters"}, rest: []string{"id", "--chapters"}},
+	}
+	for _, tt := range tests {
+		fs := newFlagSet()
+		chapters := fs.String("chapters", "", "")
+		rest, err := parseCommand(fs, tt.args)
+		if err != nil || *chapters != tt.chapters || !reflect.DeepEqual(rest, tt.rest) {
+			t.Errorf("parseCommand(%q) = %q, %v with chapters %q, want %q with chapters %q", tt.args, rest, err,
+				*chapters, tt.rest, tt.chapters)
+		}
+	}
+}
+
+func TestOptionsOverrideConfiguration(t *testing.T) {
+	configDir, downloadDir := t.TempDir(), t.TempDir()
+	conf := `{"version": 1, "downloadDir": "` + filepath.ToSlash(downloadDir) + `", "languages": ["fr"], ` +
+		`"downloadQuality": "data-saver", "guestMode": false}`
+	if err := ioutil.WriteFile(filepath.Join(configDir, "config.json"), []byte(conf), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The flag is used over the environment variable, which is used over the configuration file.
+	opts, _, _, err := parseOptions([]string{"--config-dir", configDir, "--lang", "de", "--log-level", "off"},
+		fakeEnv(map[string]string{"MANGADESK_LANG": "es", "MANGADESK_QUALITY": "data"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	core.SetOptions(opts)
+	defer core.SetOptions(&core.Options{})
+	app := &core.MangaDesk{}
+	if _, err = app.InitialiseHeadless(); err != nil {
+		t.Fatal(err)
+	}
+	defer app.ShutdownHeadless()
+
+	got := app.Config
+	if strings.Join(got.Languages, ",") != "de" || got.DownloadQuality != "data" || got.DownloadDir != downloadDir ||
+		got.GuestMode {
+		t.Errorf("configuration = languages %q, quality %q, download folder %q, guest mode %t, want de, data, %q, "+
+			"false", got.Languages, got.DownloadQuality, got.DownloadDir, got.GuestMode, downloadDir)
+	}
+}
@@ -1,38 +1,107 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"time"
 
 	"github.com/darylhjd/mangodex"
 	"github.com/rivo/tview"
 
+	"github.com/darylhjd/mangadesk/app/backend"
 	"github.com/darylhjd/mangadesk/app/core"
 	"github.com/darylhjd/mangadesk/app/ui"
 )
 
 // Start : Set up the application.
 func Start() {
+	start(tview.NewApplication(), mangodex.NewDexClient)
+
+	// Run the app.
+	log.Println("Running app...")
+	if err := core.App.TView.Run(); err != nil {
+		core.LogErrorf("Error running app: %s\n", err.Error())
+	}
+}
+
+// start : Set up the application on a terminal application, without running it. Clients for MangaDex are created
+// with newClient, once for each profile that is used.
+func start(app *tview.Application, newClient func() *mangodex.DexClient) {
 	// Create new app.
 	core.App = &core.MangaDesk{
-		Client:     mangodex.NewDexClient(),
-		TView:      tview.NewApplication(),
+		TView:      app,
 		PageHolder: tview.NewPages(),
 	}
+	core.App.Initialise()
+
+	// Requests are sent with the network settings. As the client for MangaDex can only send its requests with the
+	// default transport, it is also changed to send requests for the API with them.
+	if err := core.ApplyNetworkSettings(core.App.Config); err != nil {
+		core.LogErrorf("Unable to apply network settings: %s\n", err.Error())
+	}
+
+	// The passphrase of the secret file is asked for on the terminal, so the interface is suspended while it is.
+	core.SetPassphrasePrompt(func(create bool) (pass string, err error) {
+		prompt := func() {
+			pass, err = core.PromptPassphrase(create)
+		}
+		if !app.Suspend(prompt) { // The interface is not running yet.
+			prompt()
+		}
+		return pass, err
+	})
+
+	// The services are created again when the user switches profile, as clients keep the session of the user.
+	newServices := func() *backend.Services {
+		// The services read the configuration from the app, as it may be changed while the app is running.
+		services := backend.New(newClient(), core.HTTPClient(), func() *core.UserConfig {
+			return core.App.Config
+		}, core.StoredCredentials{Secrets: core.Secrets()})
+		core.AuthoriseRequests(services.Auth.Authorise)
+		return services
+	}
+	services := newServices()
+	ui.UseServices(services, newServices)
 
 	// Show appropriate screen based on restore session result.
-	if err := core.App.Initialise(); err != nil {
+	// The status bar is set up first, as pages show their messages in it.
+	err := restoreSession(services.Auth)
+	ui.SetUpStatusBar()
+	if err != nil {
 		ui.ShowLoginPage()
 	} else {
 		ui.ShowMainPage()
 	}
 	log.Println("Initialised starting screen.")
+	ui.LoadTheme()
+	ui.LoadKeybindings()
+	ui.WatchConfiguration()
+	ui.WatchSession()
 	ui.SetUniversalHandlers()
+}
 
-	// Run the app.
-	log.Println("Running app...")
-	if err := core.App.TView.Run(); err != nil {
-		log.Println(err)
+// restoreSession : Check if the user's credentials have been stored before.
+// If they are, then read it, and attempt to refresh the token.
+// Will return error if any steps fail (no stored credentials, authentication failed).
+func restoreSession(auth *backend.Auth) error {
+	// Skip session restore if user is using guest mode.
+	if core.App.Config.GuestMode {
+		return nil
+	}
+
+	fmt.Println("Attempting session restore...")
+	err := auth.Resume(context.Background())
+	switch {
+	case errors.Is(err, backend.ErrNoSession): // User was not originally logged in.
+		fmt.Println("No past session, redirecting to login...")
+		time.Sleep(time.Millisecond * 750)
+	case err != nil:
+		fmt.Printf("Unable to restore session: %s.\n", err.Error())
+		time.Sleep(time.Millisecond * 750)
 	}
+	return err
 }
 
 // Shutdown : Shutdown the application.
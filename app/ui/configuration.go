@@ -0,0 +1,101 @@
+package ui
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"time"
+
+	"github.com/rivo/tview"
+
+	"github.com/darylhjd/mangadesk/app/core"
+)
+
+// configListeners : Functions to call when the configuration changes, so that pages can update what they show.
+// By the root primitive of the page.
+var configListeners = map[tview.Primitive]func(old, conf *core.UserConfig){}
+
+// onConfigChange : Call a function when the configuration changes while the page is in the navigation history,
+// replacing any function given before for the page.
+func onConfigChange(page tview.Primitive, f func(old, conf *core.UserConfig)) {
+	pageMutex.Lock()
+	defer pageMutex.Unlock()
+	configListeners[page] = f
+}
+
+// stopWatching : Functions that stop watching the configuration files of the profile in use.
+var stopWatching []func()
+
+// WatchConfiguration : Show any problems with the configuration file, and apply any changes to it while the app
+// is running. Every file the configuration can be in is watched, so that creating a file that takes precedence
+// over the one in use also applies it. When the user switches profile, this is done again for its files.
+func WatchConfiguration() {
+	for _, stop := range stopWatching {
+		stop()
+	}
+	stopWatching = nil
+
+	reloadConfiguration()
+	for _, path := range core.ConfigFiles() {
+		stopWatching = append(stopWatching, core.WatchFile(path, time.Second, func() {
+			core.App.TView.QueueUpdateDraw(reloadConfiguration)
+		}))
+	}
+}
+
+// reloadConfiguration : Read the configuration file again and apply it. If the file cannot be read, the current
+// configuration is kept. Problems with the configuration are shown to the user as notifications.
+func reloadConfiguration() {
+	old, problems, err := core.App.ReloadConfiguration()
+	if err != nil {
+		core.LogErrorf("Error reading configuration file: %s\n", err.Error())
+		notifyError(fmt.Sprintf("Could not read configuration file, keeping current settings: %s", err.Error()))
+		return
+	}
+	for _, problem := range problems {
+		if problem.Warning {
+			notify(fmt.Sprintf("Configuration: %s", problem.Error()))
+		} else {
+			notifyError(fmt.Sprintf("Configuration: %s", problem.Error()))
+		}
+	}
+	if configChanged(old, core.App.Config) {
+		notify("Configuration reloaded.")
+	}
+}
+
+// configChanged : Apply a change of configuration to the app, and let pages update what they show.
+// Returns false if the configuration did not change.
+func configChanged(old, conf *core.UserConfig) bool {
+	if reflect.DeepEqual(old, conf) {
+		return false
+	}
+	log.Println("Configuration changed.")
+	if old.VimMode != conf.VimMode || !reflect.DeepEqual(old.Keybindings, conf.Keybindings) {
+		LoadKeybindings()
+	}
+	if networkChanged(old, conf) {
+		if err := core.ApplyNetworkSettings(conf); err != nil {
+			core.LogErrorf("Unable to apply network settings: %s\n", err.Error())
+			notifyError(fmt.Sprintf("Network settings not applied: %s", err.Error()))
+		}
+	}
+
+	pageMutex.Lock()
+	var listeners []func(old, conf *core.UserConfig)
+	for _, listener := range configListeners {
+		listeners = append(listeners, listener)
+	}
+	pageMutex.Unlock()
+	for _, listener := range listeners {
+		listener(old, conf)
+	}
+	return true
+}
+
+// networkChanged : Check whether any of the network settings changed, such as the proxy.
+func networkChanged(old, conf *core.UserConfig) bool {
+	return old.APIURL != conf.APIURL || old.AtHomeURL != conf.AtHomeURL || old.AuthURL != conf.AuthURL ||
+		old.RequestTimeout != conf.RequestTimeout || old.UserAgent != conf.UserAgent ||
+		old.Proxy != conf.Proxy || old.CABundle != conf.CABundle
+}
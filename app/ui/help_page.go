@@ -15,36 +15,37 @@ const (
 // HelpPage : This struct contains the grid for the help page.
 type HelpPage struct {
 	Grid *tview.Grid
+	Text *tview.TextView
 }
 
 // ShowHelpPage : Make the app show the help page.
 func ShowHelpPage() {
+	// Do not show the help page on top of itself.
+	if router.currentID() == utils.HelpPageID {
+		return
+	}
 	helpPage := newHelpPage()
-
-	core.App.TView.SetFocus(helpPage.Grid)
-	core.App.PageHolder.AddPage(utils.HelpPageID, helpPage.Grid, true, true)
+	router.push(utils.HelpPageID, helpPage.Grid, helpPage.Grid)
 }
 
 // newHelpPage : Creates a new help page.
 func newHelpPage() *HelpPage {
 	formatString := fmt.Sprintf("%%-%ds:%%%ds\n", padding, padding)
-	// Set up the help text.
+	// Set up the help text from the keys currently bound to each action.
 	helpText := "Keyboard Mappings\n" +
-		"-----------------------------\n\n" +
-		"Universal\n" +
-		fmt.Sprintf(formatString, "Ctrl + L", "Login/Logout") +
-		fmt.Sprintf(formatString, "Ctrl + K", "Keybinds/Help") +
-		fmt.Sprintf(formatString, "Ctrl + S", "Search") +
-		"\nManga Page\n" +
-		fmt.Sprintf(formatString, "Ctrl + E", "Select mult.") +
-		fmt.Sprintf(formatString, "Ctrl + A", "Toggle All") +
-		fmt.Sprintf(formatString, "Ctrl + R", "Toggle Read Status") +
-		fmt.Sprintf(formatString, "Ctrl + Q", "Toggle Follow Manga") +
-		fmt.Sprintf(formatString, "Enter", "Start download") +
-		"\nOthers\n" +
-		fmt.Sprintf(formatString, "Esc", "Go back") +
-		fmt.Sprintf(formatString, "Ctrl + F/B", "Next/Prev Page") +
-		"\nApp Info\n" +
+		"-----------------------------\n\n"
+	for _, scope := range keymap.Scopes() {
+		helpText += scope.Title + "\n"
+		for _, action := range scope.Actions {
+			keys := keymap.Describe(action.Name)
+			if keys == "" {
+				keys = "(unbound)"
+			}
+			helpText += fmt.Sprintf(formatString, keys, action.Description)
+		}
+		helpText += "\n"
+	}
+	helpText += "App Info\n" +
 		core.AppVersion
 
 	// Create TextView to show the help information.
@@ -52,7 +53,6 @@ func newHelpPage() *HelpPage {
 	// Set TextView attributes.
 	help.SetText(helpText).
 		SetTextAlign(tview.AlignCenter).
-		SetBorderColor(utils.HelpPageBorderColor).
 		SetBorder(true)
 
 	// Create a new grid for the text view, so we can align it to the center.
@@ -62,8 +62,18 @@ func newHelpPage() *HelpPage {
 
 	helpPage := &HelpPage{
 		Grid: grid,
+		Text: help,
 	}
 	helpPage.setHandlers()
+	themePage(helpPage.Grid, helpPage.setColors)
 
 	return helpPage
 }
+
+// setColors : Apply the current theme to the help page.
+func (p *HelpPage) setColors() {
+	p.Text.SetTextColor(utils.Colors.Text).
+		SetBorderColor(utils.Colors.HelpPageBorder).
+		SetBackgroundColor(utils.Colors.Background)
+	p.Grid.SetBackgroundColor(utils.Colors.Background)
+}
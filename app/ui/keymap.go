@@ -0,0 +1,213 @@
+package ui
+
+import (
+	"fmt"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+
+	"github.com/darylhjd/mangadesk/app/core"
+	"github.com/darylhjd/mangadesk/app/ui/utils"
+)
+
+// Scopes of the keymap. Actions of the universal scope are available on every page.
+const (
+	universalScope     = "universal"
+	mainScope          = "main"
+	searchScope        = "search"
+	mangaScope         = "manga"
+	helpScope          = "help"
+	notificationsScope = "notifications"
+	settingsScope      = "settings"
+	vimScope           = "vim" // Layered on the main, search, manga, help and notifications pages when vim mode is enabled.
+)
+
+// Names of the actions that can be bound to keys. These are used in the `keybindings` configuration.
+const (
+	loginAction         = "universal.login"
+	helpAction          = "universal.help"
+	searchAction        = "universal.search"
+	quitAction          = "universal.quit"
+	paletteAction       = "universal.palette"
+	backAction          = "universal.back"
+	forwardAction       = "universal.forward"
+	notificationsAction = "universal.notifications"
+	settingsAction      = "universal.settings"
+	profileAction       = "universal.profile"
+
+	mainNextPageAction    = "main.nextPage"
+	mainPrevPageAction    = "main.prevPage"
+	mainSortNextAction    = "main.sortNext"
+	mainSortReverseAction = "main.sortReverse"
+
+	searchBackAction         = "search.back"
+	searchFocusFormAction    = "search.focusForm"
+	searchFocusResultsAction = "search.focusResults"
+
+	mangaBackAction         = "manga.back"
+	mangaSelectAction       = "manga.select"
+	mangaSelectAllAction    = "manga.selectAll"
+	mangaSelectRangeAction  = "manga.selectRange"
+	mangaVisualModeAction   = "manga.visualMode"
+	mangaExtendUpAction     = "manga.extendUp"
+	mangaExtendDownAction   = "manga.extendDown"
+	mangaInvertAction       = "manga.invertSelection"
+	mangaToggleReadAction   = "manga.toggleRead"
+	mangaToggleFollowAction = "manga.toggleFollow"
+	mangaDownloadAction     = "manga.download"
+	mangaCollapseAction     = "manga.collapse"
+	mangaExpandAction       = "manga.expand"
+	mangaSortNextAction     = "manga.sortNext"
+	mangaSortReverseAction  = "manga.sortReverse"
+
+	helpBackAction = "help.back"
+
+	notificationsBackAction = "notifications.back"
+
+	settingsBackAction = "settings.back"
+
+	vimDownAction         = "vim.down"
+	vimUpAction           = "vim.up"
+	vimTopAction          = "vim.top"
+	vimBottomAction       = "vim.bottom"
+	vimHalfPageDownAction = "vim.halfPageDown"
+	vimHalfPageUpAction   = "vim.halfPageUp"
+	vimFindAction         = "vim.find"
+	vimNextMatchAction    = "vim.nextMatch"
+	vimPrevMatchAction    = "vim.prevMatch"
+	vimBackAction         = "vim.back"
+	vimOpenAction         = "vim.open"
+	vimCommandAction      = "vim.command"
+)
+
+// keymap : The registry of every action that can be bound to keys, and the keys bound to them.
+var keymap = newKeymap()
+
+// newKeymap : Creates the keymap, with the actions of each page bound to their default keys.
+func newKeymap() *utils.Keymap {
+	k := utils.NewKeymap(universalScope)
+	k.Register(universalScope, "Universal",
+		&utils.KeyAction{Name: loginAction, Description: "Login/Logout", Defaults: []string{"Ctrl+L"}},
+		&utils.KeyAction{Name: helpAction, Description: "Keybinds/Help", Defaults: []string{"Ctrl+K"}},
+		&utils.KeyAction{Name: searchAction, Description: "Search", Defaults: []string{"Ctrl+S"}},
+		&utils.KeyAction{Name: quitAction, Description: "Quit", Defaults: []string{"Ctrl+C"}},
+		&utils.KeyAction{Name: paletteAction, Description: "Command palette", Defaults: []string{"Ctrl+P"}},
+		&utils.KeyAction{Name: backAction, Description: "Back", Defaults: []string{"Alt+Left"}},
+		&utils.KeyAction{Name: forwardAction, Description: "Forward", Defaults: []string{"Alt+Right"}},
+		&utils.KeyAction{Name: notificationsAction, Description: "Notifications", Defaults: []string{"Ctrl+W"}},
+		&utils.KeyAction{Name: settingsAction, Description: "Settings", Defaults: []string{"F2"}},
+		&utils.KeyAction{Name: profileAction, Description: "Switch profile", Defaults: []string{"F3"}},
+	)
+	k.Register(mainScope, "Main Page",
+		&utils.KeyAction{Name: mainNextPageAction, Description: "Next Page", Defaults: []string{"Ctrl+F"}},
+		&utils.KeyAction{Name: mainPrevPageAction, Description: "Prev Page", Defaults: []string{"Ctrl+B"}},
+		&utils.KeyAction{Name: mainSortNextAction, Description: "Sort by next column", Defaults: []string{"Ctrl+O"}},
+		&utils.KeyAction{Name: mainSortReverseAction, Description: "Reverse sort order", Defaults: []string{"Ctrl+T"}},
+	)
+	k.Register(searchScope, "Search Page",
+		&utils.KeyAction{Name: searchBackAction, Description: "Go back", Defaults: []string{"Esc"}},
+		&utils.KeyAction{Name: searchFocusFormAction, Description: "Go to search bar", Defaults: []string{"Tab"}},
+		&utils.KeyAction{Name: searchFocusResultsAction, Description: "Go to results", Defaults: []string{"Down"}},
+	)
+	k.Register(mangaScope, "Manga Page",
+		&utils.KeyAction{Name: mangaBackAction, Description: "Go back", Defaults: []string{"Esc"}},
+		&utils.KeyAction{Name: mangaSelectAction, Description: "Select mult.", Defaults: []string{"Ctrl+E"}},
+		&utils.KeyAction{Name: mangaSelectAllAction, Description: "Toggle All", Defaults: []string{"Ctrl+A"}},
+		&utils.KeyAction{Name: mangaSelectRangeAction, Description: "Select range", Defaults: []string{"Ctrl+G"}},
+		&utils.KeyAction{Name: mangaVisualModeAction, Description: "Visual mode", Defaults: []string{"Ctrl+V"}},
+		&utils.KeyAction{Name: mangaExtendUpAction, Description: "Extend selection up", Defaults: []string{"Shift+Up"}},
+		&utils.KeyAction{Name: mangaExtendDownAction, Description: "Extend selection down", Defaults: []string{"Shift+Down"}},
+		&utils.KeyAction{Name: mangaInvertAction, Description: "Invert selection", Defaults: []string{"Ctrl+N"}},
+		&utils.KeyAction{Name: mangaToggleReadAction, Description: "Toggle Read Status", Defaults: []string{"Ctrl+R"}},
+		&utils.KeyAction{Name: mangaToggleFollowAction, Description: "Toggle Follow Manga", Defaults: []string{"Ctrl+Q"}},
+		&utils.KeyAction{Name: mangaDownloadAction, Description: "Start download", Defaults: []string{"Enter"}},
+		&utils.KeyAction{Name: mangaCollapseAction, Description: "Collapse vol.", Defaults: []string{"Left"}},
+		&utils.KeyAction{Name: mangaExpandAction, Description: "Expand vol.", Defaults: []string{"Right"}},
+		&utils.KeyAction{Name: mangaSortNextAction, Description: "Sort by next column", Defaults: []string{"Ctrl+O"}},
+		&utils.KeyAction{Name: mangaSortReverseAction, Description: "Reverse sort order", Defaults: []string{"Ctrl+T"}},
+	)
+	k.Register(helpScope, "Help Page",
+		&utils.KeyAction{Name: helpBackAction, Description: "Go back", Defaults: []string{"Esc"}},
+	)
+	k.Register(notificationsScope, "Notifications Page",
+		&utils.KeyAction{Name: notificationsBackAction, Description: "Go back", Defaults: []string{"Esc"}},
+	)
+	k.Register(settingsScope, "Settings Page",
+		&utils.KeyAction{Name: settingsBackAction, Description: "Go back", Defaults: []string{"Esc"}},
+	)
+	k.RegisterLayer(vimScope, "Vim Mode",
+		&utils.KeyAction{Name: vimDownAction, Description: "Down", Defaults: []string{"j"}},
+		&utils.KeyAction{Name: vimUpAction, Description: "Up", Defaults: []string{"k"}},
+		&utils.KeyAction{Name: vimTopAction, Description: "Go to top", Defaults: []string{"g g"}},
+		&utils.KeyAction{Name: vimBottomAction, Description: "Go to bottom", Defaults: []string{"G"}},
+		&utils.KeyAction{Name: vimHalfPageDownAction, Description: "Half page down", Defaults: []string{"Ctrl+D"}},
+		&utils.KeyAction{Name: vimHalfPageUpAction, Description: "Half page up", Defaults: []string{"Ctrl+U"}},
+		&utils.KeyAction{Name: vimFindAction, Description: "Find", Defaults: []string{"/"}},
+		&utils.KeyAction{Name: vimNextMatchAction, Description: "Next match", Defaults: []string{"n"}},
+		&utils.KeyAction{Name: vimPrevMatchAction, Description: "Prev match", Defaults: []string{"N"}},
+		&utils.KeyAction{Name: vimBackAction, Description: "Go back", Defaults: []string{"h", "q"}},
+		&utils.KeyAction{Name: vimOpenAction, Description: "Open/Expand vol.", Defaults: []string{"l"}},
+		&utils.KeyAction{Name: vimCommandAction, Description: "Command", Defaults: []string{":"}},
+	)
+	return k
+}
+
+// LoadKeybindings : Apply the keybindings in the user configuration. Any problems, such as unknown actions or
+// conflicting keys, are shown to the user as notifications.
+func LoadKeybindings() {
+	keymap.SetEnabled(vimScope, core.App.Config.VimMode)
+	problems := keymap.Load(core.App.Config.Keybindings)
+	if len(problems) == 0 {
+		return
+	}
+
+	for _, problem := range problems {
+		notifyError(fmt.Sprintf("Keybindings: %s", problem.Error()))
+	}
+	if len(problems) > 1 {
+		notifyError(fmt.Sprintf("Found %d problems with your keybindings. Press %s to see them all.",
+			len(problems), keymap.Describe(notificationsAction)))
+	}
+}
+
+func init() {
+	// The keybindings are checked along with the rest of the configuration, such as by `mangadesk config check`.
+	core.AddConfigCheck(checkKeybindings)
+}
+
+// checkKeybindings : Find any problems with the keybindings in a configuration, without applying them.
+func checkKeybindings(conf *core.UserConfig) []*core.ConfigError {
+	k := newKeymap()
+	k.SetEnabled(vimScope, conf.VimMode)
+	var problems []*core.ConfigError
+	for _, err := range k.Load(conf.Keybindings) {
+		problems = append(problems, &core.ConfigError{Field: "keybindings", Message: err.Error()})
+	}
+	return problems
+}
+
+// handleKeys : Creates an input capture that calls the handlers of actions when their keys are pressed.
+func handleKeys(handlers map[string]func(event *tcell.EventKey) *tcell.EventKey) func(event *tcell.EventKey) *tcell.EventKey {
+	h := keymap.NewHandler(handlers)
+	return func(event *tcell.EventKey) *tcell.EventKey {
+		if isTyping(event) {
+			return event
+		}
+		return h.Handle(event)
+	}
+}
+
+// isTyping : Check whether a key event types a character into an input field. Such events are left to the
+// input field, even if the characters are bound to actions.
+func isTyping(event *tcell.EventKey) bool {
+	_, ok := core.App.TView.GetFocus().(*tview.InputField)
+	return ok && event.Key() == tcell.KeyRune && event.Modifiers()&tcell.ModAlt == 0
+}
+
+// consume : Wraps a function as an action handler that consumes the key event.
+func consume(f func()) func(event *tcell.EventKey) *tcell.EventKey {
+	return func(event *tcell.EventKey) *tcell.EventKey {
+		f()
+		return nil
+	}
+}
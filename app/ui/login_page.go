@@ -1,8 +1,10 @@
 package ui
 
 import (
-	"log"
+	"context"
+	"fmt"
 
+	"github.com/darylhjd/mangadesk/app/backend"
 	"github.com/darylhjd/mangadesk/app/core"
 	"github.com/darylhjd/mangadesk/app/ui/utils"
 	"github.com/rivo/tview"
@@ -12,82 +14,168 @@ import (
 type LoginPage struct {
 	Grid *tview.Grid
 	Form *tview.Form
+
+	relogin  bool              // Whether the user is logging in again after their session ended.
+	services *backend.Services // The services of the profile the page was created for.
 }
 
 // ShowLoginPage : Make the app show the login page.
 func ShowLoginPage() {
 	// Create the new login page
-	loginPage := newLoginPage()
+	// Pages shown before logging in no longer apply, so the navigation history is cleared.
+	loginPage := newLoginPage(router.services, false, false)
+	router.reset(utils.LoginPageID, loginPage.Grid, loginPage.Grid)
+	statusBar.setUser("Not logged in")
+}
+
+// showReloginPage : Make the app show the login page after the session ended. Once the user logs in, they are
+// returned to the page they were on. If the session that ended was remembered, so is the new one by default.
+func showReloginPage(remembered bool) {
+	loginPage := newLoginPage(router.services, true, remembered)
+	router.push(utils.LoginPageID, loginPage.Grid, loginPage.Grid)
+}
+
+// stopRefreshing : Stops refreshing the session in the background. Nil if it is not being refreshed.
+var stopRefreshing context.CancelFunc
 
-	core.App.TView.SetFocus(loginPage.Grid)
-	core.App.PageHolder.AddAndSwitchToPage(utils.LoginPageID, loginPage.Grid, true)
+// WatchSession : Keep the user's session refreshed while the app runs, and ask them to log in again if it ends.
+// When the user switches profile, the session of the new profile is watched instead.
+func WatchSession() {
+	if stopRefreshing != nil {
+		stopRefreshing()
+	}
+	auth := router.services.Auth
+	auth.OnSessionEnded(func(err error, remembered bool) {
+		core.App.TView.QueueUpdateDraw(func() {
+			sessionEnded(err, remembered)
+		})
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	stopRefreshing = cancel
+	go auth.RefreshInBackground(ctx)
+}
+
+// sessionEnded : Tell the user that their session ended, and let them log in again.
+func sessionEnded(err error, remembered bool) {
+	statusBar.setUser("Not logged in")
+	if core.App.PageHolder.HasPage(utils.SessionEndedModalID) || router.currentID() == utils.LoginPageID {
+		return
+	}
+	text := fmt.Sprintf("Your session has ended: %s.\nLog in again to continue where you left off.", err.Error())
+	modal := confirmModal(utils.SessionEndedModalID, text, "Log in", func() {
+		showReloginPage(remembered)
+	})
+	ShowModal(utils.SessionEndedModalID, modal)
 }
 
-// newLoginPage : Creates a new login page.
-func newLoginPage() *LoginPage {
+// newLoginPage : Creates a new login page, which uses the given services, with Remember Me checked if remember is
+// true.
+func newLoginPage(services *backend.Services, relogin, remember bool) *LoginPage {
 	// Create the LoginPage
-	loginPage := &LoginPage{}
+	loginPage := &LoginPage{relogin: relogin, services: services}
 
 	form := tview.NewForm()
 
 	// Set form attributes.
 	form.SetButtonsAlign(tview.AlignCenter).
-		SetLabelColor(utils.LoginFormLabelColor).
 		SetTitle("Login to MangaDex").
-		SetTitleColor(utils.LoginPageTitleColor).
-		SetBorder(true).
-		SetBorderColor(utils.LoginFormBorderColor)
+		SetBorder(true)
 
 	// Add form fields.
-	form.AddInputField("Username", "", 0, nil, nil).
+	// Each profile has its own login, so the profile is chosen first.
+	profiles := profileChoices()
+	form.AddDropDown("Profile", profiles, indexOf(profiles, core.Profile()), func(option string, _ int) {
+		loginPage.profileChosen(option)
+	})
+	// MangaDex logs users in with personal API clients, which they create in their MangaDex settings.
+	form.AddInputField("Client ID", "", 0, nil, nil).
+		AddPasswordField("Client Secret", "", 0, '*', nil).
+		AddInputField("Username", "", 0, nil, nil).
 		AddPasswordField("Password", "", 0, '*', nil).
-		AddCheckbox("Remember Me", false, nil).
+		AddCheckbox("Remember Me", remember, nil).
 		AddButton("Login", func() {
 			loginPage.attemptLogin()
 		}).
 		AddButton("Guest", func() { // Guest button
-			core.App.PageHolder.RemovePage(utils.LoginPageID)
 			ShowMainPage()
 		})
 
+	form.SetFocus(1) // Start at the client ID, as the profile is seldom changed.
+
 	dimension := []int{0, 0, 0}
 	grid := utils.NewGrid(dimension, dimension)
 
 	grid.AddItem(form, 0, 0, 3, 3, 0, 0, true).
-		AddItem(form, 1, 1, 1, 1, 32, 70, true)
+		AddItem(form, 1, 1, 1, 1, 34, 70, true)
 
 	loginPage.Grid = grid
 	loginPage.Form = form
+	themePage(loginPage.Grid, loginPage.setColors)
 	return loginPage
 }
 
+// setColors : Apply the current theme to the login page.
+func (p *LoginPage) setColors() {
+	setFormColors(p.Form, utils.Colors.LoginFormLabel)
+	p.Form.SetTitleColor(utils.Colors.LoginPageTitle).
+		SetBorderColor(utils.Colors.LoginFormBorder)
+	p.Grid.SetBackgroundColor(utils.Colors.Background)
+}
+
+// profileChosen : Switch to the profile chosen in the form, or ask for the name of a new one.
+func (p *LoginPage) profileChosen(option string) {
+	if p.Form == nil || option == core.Profile() {
+		return // The form is still being created, or the profile did not change.
+	}
+	if option == newProfileChoice {
+		// The profile only changes once the new one is created.
+		profiles := profileChoices()
+		p.Form.GetFormItemByLabel("Profile").(*tview.DropDown).SetCurrentOption(indexOf(profiles, core.Profile()))
+		askNewProfile()
+		return
+	}
+	chooseProfile(option)
+}
+
 // attemptLogin : Attempts to log in with given form fields. If success, bring user to main page.
 func (p *LoginPage) attemptLogin() {
 	form := p.Form
 
-	// Get username and password input.
+	// Get the client, username and password input.
+	clientID := form.GetFormItemByLabel("Client ID").(*tview.InputField).GetText()
+	clientSecret := form.GetFormItemByLabel("Client Secret").(*tview.InputField).GetText()
 	user := form.GetFormItemByLabel("Username").(*tview.InputField).GetText()
 	pwd := form.GetFormItemByLabel("Password").(*tview.InputField).GetText()
 	remember := form.GetFormItemByLabel("Remember Me").(*tview.Checkbox).IsChecked()
 
 	// Attempt to log in to MangaDex API.
-	if err := core.App.Client.Auth.Login(user, pwd); err != nil {
-		log.Printf("Error trying to login: %s\n", err.Error())
-		modal := okModal(utils.GenericAPIErrorModalID, "Authentication failed.\nTry again!")
-		ShowModal(utils.GenericAPIErrorModalID, modal)
+	if err := p.services.Auth.Login(context.Background(), clientID, clientSecret, user, pwd); err != nil {
+		core.LogErrorf("Error trying to login: %s\n", err.Error())
+		notifyError(fmt.Sprintf("Authentication failed: %s.", err.Error()))
 		return
 	}
 
 	// Remember the user's login credentials if user wants it.
 	if remember {
-		if err := core.App.StoreCredentials(); err != nil {
-			log.Printf("Error storing credentials: %s\n", err.Error())
-			modal := okModal(utils.StoreCredentialErrorModalID,
-				"Failed to store login token.\nCheck logs for details.")
-			ShowModal(utils.StoreCredentialErrorModalID, modal)
+		if err := p.services.Auth.Remember(); err != nil {
+			core.LogErrorf("Error storing credentials: %s\n", err.Error())
+			notifyError(fmt.Sprintf("Failed to store login token: %s.", err.Error()))
 		}
 	}
 
-	core.App.PageHolder.RemovePage(utils.LoginPageID) // Remove the login page as we no longer need it.
-	ShowMainPage()
+	if p.relogin && router.pop() {
+		// The user is returned to the page they were on, which the status bar no longer shows them logged in for.
+		go func() {
+			username, err := p.services.Auth.Username(context.Background())
+			if err != nil {
+				core.LogErrorf("Error getting user info: %s\n", err.Error())
+				return
+			}
+			core.App.TView.QueueUpdateDraw(func() {
+				statusBar.setUser(fmt.Sprintf("Logged in as %s", username))
+			})
+		}()
+		return
+	}
+	ShowMainPage() // The login page is removed from the navigation history, as we no longer need it.
 }
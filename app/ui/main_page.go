@@ -5,15 +5,14 @@ import (
 	"fmt"
 	"log"
 	"math"
-	"net/url"
-	"sort"
-	"strconv"
 	"strings"
 	"time"
 
+	"github.com/darylhjd/mangadesk/app/backend"
 	"github.com/darylhjd/mangadesk/app/core"
 	"github.com/darylhjd/mangadesk/app/ui/utils"
 	"github.com/darylhjd/mangodex"
+	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
 )
 
@@ -32,38 +31,40 @@ type MainPage struct {
 	CurrentOffset int
 	MaxOffset     int
 
-	cWrap *utils.ContextWrapper // For context cancellation.
+	logged   bool              // Whether the table shows the followed manga of a logged user.
+	services *backend.Services // The services of the profile the page was created for.
+
+	tableTitle   string                // The title of the table, without pagination and sort details.
+	defaultOrder map[string]int        // The order of each manga, as returned by MangaDex.
+	sorter       *utils.SortWrapper    // For sorting the table.
+	cWrap        *utils.ContextWrapper // For context cancellation.
 }
 
 // ShowMainPage : Make the app show the main page.
 func ShowMainPage() {
 	// Create the new main page
 	log.Println("Creating new main page...")
-	mainPage := newMainPage()
+	mainPage := newMainPage(router.services)
 
-	core.App.TView.SetFocus(mainPage.Grid)
-	core.App.PageHolder.AddAndSwitchToPage(utils.MainPageID, mainPage.Grid, true)
+	// The main page is the first page, so the navigation history is cleared.
+	router.reset(utils.MainPageID, mainPage.Grid, mainPage.Grid)
 }
 
-// newMainPage : Creates a new main page.
-func newMainPage() *MainPage {
+// newMainPage : Creates a new main page, which uses the given services.
+func newMainPage(services *backend.Services) *MainPage {
 	var dimensions []int
 	for i := 0; i < 15; i++ {
 		dimensions = append(dimensions, -1)
 	}
 	grid := utils.NewGrid(dimensions, dimensions)
 	// Set grid attributes.
-	grid.SetTitleColor(utils.MainPageGridTitleColor).
-		SetBorderColor(utils.MainPageGridBorderColor).
-		SetBorder(true)
+	grid.SetBorder(true)
 
 	// Create the base main table.
 	table := tview.NewTable()
 	// Set table attributes
 	table.SetSelectable(true, false).
 		SetSeparator('|').
-		SetBordersColor(utils.MainPageTableBorderColor).
-		SetTitleColor(utils.MainPageTableTitleColor).
 		SetBorder(true)
 
 	// Add the table to the grid. Table spans the whole page.
@@ -71,8 +72,9 @@ func newMainPage() *MainPage {
 
 	ctx, cancel := context.WithCancel(context.Background())
 	mainPage := &MainPage{
-		Grid:  grid,
-		Table: table,
+		Grid:     grid,
+		Table:    table,
+		services: services,
 		cWrap: &utils.ContextWrapper{
 			Ctx:    ctx,
 			Cancel: cancel,
@@ -80,14 +82,46 @@ func newMainPage() *MainPage {
 	}
 
 	// Check what kind of main page to show to the user.
-	if core.App.Client.Auth.IsLoggedIn() {
+	if mainPage.services.Auth.LoggedIn() {
+		mainPage.logged = true
+		mainPage.setLoggedSorter()
 		mainPage.setLogged()
 	} else {
+		mainPage.setGuestSorter("Popularity")
 		mainPage.setGuest()
 	}
+	themePage(mainPage.Grid, mainPage.setColors)
 	return mainPage
 }
 
+// setColors : Apply the current theme to the main page.
+func (p *MainPage) setColors() {
+	p.Grid.SetTitleColor(utils.Colors.MainPageGridTitle).
+		SetBorderColor(utils.Colors.MainPageGridBorder).
+		SetBackgroundColor(utils.Colors.Background)
+	p.Table.SetBordersColor(utils.Colors.MainPageTableBorder).
+		SetTitleColor(utils.Colors.MainPageTableTitle).
+		SetBackgroundColor(utils.Colors.Background)
+	p.setCellColors()
+}
+
+// setCellColors : Apply the current theme to the cells of the table, by column.
+func (p *MainPage) setCellColors() {
+	colors := []tcell.Color{utils.Colors.GuestMainPageTitle, utils.Colors.GuestMainPageDesc, utils.Colors.GuestMainPageTag}
+	if p.logged {
+		colors = []tcell.Color{
+			utils.Colors.LoggedMainPageTitle, utils.Colors.LoggedMainPagePubStatus, utils.Colors.LoggedMainPageLastUpdate,
+		}
+	}
+	for row := 0; row < p.Table.GetRowCount(); row++ {
+		for col, color := range colors {
+			if cell := p.Table.GetCell(row, col); cell != nil {
+				cell.SetTextColor(color)
+			}
+		}
+	}
+}
+
 // setLogged : Set up the MainPage for a logged user.
 func (p *MainPage) setLogged() {
 	log.Println("Using logged main page.")
@@ -99,14 +133,15 @@ func (p *MainPage) setLogged() {
 func (p *MainPage) setLoggedGrid() {
 	log.Println("Setting logged grid...")
 	var username string
-	if u, err := core.App.Client.User.GetLoggedUser(); err != nil {
-		log.Println(fmt.Sprintf("Error getting user info: %s", err.Error()))
+	if u, err := p.services.Auth.Username(context.Background()); err != nil {
+		core.LogErrorf("Error getting user info: %s\n", err.Error())
 	} else {
-		username = u.Data.Attributes.Username
+		username = u
 	}
 
 	core.App.TView.QueueUpdateDraw(func() {
 		p.Grid.SetTitle(fmt.Sprintf("Welcome to MangaDex, [lightgreen]%s!", username))
+		statusBar.setUser(fmt.Sprintf("Logged in as %s", username))
 	})
 	log.Println("Finished setting logged grid.")
 }
@@ -128,41 +163,40 @@ func (p *MainPage) setLoggedTable() {
 		// Set headers.
 		titleHeader := tview.NewTableCell("Title").
 			SetAlign(tview.AlignCenter).
-			SetTextColor(utils.LoggedMainPageTitleColor).
+			SetTextColor(utils.Colors.LoggedMainPageTitle).
 			SetSelectable(false)
 		pubStatusHeader := tview.NewTableCell("Pub. Status").
 			SetAlign(tview.AlignLeft).
-			SetTextColor(utils.LoggedMainPagePubStatusColor).
+			SetTextColor(utils.Colors.LoggedMainPagePubStatus).
+			SetSelectable(false)
+		lastUpdateHeader := tview.NewTableCell("Last Update").
+			SetAlign(tview.AlignLeft).
+			SetTextColor(utils.Colors.LoggedMainPageLastUpdate).
 			SetSelectable(false)
 		p.Table.SetCell(0, 0, titleHeader).
 			SetCell(0, 1, pubStatusHeader).
+			SetCell(0, 2, lastUpdateHeader).
 			SetFixed(1, 0)
+		p.sorter.SetHeaders(p.Table, p.sortTable)
 
 		// Set table title.
-		page, first, last := p.calculatePaginationData()
-		p.Table.SetTitle(fmt.Sprintf("Followed manga. Page %d (%d-%d). [::bu]Loading...", page, first, last))
+		p.tableTitle = "Followed manga"
+		p.setTableTitle(true)
 	})
 
 	// Get the list of the user's followed manga.
 	if p.cWrap.ToCancel(ctx) {
 		return
 	}
-	followed, err := core.App.Client.User.GetUserFollowedMangaList(
-		offsetRange, p.CurrentOffset, []string{mangodex.AuthorRel})
+	followed, err := p.services.Feed.Followed(ctx, offsetRange, p.CurrentOffset)
 	if err != nil {
-		log.Printf("Error getting followed manga: %s\n", err.Error())
+		core.LogErrorf("Error getting followed manga: %s\n", err.Error())
 		core.App.TView.QueueUpdateDraw(func() {
-			modal := okModal(utils.GenericAPIErrorModalID, "Error getting followed manga.\nCheck logs for details.")
-			ShowModal(utils.GenericAPIErrorModalID, modal)
+			notifyError("Error getting followed manga. Check logs for details.")
 		})
 		return
 	}
 
-	// Sort the list based on manga title.
-	sort.Slice(followed.Data, func(i, j int) bool {
-		return followed.Data[i].GetTitle("en") < followed.Data[j].GetTitle("en")
-	})
-
 	// Update offset details.
 	p.MaxOffset = int(math.Min(float64(followed.Total), maxOffset))
 
@@ -176,12 +210,12 @@ func (p *MainPage) setLoggedTable() {
 	}
 
 	// Update table title.
-	page, first, last := p.calculatePaginationData()
 	core.App.TView.QueueUpdateDraw(func() {
-		p.Table.SetTitle(fmt.Sprintf("Followed manga. Page %d (%d-%d).", page, first, last))
+		p.setTableTitle(false)
 	})
 
 	// Fill in the details
+	p.defaultOrder = map[string]int{}
 	for index := 0; index < len(followed.Data); index++ {
 		if p.cWrap.ToCancel(ctx) {
 			return
@@ -190,15 +224,20 @@ func (p *MainPage) setLoggedTable() {
 		// Set title and publishing status cells.
 		// Title
 		mtCell := tview.NewTableCell(fmt.Sprintf("%-50s", manga.GetTitle("en"))).
-			SetMaxWidth(50).SetTextColor(utils.LoggedMainPageTitleColor).SetReference(&manga)
+			SetMaxWidth(50).SetTextColor(utils.Colors.LoggedMainPageTitle).SetReference(&manga)
 
 		// Publishing Status.
 		sCell := tview.NewTableCell(strings.Title(fmt.Sprintf("%-15s", *manga.Attributes.Status))).
-			SetMaxWidth(15).SetTextColor(utils.LoggedMainPagePubStatusColor)
+			SetMaxWidth(15).SetTextColor(utils.Colors.LoggedMainPagePubStatus)
+
+		// Last update.
+		uCell := tview.NewTableCell(backend.LastUpdate(&manga)).SetTextColor(utils.Colors.LoggedMainPageLastUpdate)
 
-		p.Table.SetCell(index+1, 0, mtCell).SetCell(index+1, 1, sCell)
+		p.Table.SetCell(index+1, 0, mtCell).SetCell(index+1, 1, sCell).SetCell(index+1, 2, uCell)
+		p.defaultOrder[manga.ID] = index
 	}
 	core.App.TView.QueueUpdateDraw(func() {
+		p.sortTable()
 		p.Table.Select(1, 0)
 		p.Table.ScrollToBeginning()
 	})
@@ -217,6 +256,11 @@ func (p *MainPage) setGuestGrid() {
 	log.Println("Setting guest grid...")
 	core.App.TView.QueueUpdateDraw(func() {
 		p.Grid.SetTitle("Welcome to MangaDex, [yellow]Guest!")
+		if core.Offline() {
+			statusBar.setUser("Offline")
+		} else {
+			statusBar.setUser("Guest")
+		}
 	})
 	log.Println("Finished setting guest grid.")
 }
@@ -233,9 +277,9 @@ func (p *MainPage) setGuestTable(searchParams *SearchParams) {
 	time.Sleep(loadDelay)
 	defer cancel()
 
-	tableTitle := "Popular manga"
+	p.tableTitle = "Popular manga"
 	if searchParams != nil {
-		tableTitle = "Search Results"
+		p.tableTitle = "Search Results"
 	}
 
 	core.App.TView.QueueUpdateDraw(func() {
@@ -245,37 +289,44 @@ func (p *MainPage) setGuestTable(searchParams *SearchParams) {
 		// Set headers.
 		titleHeader := tview.NewTableCell("Manga").
 			SetAlign(tview.AlignCenter).
-			SetTextColor(utils.GuestMainPageTitleColor).
+			SetTextColor(utils.Colors.GuestMainPageTitle).
 			SetSelectable(false)
 		descHeader := tview.NewTableCell("Description").
 			SetAlign(tview.AlignCenter).
-			SetTextColor(utils.GuestMainPageDescColor).
+			SetTextColor(utils.Colors.GuestMainPageDesc).
 			SetSelectable(false)
 		tagHeader := tview.NewTableCell("Tags").
 			SetAlign(tview.AlignCenter).
-			SetTextColor(utils.GuestMainPageTagColor).
+			SetTextColor(utils.Colors.GuestMainPageTag).
 			SetSelectable(false)
 		p.Table.SetCell(0, 0, titleHeader).
 			SetCell(0, 1, descHeader).
 			SetCell(0, 2, tagHeader).
 			SetFixed(1, 0)
+		p.sorter.SetHeaders(p.Table, p.sortTable)
 
 		// Set table title.
-		page, first, last := p.calculatePaginationData()
-		p.Table.SetTitle(fmt.Sprintf("%s. Page %d (%d-%d). [::bu]Loading...", tableTitle, page, first, last))
+		p.setTableTitle(true)
 	})
 
-	// Get list of manga.
-	params := p.setGuestSearchParams(searchParams)
+	// Get list of manga. If it is a search, then we follow settings for this search.
 	if p.cWrap.ToCancel(ctx) {
 		return
 	}
-	list, err := core.App.Client.Manga.GetMangaList(*params)
+	var (
+		list *mangodex.MangaList
+		err  error
+	)
+	if searchParams != nil {
+		log.Printf("Settings guest table for search: \"%s\"\n", searchParams.term)
+		list, err = p.services.Feed.Search(ctx, searchParams.term, searchParams.explicit, offsetRange, p.CurrentOffset)
+	} else {
+		list, err = p.services.Feed.Popular(ctx, offsetRange, p.CurrentOffset)
+	}
 	if err != nil {
-		log.Println(err.Error())
+		core.LogErrorf("Error getting manga list: %s\n", err.Error())
 		core.App.TView.QueueUpdateDraw(func() {
-			modal := okModal(utils.GenericAPIErrorModalID, "Error getting manga list.\nCheck logs for details.")
-			ShowModal(utils.GenericAPIErrorModalID, modal)
+			notifyError("Error getting manga list. Check logs for details.")
 		})
 		return
 	}
@@ -293,12 +344,12 @@ func (p *MainPage) setGuestTable(searchParams *SearchParams) {
 	}
 
 	// Update table title.
-	page, first, last := p.calculatePaginationData()
 	core.App.TView.QueueUpdateDraw(func() {
-		p.Table.SetTitle(fmt.Sprintf("%s. Page %d (%d-%d).", tableTitle, page, first, last))
+		p.setTableTitle(false)
 	})
 
 	// Fill in the details
+	p.defaultOrder = map[string]int{}
 	for index := 0; index < len(list.Data); index++ {
 		if p.cWrap.ToCancel(ctx) {
 			return
@@ -306,72 +357,110 @@ func (p *MainPage) setGuestTable(searchParams *SearchParams) {
 		manga := list.Data[index]
 		// Manga title cell.
 		mtCell := tview.NewTableCell(fmt.Sprintf("%-40s", manga.GetTitle("en"))).
-			SetMaxWidth(40).SetTextColor(utils.GuestMainPageTitleColor).SetReference(&manga)
+			SetMaxWidth(40).SetTextColor(utils.Colors.GuestMainPageTitle).SetReference(&manga)
 
 		// Description cell. Truncate description to improve loading times.
 		desc := tview.Escape(fmt.Sprintf("%-60s",
 			strings.SplitN(tview.Escape(manga.GetDescription("en")), "\n", 2)[0]))
-		descCell := tview.NewTableCell(desc).SetMaxWidth(60).SetTextColor(utils.GuestMainPageDescColor)
+		descCell := tview.NewTableCell(desc).SetMaxWidth(60).SetTextColor(utils.Colors.GuestMainPageDesc)
 
 		// Tag cell.
 		tags := make([]string, len(manga.Attributes.Tags))
 		for i, tag := range manga.Attributes.Tags {
 			tags[i] = tag.GetName("en")
 		}
-		tagCell := tview.NewTableCell(strings.Join(tags, ", ")).SetTextColor(utils.GuestMainPageTagColor)
+		tagCell := tview.NewTableCell(strings.Join(tags, ", ")).SetTextColor(utils.Colors.GuestMainPageTag)
 
 		p.Table.SetCell(index+1, 0, mtCell).
 			SetCell(index+1, 1, descCell).
 			SetCell(index+1, 2, tagCell)
+		p.defaultOrder[manga.ID] = index
 	}
 	core.App.TView.QueueUpdateDraw(func() {
+		p.sortTable()
 		p.Table.Select(1, 0)
 		p.Table.ScrollToBeginning()
 	})
 	log.Println("Finished setting guest table.")
 }
 
-// setGuestSearchParams : Helper function to set up query parameters for the guest table.
-func (p *MainPage) setGuestSearchParams(searchParams *SearchParams) *url.Values {
-	// Set up offset parameters
-	params := url.Values{}
-
-	// Set limits and offset
-	params.Set("limit", strconv.Itoa(offsetRange))
-	params.Set("offset", strconv.Itoa(p.CurrentOffset))
-
-	// Set content ratings
-	ratings := []string{mangodex.Safe, mangodex.Suggestive, mangodex.Erotica}
-	switch searchParams != nil {
-	case true: // If it is a search, then we follow settings for this search.
-		if !searchParams.explicit {
-			ratings = append(ratings, mangodex.Porn)
-		}
-	case false: // Else, we follow the configuration settings set by the user.
-		if core.App.Config.ExplicitContent {
-			ratings = append(ratings, mangodex.Porn)
-		}
+// setLoggedSorter : Set the fields that the logged table can be sorted by. Followed manga are sorted by title.
+func (p *MainPage) setLoggedSorter() {
+	p.sorter = &utils.SortWrapper{
+		Fields: []utils.SortField{
+			{Name: "Title", Column: 0, Less: lessMangaTitle},
+			{Name: "Pub. Status", Column: 1, Less: lessMangaStatus},
+			{Name: "Last Update", Column: 2, Less: lessMangaLastUpdate},
+		},
 	}
-	for _, rating := range ratings {
-		params.Add("contentRating[]", rating)
+}
+
+// setGuestSorter : Set the fields that the guest table can be sorted by. The table is first shown in the order
+// returned by MangaDex, which is described by defaultOrder.
+func (p *MainPage) setGuestSorter(defaultOrder string) {
+	p.sorter = &utils.SortWrapper{
+		Fields: []utils.SortField{
+			{Name: defaultOrder, Column: -1, Less: func(a, b []*tview.TableCell) bool {
+				return p.defaultOrder[rowManga(a).ID] < p.defaultOrder[rowManga(b).ID]
+			}},
+			{Name: "Title", Column: 0, Less: lessMangaTitle},
+			{Name: "Pub. Status", Column: -1, Less: lessMangaStatus},
+			{Name: "Last Update", Column: -1, Less: lessMangaLastUpdate},
+		},
 	}
+}
 
-	// Set order of results.
-	if searchParams != nil { // If fpr searching, we sort by relevancy.
-		params.Set("order[relevance]", "desc")
-	} else { // Else, we sort by popular manga (based on follow count)
-		params.Set("order[followedCount]", "desc")
+// sortTable : Sort the manga table by the current sort field.
+func (p *MainPage) sortTable() {
+	row, _ := p.Table.GetSelection()
+	if newRow, ok := p.sorter.Sort(p.Table)[row]; ok {
+		p.Table.Select(newRow, 0)
 	}
+	p.sorter.SetHeaders(p.Table, p.sortTable)
+	p.setTableTitle(false)
+}
 
-	// Set search term (if for search)
-	if searchParams != nil {
-		log.Printf("Settings guest table for search: \"%s\"\n", searchParams.term)
-		params.Set("title", searchParams.term)
+// setTableTitle : Set the title of the table, with pagination and sort details.
+func (p *MainPage) setTableTitle(loading bool) {
+	page, first, last := p.calculatePaginationData()
+	title := fmt.Sprintf("%s. Page %d (%d-%d). Sorted by %s.",
+		p.tableTitle, page, first, last, p.sorter.Description())
+	if loading {
+		title += " [::bu]Loading..."
 	}
+	p.Table.SetTitle(title)
+}
+
+// rowManga : Get the manga referenced by a row of the manga table.
+// Returns an empty manga if the row does not reference one.
+func rowManga(row []*tview.TableCell) *mangodex.Manga {
+	if manga, ok := row[0].GetReference().(*mangodex.Manga); ok {
+		return manga
+	}
+	return &mangodex.Manga{}
+}
+
+// lessMangaTitle : Compare the titles of the manga in two rows.
+func lessMangaTitle(a, b []*tview.TableCell) bool {
+	return strings.ToLower(rowManga(a).GetTitle("en")) < strings.ToLower(rowManga(b).GetTitle("en"))
+}
+
+// lessMangaStatus : Compare the publication status of the manga in two rows.
+func lessMangaStatus(a, b []*tview.TableCell) bool {
+	var aStatus, bStatus string
+	if status := rowManga(a).Attributes.Status; status != nil {
+		aStatus = *status
+	}
+	if status := rowManga(b).Attributes.Status; status != nil {
+		bStatus = *status
+	}
+	return aStatus < bStatus
+}
 
-	// Include Author relationship
-	params.Set("includes[]", mangodex.AuthorRel)
-	return &params
+// lessMangaLastUpdate : Compare the last update time of the manga in two rows.
+func lessMangaLastUpdate(a, b []*tview.TableCell) bool {
+	// Update times are in ISO 8601, so they can be compared as strings.
+	return rowManga(a).Attributes.UpdatedAt < rowManga(b).Attributes.UpdatedAt
 }
 
 // calculatePaginationData : Calculates the current page and first/last entry number.
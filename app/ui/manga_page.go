@@ -4,12 +4,11 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"net/url"
-	"os"
-	"strconv"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/darylhjd/mangadesk/app/backend"
 	"github.com/darylhjd/mangadesk/app/core"
 	"github.com/darylhjd/mangadesk/app/ui/utils"
 	"github.com/darylhjd/mangodex"
@@ -17,10 +16,19 @@ import (
 	"github.com/rivo/tview"
 )
 
+const readStatus = "Y"
+
+// Columns of the chapter table.
 const (
-	chapterOffsetRange    = 500
-	contextCancelledError = "CANCELLED"
-	readStatus            = "Y"
+	chapNumCol = iota
+	chapVolumeCol
+	chapTitleCol
+	chapLangCol
+	chapDownloadCol
+	chapScanGroupCol
+	chapPublishedCol
+	chapReadCol
+	chapColCount // The number of columns in the chapter table.
 )
 
 // MangaPage : This struct contains the required primitives for the manga page.
@@ -30,37 +38,65 @@ type MangaPage struct {
 	Info  *tview.TextView
 	Table *tview.Table
 
-	sWrap *utils.SelectorWrapper
-	cWrap *utils.ContextWrapper // For context cancellation.
+	volumes  []*volumeNode       // The volumes shown in the chapter table.
+	read     map[string]struct{} // The IDs of the chapters that the user has read.
+	services *backend.Services   // The services of the profile the page was created for.
+
+	sWrap     *utils.SelectorWrapper
+	rangeSel  *rangeSelection       // The range of chapters being selected, if any.
+	dragStart int                   // The row where the mouse was pressed down, or 0 if it is not pressed.
+	sorter    *utils.SortWrapper    // For sorting the chapter table.
+	cWrap     *utils.ContextWrapper // For context cancellation.
+}
+
+// rangeMode : How a range of chapters is being selected.
+type rangeMode int
+
+const (
+	visualRange rangeMode = iota // Visual mode, until the user leaves it.
+	shiftRange                   // Shift + Up/Down, until a key is pressed without Shift.
+	dragRange                    // Mouse drag, until the mouse button is released.
+)
+
+// rangeSelection : A contiguous range of chapters being selected. The range covers every chapter between the
+// anchor and the cursor, in addition to the chapters that were already selected when the range was started.
+type rangeSelection struct {
+	mode   rangeMode
+	anchor *tview.TableCell              // The chapter number cell of the row where the range was started.
+	base   map[*tview.TableCell]struct{} // The chapter number cells of rows selected before the range was started.
+}
+
+// volumeNode : A volume in the chapter table, grouping the rows of the chapters that belong to it.
+// The chapters of a collapsed volume are not shown in the table.
+type volumeNode struct {
+	volume    string               // The volume number. Empty for chapters that do not belong to a volume.
+	rows      [][]*tview.TableCell // The rows of the chapters in the volume.
+	header    []*tview.TableCell   // The row showing the volume itself.
+	collapsed bool
 }
 
 // ShowMangaPage : Make the app show the manga page.
 func ShowMangaPage(manga *mangodex.Manga) {
-	mangaPage := newMangaPage(manga)
+	mangaPage := newMangaPage(manga, router.services)
 
-	core.App.TView.SetFocus(mangaPage.Grid)
-	core.App.PageHolder.AddAndSwitchToPage(utils.MangaPageID, mangaPage.Grid, true)
+	router.push(utils.MangaPageID, mangaPage.Grid, mangaPage.Grid)
 }
 
-// newMangaPage : Creates a new manga page.
-func newMangaPage(manga *mangodex.Manga) *MangaPage {
+// newMangaPage : Creates a new manga page, which uses the given services.
+func newMangaPage(manga *mangodex.Manga, services *backend.Services) *MangaPage {
 	var dimensions []int
 	for i := 0; i < 15; i++ {
 		dimensions = append(dimensions, -1)
 	}
 	grid := utils.NewGrid(dimensions, dimensions)
 	// Set grid attributes
-	grid.SetTitleColor(utils.MangaPageGridTitleColor).
-		SetBorderColor(utils.MangaPageGridBorderColor).
-		SetTitle("Manga Information").
+	grid.SetTitle("Manga Information").
 		SetBorder(true)
 
 	// Use a TextView for basic information of the manga.
 	info := tview.NewTextView()
 	// Set textview attributes
 	info.SetWrap(true).SetWordWrap(true).
-		SetBorderColor(utils.MangaPageInfoViewBorderColor).
-		SetTitleColor(utils.MangaPageInfoViewTitleColor).
 		SetTitle("About").
 		SetBorder(true)
 
@@ -68,32 +104,42 @@ func newMangaPage(manga *mangodex.Manga) *MangaPage {
 	table := tview.NewTable()
 	// Set chapter headers
 	numHeader := tview.NewTableCell("Chap").
-		SetTextColor(utils.MangaPageChapNumColor).
+		SetTextColor(utils.Colors.MangaPageChapNum).
+		SetSelectable(false)
+	volumeHeader := tview.NewTableCell("Vol").
+		SetTextColor(utils.Colors.MangaPageVolume).
 		SetSelectable(false)
 	titleHeader := tview.NewTableCell("Name").
-		SetTextColor(utils.MangaPageTitleColor).
+		SetTextColor(utils.Colors.MangaPageTitle).
+		SetSelectable(false)
+	langHeader := tview.NewTableCell("Lang").
+		SetTextColor(utils.Colors.MangaPageLang).
 		SetSelectable(false)
 	downloadHeader := tview.NewTableCell("Download Status").
-		SetTextColor(utils.MangaPageDownloadStatColor).
+		SetTextColor(utils.Colors.MangaPageDownloadStat).
 		SetSelectable(false)
 	scanGroupHeader := tview.NewTableCell("ScanGroup").
-		SetTextColor(utils.MangaPageScanGroupColor).
+		SetTextColor(utils.Colors.MangaPageScanGroup).
+		SetSelectable(false)
+	publishedHeader := tview.NewTableCell("Published").
+		SetTextColor(utils.Colors.MangaPagePublished).
 		SetSelectable(false)
 	readMarkerHeader := tview.NewTableCell("Read Status").
-		SetTextColor(utils.MangaPageReadStatColor).
+		SetTextColor(utils.Colors.MangaPageReadStat).
 		SetSelectable(false)
-	table.SetCell(0, 0, numHeader).
-		SetCell(0, 1, titleHeader).
-		SetCell(0, 2, downloadHeader).
-		SetCell(0, 3, scanGroupHeader).
-		SetCell(0, 4, readMarkerHeader).
+	table.SetCell(0, chapNumCol, numHeader).
+		SetCell(0, chapVolumeCol, volumeHeader).
+		SetCell(0, chapTitleCol, titleHeader).
+		SetCell(0, chapLangCol, langHeader).
+		SetCell(0, chapDownloadCol, downloadHeader).
+		SetCell(0, chapScanGroupCol, scanGroupHeader).
+		SetCell(0, chapPublishedCol, publishedHeader).
+		SetCell(0, chapReadCol, readMarkerHeader).
 		SetFixed(1, 0)
 	// Set table attributes
 	table.SetSelectable(true, false).
 		SetSeparator('|').
-		SetBordersColor(utils.MangaPageTableBorderColor).
 		SetTitle("Chapters").
-		SetTitleColor(utils.MangaPageTableTitleColor).
 		SetBorder(true)
 
 	// Add info and table to the grid. Set the focus to the chapter table.
@@ -104,18 +150,26 @@ func newMangaPage(manga *mangodex.Manga) *MangaPage {
 
 	ctx, cancel := context.WithCancel(context.Background())
 	mangaPage := &MangaPage{
-		Manga: manga,
-		Grid:  grid,
-		Info:  info,
-		Table: table,
+		Manga:    manga,
+		Grid:     grid,
+		Info:     info,
+		Table:    table,
+		read:     map[string]struct{}{},
+		services: services,
 		sWrap: &utils.SelectorWrapper{
 			Selection: map[int]struct{}{},
 		},
+		sorter: &utils.SortWrapper{
+			Fields:     chapterSortFields,
+			Descending: true, // Show the latest chapters first.
+		},
 		cWrap: &utils.ContextWrapper{
 			Ctx:    ctx,
 			Cancel: cancel,
 		},
 	}
+	mangaPage.sorter.SetHeaders(table, mangaPage.renderChapters)
+	themePage(mangaPage.Grid, mangaPage.setColors)
 
 	// Set up values
 	go mangaPage.setMangaInfo()
@@ -170,198 +224,640 @@ func (p *MangaPage) setChapterTable() {
 	// Show loading status so user knows it's loading.
 	core.App.TView.QueueUpdateDraw(func() {
 		loadingCell := tview.NewTableCell("Loading...").SetSelectable(false)
-		p.Table.SetCell(1, 1, loadingCell)
+		p.Table.SetCell(1, chapTitleCol, loadingCell)
 	})
 
 	// Get All chapters
 	if p.cWrap.ToCancel(ctx) {
 		return
 	}
-	chapters, err := p.getAllChapters(ctx)
+	// Chapters from blocked groups are removed, and chapters from preferred groups are shown first.
+	chapters, err := p.services.Library.Chapters(ctx, p.Manga)
 	if err != nil { // If error getting chapters.
-		if strings.Contains(err.Error(), contextCancelledError) {
+		if p.cWrap.ToCancel(ctx) {
 			return
 		}
-		log.Println(fmt.Sprintf("Error getting manga chapters: %s", err.Error()))
+		core.LogErrorf("Error getting manga chapters: %s\n", err.Error())
 		core.App.TView.QueueUpdateDraw(func() {
-			modal := okModal(utils.GenericAPIErrorModalID, "Error getting manga chapters.\nCheck log for details.")
-			ShowModal(utils.GenericAPIErrorModalID, modal)
+			notifyError("Error getting manga chapters. Check log for details.")
 		})
 		return
-	} else if len(chapters) == 0 { // If there are no chapters.
+	}
+
+	if len(chapters) == 0 { // If there are no chapters.
 		core.App.TView.QueueUpdateDraw(func() {
 			noResultsCell := tview.NewTableCell("No chapters!").SetSelectable(false)
-			p.Table.SetCell(1, 1, noResultsCell)
+			p.Table.SetCell(1, chapTitleCol, noResultsCell)
 		})
 		return
 	}
 
 	// Get the chapter read markers.
 	markers := map[string]struct{}{}
-	if core.App.Client.Auth.IsLoggedIn() {
+	if p.services.Auth.LoggedIn() {
 		if p.cWrap.ToCancel(ctx) {
 			return
 		}
-		markerResponse, err := core.App.Client.Chapter.GetReadMangaChapters(p.Manga.ID)
-		if err != nil {
-			log.Println(fmt.Sprintf("Error getting chapter read markers: %s", err.Error()))
+		if markers, err = p.services.Library.ReadMarkers(ctx, p.Manga.ID); err != nil {
+			core.LogErrorf("Error getting chapter read markers: %s\n", err.Error())
 			core.App.TView.QueueUpdateDraw(func() {
-				modal := okModal(utils.GenericAPIErrorModalID, "Error getting chapter read markers.\nCheck log for details.")
-				ShowModal(utils.GenericAPIErrorModalID, modal)
+				notifyError("Error getting chapter read markers. Check log for details.")
 			})
 			return
 		}
-		for _, marker := range markerResponse.Data {
-			markers[marker] = struct{}{}
-		}
 	}
 
-	// Fill in the chapters
+	// Create the rows for the chapters
+	var rows [][]*tview.TableCell
 	for index := 0; index < len(chapters); index++ {
 		if p.cWrap.ToCancel(ctx) {
 			return
 		}
 		chapter := chapters[index]
 		// Chapter Number
-		chapterNumCell := tview.NewTableCell(
-			fmt.Sprintf("%-6s %s", chapter.GetChapterNum(), chapter.Attributes.TranslatedLanguage)).
-			SetMaxWidth(10).SetTextColor(utils.MangaPageChapNumColor).SetReference(&chapter)
+		chapterNumCell := tview.NewTableCell(fmt.Sprintf("%-6s", chapter.GetChapterNum())).
+			SetMaxWidth(10).SetTextColor(utils.Colors.MangaPageChapNum).SetReference(&chapter)
+
+		// Chapter volume
+		volumeCell := tview.NewTableCell(fmt.Sprintf("%-4s", backend.ChapterVolume(&chapter))).SetMaxWidth(6).
+			SetTextColor(utils.Colors.MangaPageVolume)
 
 		// Chapter title
 		titleCell := tview.NewTableCell(fmt.Sprintf("%-30s", chapter.GetTitle())).SetMaxWidth(30).
-			SetTextColor(utils.MangaPageTitleColor)
+			SetTextColor(utils.Colors.MangaPageTitle)
+
+		// Chapter language
+		langCell := tview.NewTableCell(chapter.Attributes.TranslatedLanguage).
+			SetTextColor(utils.Colors.MangaPageLang)
 
 		// Chapter download status
 		var downloadStatus string
 		// Check for the presence of the download folder.
-		if _, err = os.Stat(p.getDownloadFolder(&chapter)); err == nil {
+		if p.services.Downloads.IsDownloaded(p.Manga, &chapter) {
 			downloadStatus = "Y"
 		}
-		downloadCell := tview.NewTableCell(downloadStatus).SetTextColor(utils.MangaPageDownloadStatColor)
+		downloadCell := tview.NewTableCell(downloadStatus).SetTextColor(utils.Colors.MangaPageDownloadStat)
 
 		// Scanlation group
-		var scanGroup string
-		for _, relation := range chapter.Relationships {
-			if relation.Type == mangodex.ScanlationGroupRel {
-				scanGroup = relation.Attributes.(*mangodex.ScanlationGroupAttributes).Name
-				break
-			}
-		}
+		_, scanGroup := backend.ScanGroup(&chapter)
 		scanGroupCell := tview.NewTableCell(fmt.Sprintf("%-15s", scanGroup)).SetMaxWidth(15).
-			SetTextColor(utils.MangaPageScanGroupColor)
+			SetTextColor(utils.Colors.MangaPageScanGroup)
 
-		// Read marker
+		// Publish date
+		publishedCell := tview.NewTableCell(backend.PublishDate(&chapter)).
+			SetTextColor(utils.Colors.MangaPagePublished)
+
+		// Read marker. If the user is not logged in, a message is shown instead when the table is rendered.
 		var read string
-		if !core.App.Client.Auth.IsLoggedIn() {
-			read = "Not logged in!"
-		} else if _, ok := markers[chapter.ID]; ok {
+		if _, ok := markers[chapter.ID]; ok {
 			read = readStatus
 		}
-		readCell := tview.NewTableCell(read).SetTextColor(utils.MangaPageReadStatColor)
-
-		p.Table.SetCell(index+1, 0, chapterNumCell).
-			SetCell(index+1, 1, titleCell).
-			SetCell(index+1, 2, downloadCell).
-			SetCell(index+1, 3, scanGroupCell)
-		if !core.App.Client.Auth.IsLoggedIn() {
-			if index == 0 {
-				p.Table.SetCell(index+1, 4, readCell)
-			}
-		} else {
-			p.Table.SetCell(index+1, 4, readCell)
-		}
+		readCell := tview.NewTableCell(read).SetTextColor(utils.Colors.MangaPageReadStat)
+
+		row := make([]*tview.TableCell, chapColCount)
+		row[chapNumCol] = chapterNumCell
+		row[chapVolumeCol] = volumeCell
+		row[chapTitleCol] = titleCell
+		row[chapLangCol] = langCell
+		row[chapDownloadCol] = downloadCell
+		row[chapScanGroupCol] = scanGroupCell
+		row[chapPublishedCol] = publishedCell
+		row[chapReadCol] = readCell
+		rows = append(rows, row)
 	}
+
+	// Group the chapters by volume, and show them.
+	volumes := groupByVolume(rows)
 	core.App.TView.QueueUpdateDraw(func() {
+		p.volumes, p.read = volumes, markers
+		p.renderChapters()
 		p.Table.Select(1, 0)
 		p.Table.ScrollToBeginning()
 	})
 }
 
-// getAllChapters : Get All chapters for the manga.
-func (p *MangaPage) getAllChapters(ctx context.Context) ([]mangodex.Chapter, error) {
+// chapterSortFields : Fields that the chapter table can be sorted by.
+var chapterSortFields = []utils.SortField{
+	{Name: "Chapter", Column: chapNumCol, Less: func(a, b []*tview.TableCell) bool {
+		return utils.CompareNumeric(rowChapter(a).GetChapterNum(), rowChapter(b).GetChapterNum()) < 0
+	}},
+	{Name: "Volume", Column: chapVolumeCol, Less: func(a, b []*tview.TableCell) bool {
+		return utils.CompareNumeric(backend.ChapterVolume(rowChapter(a)), backend.ChapterVolume(rowChapter(b))) < 0
+	}},
+	{Name: "Language", Column: chapLangCol, Less: func(a, b []*tview.TableCell) bool {
+		return rowChapter(a).Attributes.TranslatedLanguage < rowChapter(b).Attributes.TranslatedLanguage
+	}},
+	{Name: "Download Status", Column: chapDownloadCol, Less: func(a, b []*tview.TableCell) bool {
+		return a[chapDownloadCol].Text < b[chapDownloadCol].Text
+	}},
+	{Name: "Group", Column: chapScanGroupCol, Less: func(a, b []*tview.TableCell) bool {
+		_, aGroup := backend.ScanGroup(rowChapter(a))
+		_, bGroup := backend.ScanGroup(rowChapter(b))
+		return strings.ToLower(aGroup) < strings.ToLower(bGroup)
+	}},
+	{Name: "Publish Date", Column: chapPublishedCol, Less: func(a, b []*tview.TableCell) bool {
+		// Publish times are in ISO 8601, so they can be compared as strings.
+		return rowChapter(a).Attributes.PublishAt < rowChapter(b).Attributes.PublishAt
+	}},
+	{Name: "Read Status", Column: chapReadCol, Less: func(a, b []*tview.TableCell) bool {
+		return a[chapReadCol].Text < b[chapReadCol].Text
+	}},
+}
+
+// rowChapter : Get the chapter referenced by a row of the chapter table.
+// Returns an empty chapter if the row does not reference one.
+func rowChapter(row []*tview.TableCell) *mangodex.Chapter {
+	if chapter, ok := row[chapNumCol].GetReference().(*mangodex.Chapter); ok {
+		return chapter
+	}
+	return &mangodex.Chapter{}
+}
+
+// rowChapters : Get the chapters referenced by rows of the chapter table. Rows that do not reference one are skipped.
+func rowChapters(rows [][]*tview.TableCell) []*mangodex.Chapter {
+	var chapters []*mangodex.Chapter
+	for _, cells := range rows {
+		chapter, ok := cells[chapNumCol].GetReference().(*mangodex.Chapter)
+		if !ok {
+			continue
+		}
+		chapters = append(chapters, chapter)
+	}
+	return chapters
+}
+
+// chapterRow : Get the row of a chapter in the chapter table, or nil if it is not in it.
+func (p *MangaPage) chapterRow(id string) []*tview.TableCell {
+	for _, vol := range p.volumes {
+		for _, cells := range vol.rows {
+			if rowChapter(cells).ID == id {
+				return cells
+			}
+		}
+	}
+	return nil
+}
+
+// isRead : Check whether the user has read the chapter in a row of the chapter table.
+func (p *MangaPage) isRead(cells []*tview.TableCell) bool {
+	_, ok := p.read[rowChapter(cells).ID]
+	return ok
+}
+
+// groupByVolume : Group the rows of chapters by the volume they belong to.
+func groupByVolume(rows [][]*tview.TableCell) []*volumeNode {
 	var (
-		params     = p.setGetChaptersParams()
-		chapters   []mangodex.Chapter
-		currOffset = 0
+		volumes []*volumeNode
+		byName  = map[string]*volumeNode{}
 	)
-	for {
-		if p.cWrap.ToCancel(ctx) {
-			return []mangodex.Chapter{}, fmt.Errorf(contextCancelledError)
+	for _, row := range rows {
+		name := backend.ChapterVolume(rowChapter(row))
+		vol, ok := byName[name]
+		if !ok {
+			vol = &volumeNode{volume: name}
+			byName[name] = vol
+			volumes = append(volumes, vol)
+		}
+		vol.rows = append(vol.rows, row)
+	}
+	return volumes
+}
+
+// renderChapters : Fill the chapter table with the volumes, and the chapters of each expanded volume.
+// Volumes and the chapters within them are sorted by the current sort field.
+// Selections and the cursor follow their rows as they move. Chapters hidden in a collapsed volume are unselected.
+func (p *MangaPage) renderChapters() {
+	p.sorter.SetHeaders(p.Table, p.renderChapters)
+	if p.volumes == nil { // Chapters have not been loaded yet.
+		return
+	}
+
+	// Remember where each row was, so that selections can follow them.
+	oldRows := map[*tview.TableCell]int{}
+	for row := 1; row < p.Table.GetRowCount(); row++ {
+		oldRows[p.Table.GetCell(row, chapNumCol)] = row
+	}
+	selected := map[int]*tview.TableCell{}
+	for row := range p.sWrap.Selection {
+		selected[row] = p.Table.GetCell(row, chapNumCol)
+	}
+	cursor, _ := p.Table.GetSelection()
+
+	// Volumes are ordered by their number, in the same direction as the chapters.
+	sort.SliceStable(p.volumes, func(i, j int) bool {
+		if p.sorter.Descending {
+			return utils.CompareNumeric(p.volumes[j].volume, p.volumes[i].volume) < 0
+		}
+		return utils.CompareNumeric(p.volumes[i].volume, p.volumes[j].volume) < 0
+	})
+
+	var (
+		row      = 1
+		mapping  = map[int]int{}
+		loggedIn = p.services.Auth.LoggedIn()
+		notice   = false
+	)
+	setRow := func(cells []*tview.TableCell) {
+		if oldRow, ok := oldRows[cells[chapNumCol]]; ok {
+			mapping[oldRow] = row
+		}
+		for col, cell := range cells {
+			p.Table.SetCell(row, col, cell)
+		}
+		row++
+	}
+	for _, vol := range p.volumes {
+		p.sorter.SortRows(vol.rows)
+		p.setVolumeHeader(vol)
+		setRow(vol.header)
+		if vol.collapsed {
+			continue
+		}
+		for _, cells := range vol.rows {
+			// When the user is not logged in, the read status column only has a message on the first chapter.
+			if !loggedIn {
+				if !notice {
+					cells[chapReadCol].SetText("Not logged in!")
+					notice = true
+				} else {
+					cells[chapReadCol].SetText("")
+				}
+			}
+			setRow(cells)
+		}
+	}
+	// Remove rows that are no longer used.
+	for p.Table.GetRowCount() > row {
+		p.Table.RemoveRow(p.Table.GetRowCount() - 1)
+	}
+
+	// Unselect chapters that are now hidden, and move the rest of the selections.
+	for oldRow, cell := range selected {
+		if _, ok := mapping[oldRow]; !ok {
+			highlightCell(cell, false)
+		}
+	}
+	p.sWrap.MoveSelections(mapping)
+	p.setTableTitle()
+	if newRow, ok := mapping[cursor]; ok {
+		p.Table.Select(newRow, 0)
+	} else if cursor >= row {
+		p.Table.Select(row-1, 0)
+	}
+}
+
+// setTableTitle : Update the title of the chapter table with the number of selected chapters.
+func (p *MangaPage) setTableTitle() {
+	title := "Chapters"
+	if n := len(p.sWrap.Selection); n != 0 {
+		title = fmt.Sprintf("Chapters (%d selected)", n)
+	}
+	if p.rangeSel != nil && p.rangeSel.mode == visualRange {
+		title += " [::r] VISUAL [::-]"
+	}
+	p.Table.SetTitle(title)
+}
+
+// setVolumeHeaders : Update the rows showing each volume.
+func (p *MangaPage) setVolumeHeaders() {
+	for _, vol := range p.volumes {
+		p.setVolumeHeader(vol)
+	}
+}
+
+// setVolumeHeader : Update the row showing a volume, with the number of read and downloaded chapters in it.
+func (p *MangaPage) setVolumeHeader(vol *volumeNode) {
+	if vol.header == nil {
+		vol.header = make([]*tview.TableCell, chapColCount)
+		for col := range vol.header {
+			vol.header[col] = tview.NewTableCell("")
 		}
-		params.Set("offset", strconv.Itoa(currOffset))
-		list, err := core.App.Client.Chapter.GetMangaChapters(p.Manga.ID, *params)
-		if err != nil {
-			return []mangodex.Chapter{}, err
+		vol.header[chapNumCol].SetTextColor(utils.Colors.MangaPageVolume).SetReference(vol)
+		vol.header[chapTitleCol].SetTextColor(utils.Colors.MangaPageVolume)
+	}
+
+	var read, downloaded int
+	for _, cells := range vol.rows {
+		if p.isRead(cells) {
+			read++
 		}
-		log.Printf("Got %d of %d chapters\n", currOffset, list.Total)
-		chapters = append(chapters, list.Data...)
-		currOffset += chapterOffsetRange
-		if currOffset >= list.Total {
+		if cells[chapDownloadCol].Text == readStatus {
+			downloaded++
+		}
+	}
+
+	arrow, name := "▼", "No Vol."
+	if vol.collapsed {
+		arrow = "▶"
+	}
+	if vol.volume != "" {
+		name = fmt.Sprintf("Vol. %s", vol.volume)
+	}
+	counts := fmt.Sprintf("%d chapters (%d downloaded)", len(vol.rows), downloaded)
+	if p.services.Auth.LoggedIn() {
+		counts = fmt.Sprintf("%d chapters (%d read, %d downloaded)", len(vol.rows), read, downloaded)
+	}
+	vol.header[chapNumCol].SetText(fmt.Sprintf("%s %s", arrow, name))
+	vol.header[chapTitleCol].SetText(counts)
+}
+
+// getVolumeNode : Get the volume shown in a row of the chapter table. Returns nil if the row is not a volume.
+func (p *MangaPage) getVolumeNode(row int) *volumeNode {
+	if vol, ok := p.Table.GetCell(row, chapNumCol).GetReference().(*volumeNode); ok {
+		return vol
+	}
+	return nil
+}
+
+// setVolumeCollapsed : Collapse or expand a volume.
+func (p *MangaPage) setVolumeCollapsed(vol *volumeNode, collapsed bool) {
+	if vol.collapsed == collapsed {
+		return
+	}
+	vol.collapsed = collapsed
+	p.renderChapters()
+}
+
+// markVolume : Marks all chapters of a volume as selected, or unselected if they are all already selected.
+// The volume is expanded, so that the selected chapters can be seen.
+func (p *MangaPage) markVolume(vol *volumeNode) {
+	p.setVolumeCollapsed(vol, false)
+
+	// Find the rows of the chapters in the volume.
+	var rows []int
+	for row := 1; row < p.Table.GetRowCount(); row++ {
+		if p.getVolumeNode(row) == vol {
+			for i := range vol.rows {
+				rows = append(rows, row+i+1)
+			}
 			break
 		}
 	}
-	return chapters, nil
+
+	all := true
+	for _, row := range rows {
+		all = all && p.sWrap.HasSelection(row)
+	}
+	for _, row := range rows {
+		if all {
+			p.markUnselected(row)
+		} else {
+			p.markSelected(row)
+		}
+	}
 }
 
-// setGetChaptersParams : Helper function to set up query parameters for getting chapters.
-func (p *MangaPage) setGetChaptersParams() *url.Values {
-	// Set up query parameters.
-	params := url.Values{}
+// selectRange : Select the chapters matching a chapter range expression, returning the number of chapters
+// selected. Volumes containing a matching chapter are expanded, so that the selected chapters can be seen.
+func (p *MangaPage) selectRange(expr string) (int, error) {
+	r, err := backend.ParseChapterRange(expr)
+	if err != nil {
+		return 0, err
+	}
+
+	matched := map[*tview.TableCell]struct{}{}
+	for _, vol := range p.volumes {
+		for _, cells := range vol.rows {
+			if r.Matches(p.getChapterInfo(cells)) {
+				matched[cells[chapNumCol]] = struct{}{}
+				vol.collapsed = false
+			}
+		}
+	}
+	p.renderChapters()
 
-	// Set limits
-	params.Set("limit", strconv.Itoa(chapterOffsetRange))
+	for row := 1; row < p.Table.GetRowCount(); row++ {
+		if _, ok := matched[p.Table.GetCell(row, chapNumCol)]; ok {
+			p.markSelected(row)
+		}
+	}
+	return len(matched), nil
+}
 
-	// Set All chapters with user's specified languages
-	for _, lang := range core.App.Config.Languages {
-		params.Add("translatedLanguage[]", lang)
+// getChapterInfo : Get the details of the chapter in a row, for matching against a chapter range.
+func (p *MangaPage) getChapterInfo(cells []*tview.TableCell) *backend.ChapterInfo {
+	chapter := rowChapter(cells)
+	_, group := backend.ScanGroup(chapter)
+	info := &backend.ChapterInfo{
+		Volume:     backend.ChapterVolume(chapter),
+		Language:   chapter.Attributes.TranslatedLanguage,
+		Group:      group,
+		Read:       p.isRead(cells),
+		Downloaded: cells[chapDownloadCol].Text == readStatus,
+	}
+	if num := chapter.Attributes.Chapter; num != nil {
+		info.Number = *num
 	}
+	return info
+}
 
-	// Show the latest chapters first.
-	params.Set("order[chapter]", "desc")
+// getChapterRows : Get the rows of the chapters in a selection. A selected volume includes all of its
+// chapters, even if it is collapsed. The rows are ordered as they are shown in the table.
+func (p *MangaPage) getChapterRows(selection map[int]struct{}) [][]*tview.TableCell {
+	var (
+		rows  [][]*tview.TableCell
+		added = map[*tview.TableCell]struct{}{}
+	)
+	addRow := func(cells []*tview.TableCell) {
+		if _, ok := added[cells[chapNumCol]]; !ok {
+			added[cells[chapNumCol]] = struct{}{}
+			rows = append(rows, cells)
+		}
+	}
 
-	// Show required explicit chapters
-	ratings := []string{mangodex.Safe, mangodex.Suggestive, mangodex.Erotica}
-	if p.Manga.Attributes.ContentRating != nil && *p.Manga.Attributes.ContentRating == mangodex.Porn {
-		ratings = append(ratings, mangodex.Porn)
+	indices := make([]int, 0, len(selection))
+	for row := range selection {
+		indices = append(indices, row)
 	}
-	for _, rating := range ratings {
-		params.Add("contentRating[]", rating)
+	sort.Ints(indices)
+	for _, row := range indices {
+		if vol := p.getVolumeNode(row); vol != nil {
+			for _, cells := range vol.rows {
+				addRow(cells)
+			}
+		} else if _, ok := p.Table.GetCell(row, chapNumCol).GetReference().(*mangodex.Chapter); ok {
+			cells := make([]*tview.TableCell, chapColCount)
+			for col := range cells {
+				cells[col] = p.Table.GetCell(row, col)
+			}
+			addRow(cells)
+		}
 	}
+	return rows
+}
 
-	// Also get the scanlation group for the chapter
-	params.Add("includes[]", mangodex.ScanlationGroupRel)
+// highlightCell : Set the colours of a chapter number cell to show whether it is selected.
+func highlightCell(cell *tview.TableCell, selected bool) {
+	if selected {
+		cell.SetTextColor(utils.Colors.MangaPageHighlightText).SetBackgroundColor(utils.Colors.MangaPageHighlight)
+	} else {
+		cell.SetTextColor(utils.Colors.MangaPageChapNum).SetBackgroundColor(utils.Colors.Background)
+	}
+}
+
+// chapterColors : Get the colours of each column of the chapter table, from the current theme.
+func chapterColors() []tcell.Color {
+	colors := make([]tcell.Color, chapColCount)
+	colors[chapNumCol] = utils.Colors.MangaPageChapNum
+	colors[chapVolumeCol] = utils.Colors.MangaPageVolume
+	colors[chapTitleCol] = utils.Colors.MangaPageTitle
+	colors[chapLangCol] = utils.Colors.MangaPageLang
+	colors[chapDownloadCol] = utils.Colors.MangaPageDownloadStat
+	colors[chapScanGroupCol] = utils.Colors.MangaPageScanGroup
+	colors[chapPublishedCol] = utils.Colors.MangaPagePublished
+	colors[chapReadCol] = utils.Colors.MangaPageReadStat
+	return colors
+}
 
-	return &params
+// setColors : Apply the current theme to the manga page, including the chapters hidden in collapsed volumes.
+func (p *MangaPage) setColors() {
+	p.Grid.SetTitleColor(utils.Colors.MangaPageGridTitle).
+		SetBorderColor(utils.Colors.MangaPageGridBorder).
+		SetBackgroundColor(utils.Colors.Background)
+	p.Info.SetTextColor(utils.Colors.Text).
+		SetBorderColor(utils.Colors.MangaPageInfoViewBorder).
+		SetTitleColor(utils.Colors.MangaPageInfoViewTitle).
+		SetBackgroundColor(utils.Colors.Background)
+	p.Table.SetBordersColor(utils.Colors.MangaPageTableBorder).
+		SetTitleColor(utils.Colors.MangaPageTableTitle).
+		SetBackgroundColor(utils.Colors.Background)
+
+	colors := chapterColors()
+	for col, color := range colors {
+		p.Table.GetCell(0, col).SetTextColor(color)
+	}
+	for _, vol := range p.volumes {
+		if vol.header != nil {
+			vol.header[chapNumCol].SetTextColor(utils.Colors.MangaPageVolume)
+			vol.header[chapTitleCol].SetTextColor(utils.Colors.MangaPageVolume)
+		}
+		for _, cells := range vol.rows {
+			for col, cell := range cells {
+				cell.SetTextColor(colors[col]).SetBackgroundColor(utils.Colors.Background)
+			}
+		}
+	}
+	for row := range p.sWrap.Selection {
+		highlightCell(p.Table.GetCell(row, chapNumCol), true)
+	}
 }
 
 // markSelected : Mark a chapter as being selected by the user on the main page table.
 func (p *MangaPage) markSelected(row int) {
-	chapterCell := p.Table.GetCell(row, 0)
-	chapterCell.SetTextColor(tcell.ColorBlack).SetBackgroundColor(utils.MangaPageHighlightColor)
+	highlightCell(p.Table.GetCell(row, chapNumCol), true)
 
 	// Add to the Selection wrapper
 	p.sWrap.AddSelection(row)
+	p.setTableTitle()
 }
 
 // markUnselected : Mark a chapter as being unselected by the user on the main page table.
 func (p *MangaPage) markUnselected(row int) {
-	chapterCell := p.Table.GetCell(row, 0)
-	chapterCell.SetTextColor(utils.MangaPageChapNumColor).SetBackgroundColor(tcell.ColorBlack)
+	highlightCell(p.Table.GetCell(row, chapNumCol), false)
 
 	// Remove from the Selection wrapper
 	p.sWrap.RemoveSelection(row)
+	p.setTableTitle()
 }
 
-// markAll : Marks All rows as selected or unselected.
+// markAll : Marks All rows as selected or unselected. All volumes are expanded, so that every chapter can be seen.
 func (p *MangaPage) markAll() {
-	if p.sWrap.All {
-		for row := 1; row < p.Table.GetRowCount(); row++ {
-			p.markUnselected(row)
+	for _, vol := range p.volumes {
+		vol.collapsed = false
+	}
+	p.renderChapters()
+
+	for row := 1; row < p.Table.GetRowCount(); row++ {
+		if p.getVolumeNode(row) != nil {
+			continue
 		}
-	} else {
-		for row := 1; row < p.Table.GetRowCount(); row++ {
+		if p.sWrap.All {
+			p.markUnselected(row)
+		} else {
 			p.markSelected(row)
 		}
 	}
 	p.sWrap.All = !p.sWrap.All
 }
+
+// invertSelection : Selects every chapter that is not selected, and unselects every chapter that is.
+// All volumes are expanded, so that every chapter can be seen.
+func (p *MangaPage) invertSelection() {
+	for _, vol := range p.volumes {
+		vol.collapsed = false
+	}
+	p.renderChapters()
+
+	all := true
+	for row := 1; row < p.Table.GetRowCount(); row++ {
+		if p.getVolumeNode(row) != nil {
+			continue
+		}
+		if p.sWrap.HasSelection(row) {
+			p.markUnselected(row)
+			all = false
+		} else {
+			p.markSelected(row)
+		}
+	}
+	p.sWrap.All = all
+}
+
+// startRange : Start selecting a range of chapters from a row. No-op if a range is already being selected.
+func (p *MangaPage) startRange(row int, mode rangeMode) {
+	if p.rangeSel != nil || row < 1 || row >= p.Table.GetRowCount() {
+		return
+	}
+	base := map[*tview.TableCell]struct{}{}
+	for se := range p.sWrap.Selection {
+		base[p.Table.GetCell(se, chapNumCol)] = struct{}{}
+	}
+	p.rangeSel = &rangeSelection{
+		mode:   mode,
+		anchor: p.Table.GetCell(row, chapNumCol),
+		base:   base,
+	}
+	p.extendRange(row)
+}
+
+// extendRange : Update the selection so that the range covers every chapter between its anchor and a row.
+// The range ends if its anchor is no longer shown, such as when its volume is collapsed.
+func (p *MangaPage) extendRange(cursor int) {
+	if p.rangeSel == nil {
+		return
+	}
+	anchor := -1
+	for row := 1; row < p.Table.GetRowCount(); row++ {
+		if p.Table.GetCell(row, chapNumCol) == p.rangeSel.anchor {
+			anchor = row
+			break
+		}
+	}
+	if anchor == -1 {
+		p.endRange()
+		return
+	}
+
+	low, high := anchor, cursor
+	if low > high {
+		low, high = high, low
+	}
+	for row := 1; row < p.Table.GetRowCount(); row++ {
+		if p.getVolumeNode(row) != nil {
+			continue
+		}
+		_, inBase := p.rangeSel.base[p.Table.GetCell(row, chapNumCol)]
+		want := inBase || (row >= low && row <= high)
+		if want && !p.sWrap.HasSelection(row) {
+			p.markSelected(row)
+		} else if !want && p.sWrap.HasSelection(row) {
+			p.markUnselected(row)
+		}
+	}
+}
+
+// endRange : Stop selecting a range of chapters. The chapters in the range stay selected.
+func (p *MangaPage) endRange() {
+	p.rangeSel = nil
+	p.setTableTitle()
+}
@@ -5,48 +5,99 @@ import (
 
 	"github.com/darylhjd/mangadesk/app/core"
 	"github.com/darylhjd/mangadesk/app/ui/utils"
+	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
 )
 
 // ShowModal : Make the app show a modal.
-func ShowModal(id string, modal *tview.Modal) {
+func ShowModal(id string, modal tview.Primitive) {
 	core.App.TView.SetFocus(modal)
 	core.App.PageHolder.AddPage(id, modal, true, true)
 }
 
-// okModal : Creates a new modal with an "OK" acknowledgement button.
-func okModal(id, text string) *tview.Modal {
+// confirmModal : Creates a new modal for confirmation.
+// The user specifies the function to do when confirming.
+// If the user cancels, then the modal is removed from the view.
+func confirmModal(id, text, confirmButton string, f func()) *tview.Modal {
+	// Create new modal
 	modal := tview.NewModal()
 
 	// Set modal attributes
 	modal.SetText(text).
-		SetBackgroundColor(utils.ModalColor).
-		AddButtons([]string{"OK"}).
+		SetBackgroundColor(utils.Colors.Modal).
+		AddButtons([]string{confirmButton, "Cancel"}).
 		SetFocus(0).
-		SetDoneFunc(func(_ int, _ string) {
+		SetDoneFunc(func(buttonIndex int, _ string) {
+			if buttonIndex == 0 {
+				f()
+			}
+			log.Printf("Removing %s modal\n", id)
 			core.App.PageHolder.RemovePage(id)
 		})
 	return modal
 }
 
-// confirmModal : Creates a new modal for confirmation.
-// The user specifies the function to do when confirming.
-// If the user cancels, then the modal is removed from the view.
-func confirmModal(id, text, confirmButton string, f func()) *tview.Modal {
-	// Create new modal
+// choiceModal : Creates a new modal with a button for each choice, and a "Cancel" button.
+// The user specifies the function to do with the chosen choice.
+func choiceModal(id, text string, choices []string, f func(choice string)) *tview.Modal {
 	modal := tview.NewModal()
 
 	// Set modal attributes
 	modal.SetText(text).
-		SetBackgroundColor(utils.ModalColor).
-		AddButtons([]string{confirmButton, "Cancel"}).
+		SetBackgroundColor(utils.Colors.Modal).
+		AddButtons(append(choices, "Cancel")).
 		SetFocus(0).
 		SetDoneFunc(func(buttonIndex int, _ string) {
-			if buttonIndex == 0 {
-				f()
-			}
 			log.Printf("Removing %s modal\n", id)
 			core.App.PageHolder.RemovePage(id)
+			if buttonIndex >= 0 && buttonIndex < len(choices) {
+				f(choices[buttonIndex])
+			}
 		})
 	return modal
 }
+
+// inputModal : Creates a new modal with an input field and some help text.
+// The user specifies the function to do with the input text when confirming.
+// If the user cancels, then the modal is removed from the view.
+func inputModal(id, title, label, help string, f func(text string)) *tview.Grid {
+	form := tview.NewForm()
+
+	input := tview.NewInputField().SetLabel(label)
+	confirm := func() {
+		log.Printf("Removing %s modal\n", id)
+		core.App.PageHolder.RemovePage(id)
+		f(input.GetText())
+	}
+	cancel := func() {
+		log.Printf("Removing %s modal\n", id)
+		core.App.PageHolder.RemovePage(id)
+	}
+	// Allow the user to confirm directly from the input field.
+	input.SetDoneFunc(func(key tcell.Key) {
+		switch key {
+		case tcell.KeyEnter:
+			confirm()
+		case tcell.KeyEsc:
+			cancel()
+		}
+	})
+
+	// Set form attributes
+	form.AddFormItem(input).
+		AddTextView("", help, 0, 4, true, false).
+		AddButton("OK", confirm).
+		AddButton("Cancel", cancel).
+		SetCancelFunc(cancel).
+		SetButtonsAlign(tview.AlignCenter).
+		SetLabelColor(utils.Colors.InputModalLabel).
+		SetBackgroundColor(utils.Colors.Modal).
+		SetTitle(title).
+		SetBorder(true)
+
+	// Create a grid to align the form to the center.
+	dimensions := []int{-1, -2, -1}
+	grid := utils.NewGrid(dimensions, dimensions).
+		AddItem(form, 1, 1, 1, 1, 0, 0, true)
+	return grid
+}
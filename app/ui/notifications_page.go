@@ -0,0 +1,74 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rivo/tview"
+
+	"github.com/darylhjd/mangadesk/app/ui/utils"
+)
+
+// NotificationsPage : This struct contains the grid for the notifications page, which lists the messages shown
+// to the user as toasts, most recent first.
+type NotificationsPage struct {
+	Grid *tview.Grid
+	Text *tview.TextView
+}
+
+// ShowNotificationsPage : Make the app show the notifications page.
+func ShowNotificationsPage() {
+	// Do not show the notifications page on top of itself.
+	if router.currentID() == utils.NotificationsPageID {
+		return
+	}
+	notificationsPage := newNotificationsPage()
+	router.push(utils.NotificationsPageID, notificationsPage.Grid, notificationsPage.Grid)
+}
+
+// newNotificationsPage : Creates a new notifications page.
+func newNotificationsPage() *NotificationsPage {
+	text := tview.NewTextView()
+	// Set TextView attributes.
+	text.SetDynamicColors(true).
+		SetWrap(true).
+		SetTitle(fmt.Sprintf("Notifications (%d)", len(statusBar.notifications))).
+		SetBorder(true)
+
+	dimensions := []int{-1}
+	grid := utils.NewGrid(dimensions, dimensions).
+		AddItem(text, 0, 0, 1, 1, 0, 0, true)
+
+	notificationsPage := &NotificationsPage{
+		Grid: grid,
+		Text: text,
+	}
+	notificationsPage.setHandlers()
+	themePage(grid, notificationsPage.setColors)
+
+	return notificationsPage
+}
+
+// setColors : Apply the current theme to the notifications page. The colour of each notification depends on
+// whether it is an error, so the text is rebuilt with the current colours.
+func (p *NotificationsPage) setColors() {
+	p.Text.SetTextColor(utils.Colors.Text).
+		SetBorderColor(utils.Colors.NotificationsPageBorder).
+		SetBackgroundColor(utils.Colors.Background)
+	p.Grid.SetBackgroundColor(utils.Colors.Background)
+
+	var text strings.Builder
+	if len(statusBar.notifications) == 0 {
+		text.WriteString("No notifications yet.")
+	}
+	for i := len(statusBar.notifications) - 1; i >= 0; i-- {
+		n := statusBar.notifications[i]
+		color := utils.Colors.ToastInfo
+		if n.isError {
+			color = utils.Colors.ToastError
+		}
+		text.WriteString(fmt.Sprintf("[%s]%s[-]  %s\n",
+			color.String(), n.time.Format("15:04:05"), tview.Escape(n.text)))
+	}
+	p.Text.SetText(text.String())
+}
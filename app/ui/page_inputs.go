@@ -2,8 +2,10 @@ package ui
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"math"
+	"reflect"
 
 	"github.com/darylhjd/mangadesk/app/ui/utils"
 
@@ -19,20 +21,21 @@ func SetUniversalHandlers() {
 	// Enable mouse inputs.
 	core.App.TView.EnableMouse(true)
 
-	// Set universal keybindings
-	core.App.TView.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
-		switch event.Key() {
-		case tcell.KeyCtrlL: // Login/Logout
-			ctrlLInput()
-		case tcell.KeyCtrlK: // Help page.
-			ctrlKInput()
-		case tcell.KeyCtrlS: // Search page.
-			ctrlSInput()
-		case tcell.KeyCtrlC: // Ctrl-C interrupt.
-			ctrlCInput()
-		}
-		return event // Forward the event to the actual current primitive.
-	})
+	// Set universal keybindings. Other keys are forwarded to the actual current primitive.
+	handlers := map[string]func(event *tcell.EventKey) *tcell.EventKey{
+		loginAction:         consume(ctrlLInput),
+		helpAction:          consume(ctrlKInput),
+		searchAction:        consume(ctrlSInput),
+		quitAction:          consume(ctrlCInput),
+		paletteAction:       consume(ctrlPInput),
+		backAction:          consume(altLeftInput),
+		forwardAction:       consume(altRightInput),
+		notificationsAction: consume(ctrlWInput),
+		settingsAction:      consume(f2Input),
+		profileAction:       consume(f3Input),
+	}
+	registerCommands(nil, handlers)
+	core.App.TView.SetInputCapture(handleKeys(handlers))
 }
 
 // ctrlLInput : Enables user to toggle login/logout.
@@ -45,19 +48,19 @@ func ctrlLInput() {
 
 	// Create the modal to prompt user confirmation.
 	var modal *tview.Modal
+	auth := router.services.Auth
 	// Decide whether the modal is to log in or logout.
-	switch core.App.Client.Auth.IsLoggedIn() {
+	switch auth.LoggedIn() {
 	case true:
 		text := "Logout?\nStored credentials will be deleted."
 		modal = confirmModal(utils.LoginLogoutCfmModalID, text, "Logout", func() {
-			// Attempt to logout
-			if err := core.App.Client.Auth.Logout(); err != nil {
-				okM := okModal(utils.GenericAPIErrorModalID, "Error logging out!")
-				ShowModal(utils.GenericAPIErrorModalID, okM)
-				return
+			// Logout. Stored credentials are deleted, and the user is logged out of the app even if the session
+			// could not be ended on MangaDex.
+			if err := auth.Logout(context.Background()); err != nil {
+				core.LogErrorf("Error logging out: %s\n", err.Error())
+				notifyError("Logged out, but the session could not be ended on MangaDex. Check log for details.")
 			}
-			// If logged out successfully, then delete stored credentials and direct user to main page (guest).
-			core.App.DeleteCredentials()
+			// Direct user to main page (guest).
 			ShowMainPage()
 		})
 	case false:
@@ -90,136 +93,340 @@ func ctrlCInput() {
 	core.App.TView.Stop()
 }
 
+// ctrlPInput : Shows the command palette to the user.
+func ctrlPInput() {
+	ShowCommandPalette()
+}
+
+// altLeftInput : Allows user to go back to the previous page.
+func altLeftInput() {
+	router.goBack()
+}
+
+// altRightInput : Allows user to go forward to the page they last went back from.
+func altRightInput() {
+	router.goForward()
+}
+
+// ctrlWInput : Shows the notifications page to the user.
+func ctrlWInput() {
+	ShowNotificationsPage()
+}
+
+// f2Input : Shows the settings page to the user.
+func f2Input() {
+	ShowSettingsPage()
+}
+
+// f3Input : Lets the user switch to another profile.
+func f3Input() {
+	// Do not allow while another profile is being chosen.
+	if page, _ := core.App.PageHolder.GetFrontPage(); page == utils.ProfileModalID || page == utils.NewProfileModalID {
+		return
+	}
+	ShowProfileModal()
+}
+
 // setHandlers : Set handlers for the help page.
 func (p *HelpPage) setHandlers() {
 	// Set grid input captures.
-	p.Grid.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
-		switch event.Key() {
-		case tcell.KeyEsc:
-			core.App.PageHolder.RemovePage(utils.HelpPageID)
-		}
-		return event
+	back := func() {
+		router.goBack()
+	}
+	nav := newTextNavigator(p.Text, back)
+	handlers := map[string]func(event *tcell.EventKey) *tcell.EventKey{
+		helpBackAction: consume(back),
+	}
+	registerCommands(p.Grid, handlers)
+	p.Grid.SetInputCapture(nav.handleKeys(handlers))
+}
+
+// setHandlers : Set handlers for the notifications page.
+func (p *NotificationsPage) setHandlers() {
+	// Set grid input captures.
+	back := func() {
+		router.goBack()
+	}
+	nav := newTextNavigator(p.Text, back)
+	handlers := map[string]func(event *tcell.EventKey) *tcell.EventKey{
+		notificationsBackAction: consume(back),
+	}
+	registerCommands(p.Grid, handlers)
+	p.Grid.SetInputCapture(nav.handleKeys(handlers))
+}
+
+// setHandlers : Set handlers for the settings page.
+func (p *SettingsPage) setHandlers() {
+	// Set grid input captures.
+	handlers := map[string]func(event *tcell.EventKey) *tcell.EventKey{
+		settingsBackAction: consume(func() {
+			router.goBack()
+		}),
+	}
+	registerCommands(p.Grid, handlers)
+	p.Grid.SetInputCapture(handleKeys(handlers))
+
+	// Show the new settings if the configuration file is changed while the page is open.
+	onConfigChange(p.Grid, func(_, conf *core.UserConfig) {
+		p.problems = nil
+		p.fillForm(conf)
+		p.showProblems()
 	})
 }
 
 // setHandlers : Set handlers for the search page.
 func (p *SearchPage) setHandlers() {
 	// Set grid input captures.
-	p.Grid.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
-		switch event.Key() {
-		case tcell.KeyEsc: // When user presses ESC, then we remove the Search page.
-			core.App.PageHolder.RemovePage(utils.SearchPageID)
-		case tcell.KeyTab: // When user presses Tab, they are sent back to the search form.
+	gridHandlers := map[string]func(event *tcell.EventKey) *tcell.EventKey{
+		// When user goes back, then we return to the previous page.
+		searchBackAction: consume(func() {
+			router.goBack()
+		}),
+		// User is sent back to the search form.
+		searchFocusFormAction: consume(func() {
 			core.App.TView.SetFocus(p.Form)
-		}
-		return event
-	})
+		}),
+	}
+	p.Grid.SetInputCapture(handleKeys(gridHandlers))
 
 	// Set up input capture for the search bar.
-	p.Form.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
-		switch event.Key() {
-		case tcell.KeyDown: // When user presses KeyDown, they are sent to the search results table.
+	formHandlers := map[string]func(event *tcell.EventKey) *tcell.EventKey{
+		// User is sent to the search results table.
+		searchFocusResultsAction: func(event *tcell.EventKey) *tcell.EventKey {
 			core.App.TView.SetFocus(p.Table)
-		}
-		return event
-	})
+			return event
+		},
+	}
+	p.Form.SetInputCapture(handleKeys(formHandlers))
+
+	registerCommands(p.Grid, gridHandlers)
+	registerCommands(p.Grid, formHandlers)
 }
 
 // setHandlers : Set handlers for the main page.
 func (p *MainPage) setHandlers(cancel context.CancelFunc, searchParams *SearchParams) {
-	// Set table input captures.
-	p.Table.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
-		var reload bool
-		switch event.Key() {
-		// User wants to go to the next offset page.
-		case tcell.KeyCtrlF:
-			if p.CurrentOffset+offsetRange >= p.MaxOffset {
-				modal := okModal(utils.OffsetErrorModalID, "No more results to show.")
-				ShowModal(utils.OffsetErrorModalID, modal)
-			} else {
-				// Update the new offset
-				p.CurrentOffset += offsetRange
-			}
-			reload = true
-		case tcell.KeyCtrlB:
-			if p.CurrentOffset == 0 {
-				modal := okModal(utils.OffsetErrorModalID, "Already on first page.")
-				ShowModal(utils.OffsetErrorModalID, modal)
-			}
-			reload = true
-			// Update the new offset
-			p.CurrentOffset = int(math.Max(0, float64(p.CurrentOffset-offsetRange)))
+	reload := func() {
+		// Cancel any current loading, and create a new one.
+		cancel()
+		if searchParams != nil {
+			go p.setGuestTable(searchParams)
+		} else if !p.services.Auth.LoggedIn() {
+			go p.setGuestTable(nil)
+		} else {
+			go p.setLoggedTable()
 		}
+	}
 
-		if reload {
-			// Cancel any current loading, and create a new one.
-			cancel()
-			if searchParams != nil {
-				go p.setGuestTable(searchParams)
-			} else if !core.App.Client.Auth.IsLoggedIn() {
-				go p.setGuestTable(nil)
-			} else {
-				go p.setLoggedTable()
-			}
+	// Going back is only possible from the search page.
+	var back func()
+	if searchParams != nil {
+		back = func() {
+			router.goBack()
 		}
-		return event
+	}
+	nav := newTableNavigator(p.Table, back, func() {
+		row, _ := p.Table.GetSelection()
+		p.enterInput(row)
 	})
 
+	// Set table input captures.
+	handlers := map[string]func(event *tcell.EventKey) *tcell.EventKey{
+		mainNextPageAction: consume(func() { // User wants to go to the next offset page.
+			p.ctrlFInput()
+			reload()
+		}),
+		mainPrevPageAction: consume(func() { // User wants to go to the previous offset page.
+			p.ctrlBInput()
+			reload()
+		}),
+		mainSortNextAction:    consume(p.ctrlOInput), // User wants to sort by the next field.
+		mainSortReverseAction: consume(p.ctrlTInput), // User wants to reverse the sort order.
+	}
+	registerCommands(p.Grid, handlers)
+	onDiscard(p.Grid, cancel)
+	p.Table.SetInputCapture(nav.handleKeys(handlers))
+
+	// Popular manga depend on whether the user wants to see explicit content.
+	if searchParams == nil {
+		onConfigChange(p.Grid, func(old, conf *core.UserConfig) {
+			if old.ExplicitContent != conf.ExplicitContent && !p.services.Auth.LoggedIn() {
+				reload()
+			}
+		})
+	}
+
 	// Set table selected function.
 	p.Table.SetSelectedFunc(func(row, _ int) {
-		log.Printf("Selected row %d on main page.\n", row)
-		mangaRef := p.Table.GetCell(row, 0).GetReference()
-		if mangaRef == nil {
-			return
-		} else if manga, ok := mangaRef.(*mangodex.Manga); ok {
-			ShowMangaPage(manga)
-		}
+		p.enterInput(row)
 	})
 }
 
+// enterInput : Allows user to open the manga in a row.
+func (p *MainPage) enterInput(row int) {
+	log.Printf("Selected row %d on main page.\n", row)
+	mangaRef := p.Table.GetCell(row, 0).GetReference()
+	if mangaRef == nil {
+		return
+	} else if manga, ok := mangaRef.(*mangodex.Manga); ok {
+		ShowMangaPage(manga)
+	}
+}
+
+// ctrlFInput : Allows user to go to the next offset page.
+func (p *MainPage) ctrlFInput() {
+	if p.CurrentOffset+offsetRange >= p.MaxOffset {
+		notify("No more results to show.")
+	} else {
+		// Update the new offset
+		p.CurrentOffset += offsetRange
+	}
+}
+
+// ctrlBInput : Allows user to go to the previous offset page.
+func (p *MainPage) ctrlBInput() {
+	if p.CurrentOffset == 0 {
+		notify("Already on first page.")
+	}
+	// Update the new offset
+	p.CurrentOffset = int(math.Max(0, float64(p.CurrentOffset-offsetRange)))
+}
+
+// ctrlOInput : Allows user to sort the manga table by the next field.
+func (p *MainPage) ctrlOInput() {
+	p.sorter.NextField()
+	p.sortTable()
+}
+
+// ctrlTInput : Allows user to reverse the sort order of the manga table.
+func (p *MainPage) ctrlTInput() {
+	p.sorter.ToggleDirection()
+	p.sortTable()
+}
+
 // setHandlers : Set handlers for the manga page.
 func (p *MangaPage) setHandlers(cancel context.CancelFunc) {
 	// Set grid input captures.
-	p.Grid.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
-		switch event.Key() {
-		case tcell.KeyEsc:
-			cancel()
-			core.App.PageHolder.RemovePage(utils.MangaPageID)
-		}
-		return event
+	back := func() {
+		p.escInput()
+	}
+	gridHandlers := map[string]func(event *tcell.EventKey) *tcell.EventKey{
+		mangaBackAction: consume(back),
+	}
+	p.Grid.SetInputCapture(handleKeys(gridHandlers))
+
+	// Extend the range of chapters being selected as the cursor moves.
+	p.Table.SetSelectionChangedFunc(func(row, _ int) {
+		p.extendRange(row)
+	})
+
+	// Set table mouse captures, for selecting ranges of chapters with the mouse.
+	p.Table.SetMouseCapture(func(action tview.MouseAction, event *tcell.EventMouse) (tview.MouseAction, *tcell.EventMouse) {
+		return p.mouseInput(action, event)
 	})
 
 	// Set table selected function.
+	// This is also called when the user clicks on the row under the cursor.
 	p.Table.SetSelectedFunc(func(row, _ int) {
-		log.Println("Creating and showing confirm download modal...")
-		modal := confirmModal(utils.DownloadChaptersModalID, "Download chapter(s)?", "Yes", func() {
-			// Create a copy of the Selection.
-			selected := p.sWrap.CopySelection(row)
-			// Download selected chapters.
-			go p.downloadChapters(selected, 0)
-		})
-		ShowModal(utils.DownloadChaptersModalID, modal)
+		p.enterInput(row)
+	})
+
+	// In vim mode, opening a volume expands it.
+	nav := newTableNavigator(p.Table, back, func() {
+		row, _ := p.Table.GetSelection()
+		if vol := p.getVolumeNode(row); vol != nil {
+			p.setVolumeCollapsed(vol, false)
+		}
 	})
 
 	// Set table input captures.
+	tableHandlers := map[string]func(event *tcell.EventKey) *tcell.EventKey{
+		mangaSelectAction:       consume(p.ctrlEInput), // User selects this manga row.
+		mangaSelectAllAction:    consume(p.ctrlAInput), // User wants to toggle select All.
+		mangaSelectRangeAction:  consume(p.ctrlGInput), // User wants to select a range of chapters.
+		mangaVisualModeAction:   consume(p.ctrlVInput), // User wants to toggle visual mode.
+		mangaInvertAction:       consume(p.ctrlNInput), // User wants to invert the Selection.
+		mangaToggleReadAction:   consume(p.ctrlRInput), // User wants to toggle read status for Selection.
+		mangaToggleFollowAction: consume(p.ctrlQInput), // User wants to toggle following the manga.
+		mangaSortNextAction:     consume(p.ctrlOInput), // User wants to sort by the next field.
+		mangaSortReverseAction:  consume(p.ctrlTInput), // User wants to reverse the sort order.
+		mangaExtendUpAction: consume(func() { // User wants to select a range of chapters.
+			p.shiftInput(-1)
+		}),
+		mangaExtendDownAction: consume(func() {
+			p.shiftInput(1)
+		}),
+		mangaDownloadAction: consume(func() { // User wants to download the Selection.
+			row, _ := p.Table.GetSelection()
+			p.enterInput(row)
+		}),
+		mangaCollapseAction: p.leftInput,  // User wants to collapse a volume.
+		mangaExpandAction:   p.rightInput, // User wants to expand a volume.
+	}
+	handler := nav.handleKeys(tableHandlers)
+	registerCommands(p.Grid, gridHandlers)
+	registerCommands(p.Grid, tableHandlers)
+	onDiscard(p.Grid, cancel)
+	onConfigChange(p.Grid, func(old, conf *core.UserConfig) {
+		p.configInput(cancel, old, conf)
+	})
 	p.Table.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
-		switch event.Key() {
-		case tcell.KeyCtrlE: // User selects this manga row.
-			p.ctrlEInput()
-		case tcell.KeyCtrlA: // User wants to toggle select All.
-			p.ctrlAInput()
-		case tcell.KeyCtrlR: // User wants to toggle read status for Selection.
-			p.ctrlRInput()
-		case tcell.KeyCtrlQ:
-			p.ctrlQInput()
+		// A range selected with Shift ends when a key is pressed without Shift.
+		if p.rangeSel != nil && p.rangeSel.mode == shiftRange && event.Modifiers()&tcell.ModShift == 0 {
+			p.endRange()
 		}
-		return event
+		return handler(event)
 	})
 }
 
+// configInput : Fetches the chapters again when the configuration changes which chapters are shown, or how their
+// download status is found.
+func (p *MangaPage) configInput(cancel context.CancelFunc, old, conf *core.UserConfig) {
+	if reflect.DeepEqual(old.Languages, conf.Languages) &&
+		reflect.DeepEqual(old.PreferredGroups, conf.PreferredGroups) &&
+		reflect.DeepEqual(old.BlockedGroups, conf.BlockedGroups) &&
+		old.DownloadDir == conf.DownloadDir && old.AsZip == conf.AsZip && old.ZipType == conf.ZipType {
+		return
+	}
+	log.Println("Configuration changed, reloading chapters...")
+	// Cancel any current loading. Selections are cleared once the chapters are replaced.
+	cancel()
+	p.endRange()
+	p.sWrap.All = false
+	go p.setChapterTable()
+}
+
+// escInput : Allows user to go back from the manga page, or leave visual mode if they are in it.
+// The page is kept, so that the user can go forward to it again.
+func (p *MangaPage) escInput() {
+	if p.rangeSel != nil && p.rangeSel.mode == visualRange {
+		p.endRange()
+		return
+	}
+	router.goBack()
+}
+
+// enterInput : Allows user to download the Selection, or the chapter in a row if there is no Selection.
+func (p *MangaPage) enterInput(row int) {
+	log.Println("Creating and showing confirm download modal...")
+	modal := confirmModal(utils.DownloadChaptersModalID, "Download chapter(s)?", "Yes", func() {
+		// Get the chapters in a copy of the Selection.
+		// Duplicate chapters from preferred scanlation groups take precedence.
+		selected := p.preferredChapters(rowChapters(p.getChapterRows(p.sWrap.CopySelection(row))))
+		p.setTableTitle()
+		// Download selected chapters.
+		go p.downloadChapters(selected, 0)
+	})
+	ShowModal(utils.DownloadChaptersModalID, modal)
+}
+
 // ctrlEInput : Enables user to select a chapter table row without activating the select action.
+// If the row is a volume, all chapters in the volume are selected.
 func (p *MangaPage) ctrlEInput() {
 	row, _ := p.Table.GetSelection()
+	if vol := p.getVolumeNode(row); vol != nil {
+		p.markVolume(vol)
+		return
+	}
 	// If the row is already in the Selection, we deselect. Else, we add.
 	if p.sWrap.HasSelection(row) {
 		p.markUnselected(row)
@@ -228,6 +435,73 @@ func (p *MangaPage) ctrlEInput() {
 	}
 }
 
+// shiftInput : Allows user to select a range of chapters by moving the cursor up (-1) or down (1) while
+// holding Shift.
+func (p *MangaPage) shiftInput(direction int) {
+	row, _ := p.Table.GetSelection()
+	p.startRange(row, shiftRange)
+	if next := row + direction; next >= 1 && next < p.Table.GetRowCount() {
+		p.Table.Select(next, 0)
+	}
+}
+
+// ctrlVInput : Allows user to enter or leave visual mode, where moving the cursor selects a range of chapters.
+func (p *MangaPage) ctrlVInput() {
+	if p.rangeSel != nil && p.rangeSel.mode == visualRange {
+		p.endRange()
+		return
+	}
+	// Visual mode takes over any other range being selected.
+	p.rangeSel = nil
+	row, _ := p.Table.GetSelection()
+	p.startRange(row, visualRange)
+	p.setTableTitle()
+}
+
+// ctrlNInput : Allows user to invert the Selection.
+func (p *MangaPage) ctrlNInput() {
+	p.invertSelection()
+}
+
+// mouseInput : Allows user to select a range of chapters by dragging the mouse, or by clicking on a row
+// while holding Shift to select every chapter between the cursor and that row.
+func (p *MangaPage) mouseInput(action tview.MouseAction, event *tcell.EventMouse) (tview.MouseAction, *tcell.EventMouse) {
+	row, _ := p.Table.CellAt(event.Position())
+	switch action {
+	case tview.MouseLeftDown:
+		p.dragStart = 0
+		if row > 0 {
+			p.dragStart = row
+		}
+	case tview.MouseMove:
+		if event.Buttons()&tcell.Button1 == 0 || p.dragStart == 0 || row < 1 {
+			break
+		}
+		if row != p.dragStart {
+			p.startRange(p.dragStart, dragRange)
+		}
+		if p.rangeSel != nil {
+			p.Table.Select(row, 0)
+		}
+	case tview.MouseLeftUp:
+		p.dragStart = 0
+		if p.rangeSel != nil && p.rangeSel.mode == dragRange {
+			p.endRange()
+		}
+	case tview.MouseLeftClick:
+		if event.Modifiers()&tcell.ModShift == 0 || row < 1 {
+			break
+		}
+		cursor, _ := p.Table.GetSelection()
+		if p.rangeSel == nil {
+			p.startRange(cursor, shiftRange)
+			p.extendRange(row)
+			p.endRange()
+		}
+	}
+	return action, event
+}
+
 // ctrlAInput : Enables user to select/deselect ALL chapters at once.
 func (p *MangaPage) ctrlAInput() {
 	// Toggle Selection.
@@ -239,9 +513,15 @@ func (p *MangaPage) ctrlRInput() {
 	modal := confirmModal(utils.ToggleReadChapterModalID,
 		"Toggle read status for selected chapter(s)?", "Toggle", func() {
 			row, _ := p.Table.GetSelection()
-			selected := p.sWrap.CopySelection(row)
+			selected := rowChapters(p.getChapterRows(p.sWrap.CopySelection(row)))
+			p.setTableTitle()
+			// The read markers are copied, as the request is sent in the background.
+			readSet := make(map[string]struct{}, len(p.read))
+			for id := range p.read {
+				readSet[id] = struct{}{}
+			}
 			// Toggle read markers
-			go p.toggleReadMarkers(selected)
+			go p.toggleReadMarkers(selected, readSet)
 		})
 	ShowModal(utils.ToggleReadChapterModalID, modal)
 }
@@ -250,3 +530,56 @@ func (p *MangaPage) ctrlRInput() {
 func (p *MangaPage) ctrlQInput() {
 	go p.toggleFollowManga()
 }
+
+// ctrlOInput : Allows user to sort the chapter table by the next field.
+func (p *MangaPage) ctrlOInput() {
+	p.sorter.NextField()
+	p.renderChapters()
+}
+
+// ctrlTInput : Allows user to reverse the sort order of the chapter table.
+func (p *MangaPage) ctrlTInput() {
+	p.sorter.ToggleDirection()
+	p.renderChapters()
+}
+
+// ctrlGInput : Allows user to select chapters matching a chapter range expression.
+func (p *MangaPage) ctrlGInput() {
+	help := "Examples: [yellow]1-20, 35, 40.5, vol:3, lang:fr, group:\"Name\"[-]\n" +
+		"Separate terms with spaces to match all of them, e.g. [yellow]1-20 unread[-].\n" +
+		"Other terms: [yellow]read, unread, downloaded, all, vol:none[-]. Negate a term with [yellow]![-]."
+	modal := inputModal(utils.SelectRangeModalID, "Select Chapters", "Chapters: ", help, func(text string) {
+		count, err := p.selectRange(text)
+		if err != nil {
+			log.Printf("Invalid chapter range \"%s\": %s\n", text, err.Error())
+			notifyError(fmt.Sprintf("Invalid chapter range: %s", err.Error()))
+		} else if count == 0 {
+			notify("No chapters matched.")
+		} else {
+			notify(fmt.Sprintf("Selected %d chapter(s).", count))
+		}
+	})
+	ShowModal(utils.SelectRangeModalID, modal)
+}
+
+// leftInput : Allows user to collapse the volume under the cursor.
+// The event is forwarded to the table if the cursor is not on a volume.
+func (p *MangaPage) leftInput(event *tcell.EventKey) *tcell.EventKey {
+	row, _ := p.Table.GetSelection()
+	if vol := p.getVolumeNode(row); vol != nil {
+		p.setVolumeCollapsed(vol, true)
+		return nil
+	}
+	return event
+}
+
+// rightInput : Allows user to expand the volume under the cursor.
+// The event is forwarded to the table if the cursor is not on a volume.
+func (p *MangaPage) rightInput(event *tcell.EventKey) *tcell.EventKey {
+	row, _ := p.Table.GetSelection()
+	if vol := p.getVolumeNode(row); vol != nil {
+		p.setVolumeCollapsed(vol, false)
+		return nil
+	}
+	return event
+}
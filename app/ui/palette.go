@@ -0,0 +1,186 @@
+package ui
+
+import (
+	"log"
+	"sort"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+
+	"github.com/darylhjd/mangadesk/app/core"
+	"github.com/darylhjd/mangadesk/app/ui/utils"
+)
+
+// paletteCommand : A command that can be run from the command palette.
+type paletteCommand struct {
+	title string // Shown in the palette, and matched against what the user types.
+	keys  string // The keys bound to the command, if any.
+	run   func()
+}
+
+// pageCommands : The handlers of the actions of each page, by the root primitive of the page and then by action
+// name. Handlers of universal actions are under nil. These are the actions offered by the command palette.
+var pageCommands = map[tview.Primitive]map[string]func(event *tcell.EventKey) *tcell.EventKey{}
+
+// registerCommands : Make the handlers of actions of a page available in the command palette, along with any
+// handlers already registered for the page.
+func registerCommands(page tview.Primitive, handlers map[string]func(event *tcell.EventKey) *tcell.EventKey) {
+	pageMutex.Lock()
+	defer pageMutex.Unlock()
+	if pageCommands[page] == nil {
+		pageCommands[page] = map[string]func(event *tcell.EventKey) *tcell.EventKey{}
+	}
+	for name, handler := range handlers {
+		pageCommands[page][name] = handler
+	}
+}
+
+// getPaletteCommands : Get the commands that can be run from the current page. These are the universal
+// actions, the actions of the page, and commands that are not bound to keys.
+func getPaletteCommands() []*paletteCommand {
+	front, frontPage := core.App.PageHolder.GetFrontPage()
+	pageMutex.Lock()
+	defer pageMutex.Unlock()
+
+	var commands []*paletteCommand
+	for _, scope := range keymap.Scopes() {
+		for _, action := range scope.Actions {
+			handler, ok := pageCommands[nil][action.Name]
+			if !ok {
+				handler, ok = pageCommands[frontPage][action.Name]
+			}
+			if !ok || action.Name == paletteAction {
+				continue
+			}
+			commands = append(commands, &paletteCommand{
+				title: scope.Title + ": " + action.Description,
+				keys:  keymap.Describe(action.Name),
+				run: func() {
+					handler(nil)
+				},
+			})
+		}
+	}
+
+	// Commands that are not bound to keys.
+	if front != utils.LoginPageID {
+		title := "Go to popular manga"
+		if router.services.Auth.LoggedIn() {
+			title = "Go to followed manga"
+		}
+		commands = append(commands, &paletteCommand{title: title, run: func() {
+			// Return to the main page as it was left, if the user came from it.
+			if !router.backTo(utils.MainPageID) {
+				ShowMainPage()
+			}
+		}})
+	}
+	commands = append(commands,
+		&paletteCommand{title: "Reload theme", run: func() { applyTheme(readTheme()) }},
+		&paletteCommand{title: "Reload keybindings", run: LoadKeybindings},
+		&paletteCommand{title: "Reload configuration", run: reloadConfiguration},
+	)
+	return commands
+}
+
+// ShowCommandPalette : Make the app show the command palette, which lists the commands that can be run from the
+// current page. The user types to filter the commands, and presses Enter to run the selected one.
+func ShowCommandPalette() {
+	// Do not allow the palette to be opened on top of itself.
+	if page, _ := core.App.PageHolder.GetFrontPage(); page == utils.PaletteModalID {
+		return
+	}
+	commands := getPaletteCommands()
+
+	input := tview.NewInputField().
+		SetLabel("> ").
+		SetLabelColor(utils.Colors.InputModalLabel)
+	list := tview.NewTable().
+		SetSelectable(true, false)
+
+	// Show the commands matching what the user typed, best matches first.
+	var shown []*paletteCommand
+	filter := func(text string) {
+		type match struct {
+			command *paletteCommand
+			score   int
+		}
+		var matches []match
+		for _, command := range commands {
+			if score, ok := utils.FuzzyMatch(text, command.title); ok {
+				matches = append(matches, match{command: command, score: score})
+			}
+		}
+		sort.SliceStable(matches, func(i, j int) bool {
+			return matches[i].score > matches[j].score
+		})
+
+		list.Clear()
+		shown = nil
+		for row, m := range matches {
+			shown = append(shown, m.command)
+			list.SetCell(row, 0, tview.NewTableCell(m.command.title).SetExpansion(1)).
+				SetCell(row, 1, tview.NewTableCell(m.command.keys).
+					SetAlign(tview.AlignRight).
+					SetTextColor(utils.Colors.InputModalLabel))
+		}
+		if len(shown) == 0 {
+			list.SetCell(0, 0, tview.NewTableCell("No matching commands.").SetSelectable(false))
+		}
+		list.Select(0, 0).ScrollToBeginning()
+	}
+
+	closePalette := func() {
+		core.App.PageHolder.RemovePage(utils.PaletteModalID)
+	}
+	run := func(row int) {
+		if row < 0 || row >= len(shown) {
+			return
+		}
+		closePalette()
+		log.Printf("Running command from palette: %s\n", shown[row].title)
+		shown[row].run()
+	}
+
+	// The cursor of the list is moved while the user is typing into the input field.
+	input.SetChangedFunc(filter).
+		SetDoneFunc(func(key tcell.Key) {
+			switch key {
+			case tcell.KeyEnter:
+				row, _ := list.GetSelection()
+				run(row)
+			case tcell.KeyEsc:
+				closePalette()
+			}
+		}).
+		SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+			row, _ := list.GetSelection()
+			switch event.Key() {
+			case tcell.KeyUp:
+				list.Select(max(row-1, 0), 0)
+			case tcell.KeyDown:
+				list.Select(min(row+1, max(len(shown)-1, 0)), 0)
+			default:
+				return event
+			}
+			return nil
+		})
+	list.SetSelectedFunc(func(row, _ int) {
+		run(row)
+	})
+	filter("")
+
+	palette := utils.NewGrid([]int{1, -1}, []int{-1}).
+		AddItem(input, 0, 0, 1, 1, 0, 0, true).
+		AddItem(list, 1, 0, 1, 1, 0, 0, false)
+	palette.SetBackgroundColor(utils.Colors.Modal).
+		SetTitle("Command Palette").
+		SetBorder(true)
+	input.SetBackgroundColor(utils.Colors.Modal)
+	list.SetBackgroundColor(utils.Colors.Modal)
+
+	// Create a grid to align the palette to the center.
+	grid := utils.NewGrid([]int{-1, -3, -1}, []int{-1, -2, -1}).
+		AddItem(palette, 1, 1, 1, 1, 0, 0, true)
+	ShowModal(utils.PaletteModalID, grid)
+}
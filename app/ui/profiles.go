@@ -0,0 +1,103 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/darylhjd/mangadesk/app/backend"
+	"github.com/darylhjd/mangadesk/app/core"
+	"github.com/darylhjd/mangadesk/app/ui/utils"
+)
+
+// newProfileChoice : The choice for creating a profile, among the names of the profiles. It cannot be a profile
+// name itself.
+const newProfileChoice = "+ New"
+
+// ShowProfileModal : Let the user switch to another profile, or create one.
+func ShowProfileModal() {
+	text := fmt.Sprintf("Switch profile?\nYou are using the %s profile.", core.Profile())
+	modal := choiceModal(utils.ProfileModalID, text, profileChoices(), chooseProfile)
+	ShowModal(utils.ProfileModalID, modal)
+}
+
+// profileChoices : The names of every profile, followed by the choice for creating one.
+func profileChoices() []string {
+	return append(core.Profiles(), newProfileChoice)
+}
+
+// chooseProfile : Switch to a profile chosen by the user, or ask for the name of a new one.
+func chooseProfile(choice string) {
+	if choice == newProfileChoice {
+		askNewProfile()
+		return
+	}
+	switchProfile(choice)
+}
+
+// askNewProfile : Ask the user for the name of a new profile, and switch to it.
+func askNewProfile() {
+	help := "Letters, numbers, dashes and underscores. The profile has its own login, settings and downloads."
+	modal := inputModal(utils.NewProfileModalID, "New Profile", "Name: ", help, func(text string) {
+		name := strings.TrimSpace(text)
+		if err := core.ValidateProfileName(name); err != nil {
+			notifyError(fmt.Sprintf("Invalid profile name: %s.", err.Error()))
+			return
+		}
+		switchProfile(name)
+	})
+	ShowModal(utils.NewProfileModalID, modal)
+}
+
+// switchProfile : Switch to another profile without restarting. Its configuration is applied, the services are
+// created again with a new client, and the user is logged in with the stored session of the profile, if it has
+// one. The pages are then created again with the new services, clearing the navigation history, as its pages were
+// for the previous profile.
+func switchProfile(name string) {
+	if name == core.Profile() {
+		return
+	}
+	old := core.App.Config
+	if err := core.App.SwitchProfile(name); err != nil {
+		core.LogErrorf("Error switching profile: %s\n", err.Error())
+		notifyError(fmt.Sprintf("Could not switch profile: %s.", err.Error()))
+		return
+	}
+	// The previous session is kept as it is, so that it is resumed when the user switches back.
+	router.services.Auth.OnSessionEnded(nil)
+	router.services = router.newServices()
+	auth := router.services.Auth
+	WatchSession()
+	configChanged(old, core.App.Config)
+	WatchConfiguration()
+	statusBar.setUser("Switching profile...")
+	notify(fmt.Sprintf("Switched to profile %s.", name))
+
+	go func() {
+		loggedIn := resumeSession(auth)
+		core.App.TView.QueueUpdateDraw(func() {
+			if loggedIn || core.App.Config.GuestMode {
+				ShowMainPage()
+			} else {
+				ShowLoginPage()
+			}
+		})
+	}()
+}
+
+// resumeSession : Log in with the stored session of the profile, unless it uses guest mode. Returns whether the
+// user is logged in.
+func resumeSession(auth *backend.Auth) bool {
+	if core.App.Config.GuestMode {
+		return false
+	}
+	err := auth.Resume(context.Background())
+	if err != nil && !errors.Is(err, backend.ErrNoSession) {
+		core.LogErrorf("Error restoring session: %s\n", err.Error())
+		core.App.TView.QueueUpdateDraw(func() {
+			notifyError(fmt.Sprintf("Unable to restore session: %s.", err.Error()))
+		})
+	}
+	return err == nil
+}
@@ -0,0 +1,171 @@
+package ui
+
+import (
+	"log"
+	"sync"
+
+	"github.com/rivo/tview"
+
+	"github.com/darylhjd/mangadesk/app/backend"
+	"github.com/darylhjd/mangadesk/app/core"
+)
+
+// navEntry : A page in the navigation history. The page is kept as it is, so the table selection, offsets and
+// search parameters are the same when the user returns to it.
+type navEntry struct {
+	id    string          // The page ID.
+	page  tview.Primitive // The root primitive of the page.
+	focus tview.Primitive // The primitive to focus when the page is shown.
+}
+
+// navRouter : Keeps the history of pages the user has visited, so that they can go back and forward between
+// them. Only the current page is in the page holder; modals are shown on top of it.
+type navRouter struct {
+	back    []*navEntry // Pages before the current page, the most recent last.
+	current *navEntry
+	forward []*navEntry // Pages the user went back from, the most recent last.
+
+	services    *backend.Services        // The services of the profile in use, given to pages when they are created.
+	newServices func() *backend.Services // Creates the services for the profile in use, such as after switching profile.
+}
+
+// router : The navigation history of the app. It is only used from the interface goroutine.
+var router = &navRouter{}
+
+// UseServices : Set the services that pages are created with, and how to create them for another profile. This must
+// be done before any page is shown.
+func UseServices(s *backend.Services, create func() *backend.Services) {
+	router.services, router.newServices = s, create
+}
+
+// discardFuncs : Functions to call when a page is removed from the navigation history for good, such as to
+// cancel any loading for the page. By the root primitive of the page.
+var discardFuncs = map[tview.Primitive]func(){}
+
+// pageMutex : Guards discardFuncs, pageCommands and configListeners, as pages set their handlers while loading in
+// the background.
+var pageMutex sync.Mutex
+
+// onDiscard : Call a function when a page is removed from the navigation history for good, replacing any
+// function given before for the page.
+func onDiscard(page tview.Primitive, f func()) {
+	pageMutex.Lock()
+	defer pageMutex.Unlock()
+	discardFuncs[page] = f
+}
+
+// push : Show a new page after the current page. Pages the user went back from can no longer be returned to.
+func (r *navRouter) push(id string, page, focus tview.Primitive) {
+	for _, entry := range r.forward {
+		discard(entry)
+	}
+	r.forward = nil
+	if r.current != nil {
+		r.back = append(r.back, r.current)
+	}
+	r.show(&navEntry{id: id, page: page, focus: focus})
+}
+
+// reset : Show a new page, clearing the navigation history. Used when the user logs in or out, as the pages
+// shown before no longer apply.
+func (r *navRouter) reset(id string, page, focus tview.Primitive) {
+	old := r.current
+	for _, entry := range append(r.back, r.forward...) {
+		discard(entry)
+	}
+	r.back, r.forward = nil, nil
+	r.show(&navEntry{id: id, page: page, focus: focus})
+	if old != nil {
+		discard(old)
+	}
+}
+
+// pop : Remove the current page from the navigation history for good, and show the previous page. Returns false
+// if there is no previous page.
+func (r *navRouter) pop() bool {
+	if len(r.back) == 0 {
+		return false
+	}
+	old := r.current
+	entry := r.back[len(r.back)-1]
+	r.back = r.back[:len(r.back)-1]
+	r.show(entry)
+	discard(old)
+	return true
+}
+
+// goBack : Go back to the previous page. Returns false if there is no previous page.
+func (r *navRouter) goBack() bool {
+	if len(r.back) == 0 {
+		return false
+	}
+	entry := r.back[len(r.back)-1]
+	r.back = r.back[:len(r.back)-1]
+	r.forward = append(r.forward, r.current)
+	r.show(entry)
+	return true
+}
+
+// goForward : Go forward to the page the user last went back from. Returns false if there is no such page.
+func (r *navRouter) goForward() bool {
+	if len(r.forward) == 0 {
+		return false
+	}
+	entry := r.forward[len(r.forward)-1]
+	r.forward = r.forward[:len(r.forward)-1]
+	r.back = append(r.back, r.current)
+	r.show(entry)
+	return true
+}
+
+// backTo : Go back to the most recent page with the ID, if it is in the history before the current page.
+// Returns false if there is no such page.
+func (r *navRouter) backTo(id string) bool {
+	for i := len(r.back) - 1; i >= 0; i-- {
+		if r.back[i].id == id {
+			for len(r.back) > i+1 {
+				r.goBack()
+			}
+			return r.goBack()
+		}
+	}
+	return false
+}
+
+// currentID : Get the ID of the current page. Empty if no page is shown yet.
+func (r *navRouter) currentID() string {
+	if r.current == nil {
+		return ""
+	}
+	return r.current.id
+}
+
+// show : Make a page the current page, in place of the page shown before.
+func (r *navRouter) show(entry *navEntry) {
+	if r.current != nil {
+		// Remember what was focused, so that it is focused again when the user returns to the page.
+		if r.current.page.HasFocus() {
+			r.current.focus = core.App.TView.GetFocus()
+		}
+		core.App.PageHolder.RemovePage(r.current.id)
+	}
+	log.Printf("Showing %s (%d back, %d forward)\n", entry.id, len(r.back), len(r.forward))
+	r.current = entry
+	core.App.PageHolder.AddPage(entry.id, entry.page, true, true)
+	core.App.TView.SetFocus(entry.focus)
+}
+
+// discard : Remove a page from the navigation history for good.
+func discard(entry *navEntry) {
+	pageMutex.Lock()
+	f, ok := discardFuncs[entry.page]
+	delete(discardFuncs, entry.page)
+	delete(pageCommands, entry.page)
+	delete(configListeners, entry.page)
+	pageMutex.Unlock()
+
+	delete(themedPages, entry.page)
+	if ok {
+		f()
+	}
+}
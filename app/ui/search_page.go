@@ -2,8 +2,10 @@ package ui
 
 import (
 	"context"
+	"fmt"
 	"log"
 
+	"github.com/darylhjd/mangadesk/app/backend"
 	"github.com/darylhjd/mangadesk/app/core"
 	"github.com/darylhjd/mangadesk/app/ui/utils"
 	"github.com/rivo/tview"
@@ -25,25 +27,23 @@ type SearchParams struct {
 // ShowSearchPage : Make the app show the search page.
 func ShowSearchPage() {
 	// Create the new search page
-	searchPage := newSearchPage()
+	searchPage := newSearchPage(router.services)
 
-	core.App.PageHolder.AddAndSwitchToPage(utils.SearchPageID, searchPage.Grid, true)
-	core.App.TView.SetFocus(searchPage.Form)
+	router.push(utils.SearchPageID, searchPage.Grid, searchPage.Form)
 }
 
-// newSearchPage : Creates a new SearchPage.
-func newSearchPage() *SearchPage {
+// newSearchPage : Creates a new SearchPage, which uses the given services.
+func newSearchPage(services *backend.Services) *SearchPage {
 	var dimensions []int
 	for i := 0; i < 15; i++ {
 		dimensions = append(dimensions, -1)
 	}
 	grid := utils.NewGrid(dimensions, dimensions)
 	// Set grid attributes
-	grid.SetTitleColor(utils.SearchPageGridTitleColor).
-		SetBorderColor(utils.SearchPageGridBorderColor).
-		SetTitle("Search Manga. " +
-			"[yellow]Press ↓ on search bar to switch to table. " +
-			"[green]Press Tab on table to switch to search bar.").
+	grid.SetTitle(fmt.Sprintf("Search Manga. "+
+		"[yellow]Press %s on search bar to switch to table. "+
+		"[green]Press %s on table to switch to search bar.",
+		keymap.Describe(searchFocusResultsAction), keymap.Describe(searchFocusFormAction))).
 		SetBorder(true)
 
 	// Create table to show manga list.
@@ -51,16 +51,13 @@ func newSearchPage() *SearchPage {
 	// Set table attributes
 	table.SetSelectable(true, false).
 		SetSeparator('|').
-		SetBordersColor(utils.SearchPageTableBorderColor).
-		SetTitleColor(utils.SearchPageTableTitleColor).
 		SetTitle("The curious cat peeks into the database...🐈").
 		SetBorder(true)
 
 	// Create a form for the searching
 	search := tview.NewForm()
 	// Set form attributes
-	search.SetButtonsAlign(tview.AlignLeft).
-		SetLabelColor(utils.SearchFormLabelColor)
+	search.SetButtonsAlign(tview.AlignLeft)
 
 	// Add search bar and result table to the grid. Search bar will have focus.
 	grid.AddItem(search, 0, 0, 4, 15, 0, 0, false).
@@ -71,8 +68,9 @@ func newSearchPage() *SearchPage {
 	ctx, cancel := context.WithCancel(context.Background())
 	searchPage := &SearchPage{
 		MainPage: MainPage{
-			Grid:  grid,
-			Table: table,
+			Grid:     grid,
+			Table:    table,
+			services: services,
 			cWrap: &utils.ContextWrapper{
 				Ctx:    ctx,
 				Cancel: cancel,
@@ -80,6 +78,7 @@ func newSearchPage() *SearchPage {
 		},
 		Form: search,
 	}
+	searchPage.setGuestSorter("Relevance")
 
 	// Add form fields
 	search.AddInputField("Search Manga:", "", 0, nil, nil).
@@ -97,10 +96,23 @@ func newSearchPage() *SearchPage {
 
 	// Set handlers.
 	searchPage.setHandlers()
+	themePage(searchPage.Grid, searchPage.setColors)
 
 	return searchPage
 }
 
+// setColors : Apply the current theme to the search page.
+func (p *SearchPage) setColors() {
+	p.Grid.SetTitleColor(utils.Colors.SearchPageGridTitle).
+		SetBorderColor(utils.Colors.SearchPageGridBorder).
+		SetBackgroundColor(utils.Colors.Background)
+	p.Table.SetBordersColor(utils.Colors.SearchPageTableBorder).
+		SetTitleColor(utils.Colors.SearchPageTableTitle).
+		SetBackgroundColor(utils.Colors.Background)
+	setFormColors(p.Form, utils.Colors.SearchFormLabel)
+	p.setCellColors()
+}
+
 // setSearchTable : Sets the table for search results.
 func (p *SearchPage) setSearchTable(exContent bool, searchTerm string) {
 	log.Println("Setting new search results...")
@@ -0,0 +1,267 @@
+package ui
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/rivo/tview"
+
+	"github.com/darylhjd/mangadesk/app/core"
+	"github.com/darylhjd/mangadesk/app/ui/utils"
+)
+
+// Labels of the fields in the settings form, by the name of the field in the configuration file.
+var settingsLabels = map[string]string{
+	"downloadDir":     "Download directory:",
+	"languages":       "Languages:",
+	"downloadQuality": "Download quality:",
+	"explicitContent": "Explicit content:",
+	"forcePort443":    "Force port 443:",
+	"asZip":           "Download as zip:",
+	"zipType":         "Zip type:",
+	"guestMode":       "Guest mode:",
+	"preferredGroups": "Preferred groups:",
+	"blockedGroups":   "Blocked groups:",
+	"vimMode":         "Vim mode:",
+	"apiUrl":          "API URL:",
+	"atHomeUrl":       "MangaDex@Home URL:",
+	"authUrl":         "Login URL:",
+	"requestTimeout":  "Request timeout (seconds):",
+	"userAgent":       "User agent:",
+	"proxy":           "Proxy:",
+	"caBundle":        "CA bundle:",
+}
+
+var (
+	downloadQualities = []string{"data", "data-saver"}
+	zipTypes          = []string{"zip", "cbz"}
+)
+
+// SettingsPage : This struct contains the form for changing the user configuration, and a view to show any
+// problems with the changes. Keybindings are not in the form, as they are changed in the configuration file.
+type SettingsPage struct {
+	Grid   *tview.Grid
+	Form   *tview.Form
+	Errors *tview.TextView
+
+	items    map[string]tview.FormItem // The fields of the form, by the name of the field in the configuration file.
+	problems []*core.ConfigError       // The problems found when the settings were last saved.
+}
+
+// ShowSettingsPage : Make the app show the settings page.
+func ShowSettingsPage() {
+	// Do not show the settings page on top of itself.
+	if router.currentID() == utils.SettingsPageID {
+		return
+	}
+	settingsPage := newSettingsPage()
+	router.push(utils.SettingsPageID, settingsPage.Grid, settingsPage.Form)
+}
+
+// newSettingsPage : Creates a new settings page, with the form filled in from the current configuration.
+func newSettingsPage() *SettingsPage {
+	form := tview.NewForm()
+	form.SetButtonsAlign(tview.AlignLeft).
+		SetTitle("Settings. Lists are separated by commas.").
+		SetBorder(true)
+	form.AddInputField(settingsLabels["downloadDir"], "", 0, nil, nil).
+		AddInputField(settingsLabels["languages"], "", 0, nil, nil).
+		AddDropDown(settingsLabels["downloadQuality"], downloadQualities, 0, nil).
+		AddCheckbox(settingsLabels["explicitContent"], false, nil).
+		AddCheckbox(settingsLabels["forcePort443"], false, nil).
+		AddCheckbox(settingsLabels["asZip"], false, nil).
+		AddDropDown(settingsLabels["zipType"], zipTypes, 0, nil).
+		AddCheckbox(settingsLabels["guestMode"], false, nil).
+		AddInputField(settingsLabels["preferredGroups"], "", 0, nil, nil).
+		AddInputField(settingsLabels["blockedGroups"], "", 0, nil, nil).
+		AddCheckbox(settingsLabels["vimMode"], false, nil).
+		AddInputField(settingsLabels["apiUrl"], "", 0, nil, nil).
+		AddInputField(settingsLabels["atHomeUrl"], "", 0, nil, nil).
+		AddInputField(settingsLabels["authUrl"], "", 0, nil, nil).
+		AddInputField(settingsLabels["requestTimeout"], "", 0, nil, nil).
+		AddInputField(settingsLabels["userAgent"], "", 0, nil, nil).
+		AddInputField(settingsLabels["proxy"], "", 0, nil, nil).
+		AddInputField(settingsLabels["caBundle"], "", 0, nil, nil)
+
+	errors := tview.NewTextView()
+	errors.SetDynamicColors(true).
+		SetWrap(true)
+
+	grid := utils.NewGrid([]int{-1, 4}, []int{-1}).
+		AddItem(form, 0, 0, 1, 1, 0, 0, true).
+		AddItem(errors, 1, 0, 1, 1, 0, 0, false)
+
+	settingsPage := &SettingsPage{
+		Grid:   grid,
+		Form:   form,
+		Errors: errors,
+		items:  map[string]tview.FormItem{},
+	}
+	for field, label := range settingsLabels {
+		settingsPage.items[field] = form.GetFormItemByLabel(label)
+	}
+	settingsPage.fillForm(core.App.Config)
+	form.AddButton("Save", settingsPage.save).
+		AddButton("Back", func() {
+			router.goBack()
+		})
+
+	settingsPage.setHandlers()
+	themePage(grid, settingsPage.setColors)
+
+	return settingsPage
+}
+
+// setColors : Apply the current theme to the settings page. Fields with problems have their labels marked.
+func (p *SettingsPage) setColors() {
+	p.Grid.SetBackgroundColor(utils.Colors.Background)
+	p.Form.SetTitleColor(utils.Colors.SettingsFormLabel).
+		SetBorderColor(utils.Colors.SettingsPageBorder)
+	setFormColors(p.Form, utils.Colors.SettingsFormLabel)
+	p.Errors.SetTextColor(utils.Colors.ToastError).
+		SetBackgroundColor(utils.Colors.Background)
+	p.showProblems()
+}
+
+// fillForm : Show the settings of a configuration in the form.
+func (p *SettingsPage) fillForm(conf *core.UserConfig) {
+	p.items["downloadDir"].(*tview.InputField).SetText(conf.DownloadDir)
+	p.items["languages"].(*tview.InputField).SetText(strings.Join(conf.Languages, ", "))
+	p.items["downloadQuality"].(*tview.DropDown).SetCurrentOption(indexOf(downloadQualities, conf.DownloadQuality))
+	p.items["explicitContent"].(*tview.Checkbox).SetChecked(conf.ExplicitContent)
+	p.items["forcePort443"].(*tview.Checkbox).SetChecked(conf.ForcePort443)
+	p.items["asZip"].(*tview.Checkbox).SetChecked(conf.AsZip)
+	p.items["zipType"].(*tview.DropDown).SetCurrentOption(indexOf(zipTypes, conf.ZipType))
+	p.items["guestMode"].(*tview.Checkbox).SetChecked(conf.GuestMode)
+	p.items["preferredGroups"].(*tview.InputField).SetText(strings.Join(conf.PreferredGroups, ", "))
+	p.items["blockedGroups"].(*tview.InputField).SetText(strings.Join(conf.BlockedGroups, ", "))
+	p.items["vimMode"].(*tview.Checkbox).SetChecked(conf.VimMode)
+	p.items["apiUrl"].(*tview.InputField).SetText(conf.APIURL)
+	p.items["atHomeUrl"].(*tview.InputField).SetText(conf.AtHomeURL)
+	p.items["authUrl"].(*tview.InputField).SetText(conf.AuthURL)
+	p.items["requestTimeout"].(*tview.InputField).SetText(strconv.Itoa(conf.RequestTimeout))
+	p.items["userAgent"].(*tview.InputField).SetText(conf.UserAgent)
+	p.items["proxy"].(*tview.InputField).SetText(conf.Proxy)
+	p.items["caBundle"].(*tview.InputField).SetText(conf.CABundle)
+}
+
+// save : Apply the settings in the form. If there are problems with them, they are shown and nothing is changed.
+func (p *SettingsPage) save() {
+	old := core.App.Config
+	problems, err := core.App.SetConfiguration(p.readForm())
+	p.problems = problems
+	p.showProblems()
+	if len(problems) != 0 {
+		notifyError(fmt.Sprintf("Found %d problem(s) with the settings.", len(problems)))
+		return
+	}
+	if err != nil {
+		core.LogErrorf("Error saving configuration: %s\n", err.Error())
+		notifyError("Settings applied, but they could not be saved. Check log for details.")
+	} else {
+		log.Println("Saved settings.")
+		notify("Settings saved.")
+	}
+	configChanged(old, core.App.Config)
+}
+
+// readForm : Create a configuration from the values in the form. Keybindings are kept from the current
+// configuration.
+func (p *SettingsPage) readForm() *core.UserConfig {
+	text := func(field string) string {
+		return p.items[field].(*tview.InputField).GetText()
+	}
+	list := func(field string) []string {
+		return strings.Split(text(field), ",")
+	}
+	checked := func(field string) bool {
+		return p.items[field].(*tview.Checkbox).IsChecked()
+	}
+	number := func(field string) int {
+		// Anything that is not a number is given as -1, so that it is reported as a problem.
+		n, err := strconv.Atoi(strings.TrimSpace(text(field)))
+		if err != nil {
+			return -1
+		}
+		return n
+	}
+	option := func(field string) string {
+		_, option := p.items[field].(*tview.DropDown).GetCurrentOption()
+		return option
+	}
+
+	keybindings := map[string][]string{}
+	for action, keys := range core.App.Config.Keybindings {
+		keybindings[action] = keys
+	}
+	return &core.UserConfig{
+		Version:         core.App.Config.Version,
+		DownloadDir:     strings.TrimSpace(text("downloadDir")),
+		Languages:       list("languages"),
+		DownloadQuality: option("downloadQuality"),
+		ExplicitContent: checked("explicitContent"),
+		ForcePort443:    checked("forcePort443"),
+		AsZip:           checked("asZip"),
+		ZipType:         option("zipType"),
+		GuestMode:       checked("guestMode"),
+		PreferredGroups: list("preferredGroups"),
+		BlockedGroups:   list("blockedGroups"),
+		Keybindings:     keybindings,
+		VimMode:         checked("vimMode"),
+		APIURL:          text("apiUrl"),
+		AtHomeURL:       text("atHomeUrl"),
+		AuthURL:         text("authUrl"),
+		RequestTimeout:  number("requestTimeout"),
+		UserAgent:       text("userAgent"),
+		Proxy:           text("proxy"),
+		CABundle:        text("caBundle"),
+	}
+}
+
+// showProblems : Show the problems found when the settings were last saved, marking the fields they are for.
+func (p *SettingsPage) showProblems() {
+	marked := map[string]bool{}
+	var text strings.Builder
+	for _, problem := range p.problems {
+		marked[problem.Field] = true
+		text.WriteString(fmt.Sprintf("%s %s\n", settingsLabels[problem.Field], tview.Escape(problem.Message)))
+	}
+	// Settings given on the command line are used instead of the form until the app is closed.
+	if overridden := core.OverriddenSettings(); len(overridden) != 0 {
+		text.WriteString(fmt.Sprintf("Set on the command line, so changes are not saved: %s\n",
+			strings.Join(overridden, ", ")))
+	}
+	p.Errors.SetText(text.String())
+
+	for field, item := range p.items {
+		label := settingsLabels[field]
+		if marked[field] {
+			label = fmt.Sprintf("[%s]* %s[-]", utils.Colors.ToastError.String(), label)
+		}
+		setItemLabel(item, label)
+	}
+}
+
+// setItemLabel : Set the label of a form item.
+func setItemLabel(item tview.FormItem, label string) {
+	switch item := item.(type) {
+	case *tview.InputField:
+		item.SetLabel(label)
+	case *tview.Checkbox:
+		item.SetLabel(label)
+	case *tview.DropDown:
+		item.SetLabel(label)
+	}
+}
+
+// indexOf : Get the index of an option, or 0 if it is not one of the options.
+func indexOf(options []string, option string) int {
+	for i, o := range options {
+		if o == option {
+			return i
+		}
+	}
+	return 0
+}
@@ -0,0 +1,157 @@
+package ui
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/rivo/tview"
+
+	"github.com/darylhjd/mangadesk/app/core"
+	"github.com/darylhjd/mangadesk/app/ui/utils"
+)
+
+const (
+	toastDuration    = 5 * time.Second // How long a toast is shown in the status bar.
+	maxNotifications = 200             // How many notifications are kept for the notifications page.
+)
+
+// notification : A message shown to the user as a toast, and kept for the notifications page.
+type notification struct {
+	time    time.Time
+	text    string
+	isError bool
+}
+
+// StatusBar : The bar at the bottom of the app, showing the login state, active downloads, rate limit waits
+// and toasts. Toasts are short messages that disappear after a while, without taking focus from the page.
+// The methods of the status bar must be called on the UI goroutine, such as within QueueUpdateDraw.
+type StatusBar struct {
+	Grid  *tview.Grid
+	State *tview.TextView // Login state, downloads and rate limit waits.
+	Toast *tview.TextView
+
+	user          string    // The name shown for the login state.
+	downloads     int       // The number of chapters left to download.
+	waitUntil     time.Time // When the current rate limit wait ends, if any.
+	notifications []*notification
+}
+
+// statusBar : The status bar of the app.
+var statusBar *StatusBar
+
+// SetUpStatusBar : Create the status bar, and make the app show it below the pages.
+func SetUpStatusBar() {
+	state := tview.NewTextView().SetDynamicColors(true)
+	toast := tview.NewTextView().SetDynamicColors(true).SetWrap(false).SetTextAlign(tview.AlignRight)
+	bar := utils.NewGrid([]int{1}, []int{-1, -1}).
+		AddItem(state, 0, 0, 1, 1, 0, 0, false).
+		AddItem(toast, 0, 1, 1, 1, 0, 0, false)
+
+	statusBar = &StatusBar{
+		Grid:  bar,
+		State: state,
+		Toast: toast,
+		user:  "Not logged in",
+	}
+	themePage(bar, statusBar.setColors)
+	statusBar.render()
+
+	root := utils.NewGrid([]int{-1, 1}, []int{-1}).
+		AddItem(core.App.PageHolder, 0, 0, 1, 1, 0, 0, true).
+		AddItem(bar, 1, 0, 1, 1, 0, 0, false)
+	core.App.TView.SetRoot(root, true)
+}
+
+// setColors : Apply the current theme to the status bar.
+func (s *StatusBar) setColors() {
+	s.Grid.SetBackgroundColor(utils.Colors.StatusBar)
+	s.State.SetTextColor(utils.Colors.StatusBarText).SetBackgroundColor(utils.Colors.StatusBar)
+	s.Toast.SetBackgroundColor(utils.Colors.StatusBar)
+	s.renderToast()
+}
+
+// setUser : Show the login state of the user, such as their username or that they are a guest.
+func (s *StatusBar) setUser(user string) {
+	s.user = user
+	s.render()
+}
+
+// addDownloads : Change the number of chapters left to download.
+func (s *StatusBar) addDownloads(chapters int) {
+	s.downloads = max(s.downloads+chapters, 0)
+	s.render()
+}
+
+// waitRateLimit : Show that requests are waiting until the rate limit ends. The wait is counted down every second.
+func (s *StatusBar) waitRateLimit(wait time.Duration) {
+	s.waitUntil = time.Now().Add(wait)
+	s.render()
+	var tick func()
+	tick = func() {
+		core.App.TView.QueueUpdateDraw(func() {
+			s.render()
+			if time.Now().Before(s.waitUntil) {
+				time.AfterFunc(time.Second, tick)
+			}
+		})
+	}
+	time.AfterFunc(time.Second, tick)
+}
+
+// render : Show the login state, active downloads and rate limit waits.
+func (s *StatusBar) render() {
+	parts := []string{s.user}
+	if profile := core.Profile(); profile != core.DefaultProfile {
+		parts = append([]string{fmt.Sprintf("Profile: %s", profile)}, parts...)
+	}
+	if s.downloads > 0 {
+		parts = append(parts, fmt.Sprintf("Downloading %d chapter(s)", s.downloads))
+	}
+	if wait := time.Until(s.waitUntil); wait > 0 {
+		parts = append(parts, fmt.Sprintf("Rate limited, retrying in %ds", int(wait.Round(time.Second).Seconds())))
+	}
+	s.State.SetText(" " + tview.Escape(strings.Join(parts, " | ")))
+}
+
+// notify : Show a message as a toast, and keep it for the notifications page.
+// The message is also logged, so that it can be found after the app is closed.
+func (s *StatusBar) notify(text string, isError bool) {
+	text = strings.Join(strings.Fields(text), " ") // Toasts are shown on a single line.
+	log.Printf("Notification: %s\n", text)
+	s.notifications = append(s.notifications, &notification{time: time.Now(), text: text, isError: isError})
+	if len(s.notifications) > maxNotifications {
+		s.notifications = s.notifications[len(s.notifications)-maxNotifications:]
+	}
+
+	// The toast is hidden once it expires, unless a newer toast is being shown.
+	s.renderToast()
+	time.AfterFunc(toastDuration, func() {
+		core.App.TView.QueueUpdateDraw(s.renderToast)
+	})
+}
+
+// renderToast : Show the latest notification as a toast, if it has not expired.
+func (s *StatusBar) renderToast() {
+	if len(s.notifications) == 0 || time.Since(s.notifications[len(s.notifications)-1].time) >= toastDuration {
+		s.Toast.SetText("")
+		return
+	}
+	latest := s.notifications[len(s.notifications)-1]
+	color := utils.Colors.ToastInfo
+	if latest.isError {
+		color = utils.Colors.ToastError
+	}
+	s.Toast.SetTextColor(color).SetText(tview.Escape(latest.text) + " ")
+}
+
+// notify : Show a message to the user as a toast in the status bar.
+func notify(text string) {
+	statusBar.notify(text, false)
+}
+
+// notifyError : Show an error to the user as a toast in the status bar.
+func notifyError(text string) {
+	statusBar.notify(text, true)
+}
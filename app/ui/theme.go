@@ -0,0 +1,114 @@
+package ui
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+
+	"github.com/darylhjd/mangadesk/app/core"
+	"github.com/darylhjd/mangadesk/app/ui/utils"
+)
+
+// themeCheckInterval : How often the theme file is checked for changes.
+const themeCheckInterval = time.Second
+
+// themeFilePath : The filepath to the theme file.
+func themeFilePath() string {
+	return filepath.Join(core.ConfDir(), "theme.json")
+}
+
+// themeFile : The contents of the theme file. Colours not given are taken from the base theme.
+type themeFile struct {
+	Base   string            `json:"base"`
+	Colors map[string]string `json:"colors"`
+}
+
+// themedPages : Functions that apply the current theme to each page, by the root primitive of the page.
+var themedPages = map[tview.Primitive]func(){}
+
+// LoadTheme : Apply the theme in the theme file, creating a default theme file if it does not exist.
+// The theme file is then watched, so that changes to it are applied while the app is running.
+func LoadTheme() {
+	applyTheme(readTheme())
+	core.WatchFile(themeFilePath(), themeCheckInterval, func() {
+		log.Println("Theme file changed, reloading theme...")
+		theme, problems := readTheme()
+		core.App.TView.QueueUpdateDraw(func() {
+			applyTheme(theme, problems)
+		})
+	})
+}
+
+// readTheme : Read the theme in the theme file. Any problems, such as unknown colours, are returned along with
+// the theme, which uses the base theme for colours that could not be read.
+func readTheme() (utils.Theme, []error) {
+	confBytes, err := ioutil.ReadFile(themeFilePath())
+	if os.IsNotExist(err) {
+		log.Println("No theme file found, creating default theme file...")
+		if err = saveDefaultTheme(); err != nil {
+			core.LogErrorf("Error creating theme file: %s\n", err.Error())
+		}
+		return utils.DarkTheme(), nil
+	} else if err != nil {
+		return utils.DarkTheme(), []error{err}
+	}
+
+	var file themeFile
+	if err = json.Unmarshal(confBytes, &file); err != nil {
+		return utils.DarkTheme(), []error{fmt.Errorf("unable to read theme file: %s", err.Error())}
+	}
+	return utils.NewTheme(file.Base, file.Colors)
+}
+
+// saveDefaultTheme : Save a theme file that uses the default theme, without changing any colours.
+func saveDefaultTheme() error {
+	confBytes, err := json.MarshalIndent(themeFile{Base: utils.DefaultThemeName, Colors: map[string]string{}}, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(themeFilePath(), confBytes, os.ModePerm)
+}
+
+// applyTheme : Make the theme the current theme, and apply it to the pages in the navigation history.
+// Any problems found with the theme are shown to the user as notifications.
+func applyTheme(theme utils.Theme, problems []error) {
+	utils.Colors = theme
+	tview.Styles.PrimitiveBackgroundColor = theme.Background
+	tview.Styles.PrimaryTextColor = theme.Text
+	tview.Styles.ContrastBackgroundColor = theme.FieldBackground
+	for _, setColors := range themedPages {
+		setColors()
+	}
+
+	for _, problem := range problems {
+		notifyError(fmt.Sprintf("Theme: %s", problem.Error()))
+	}
+	if len(problems) > 1 {
+		notifyError(fmt.Sprintf("Found %d problems with your theme. Press %s to see them all.",
+			len(problems), keymap.Describe(notificationsAction)))
+	}
+}
+
+// themePage : Apply the current theme to a page, and again whenever the theme changes while the page is in the
+// navigation history.
+func themePage(page tview.Primitive, setColors func()) {
+	themedPages[page] = setColors
+	setColors()
+}
+
+// setFormColors : Apply the current theme to the fields and buttons of a form.
+func setFormColors(form *tview.Form, label tcell.Color) {
+	form.SetLabelColor(label).
+		SetFieldBackgroundColor(utils.Colors.FieldBackground).
+		SetFieldTextColor(utils.Colors.Text).
+		SetButtonBackgroundColor(utils.Colors.FieldBackground).
+		SetButtonTextColor(utils.Colors.Text).
+		SetBackgroundColor(utils.Colors.Background)
+}
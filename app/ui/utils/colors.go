@@ -1,58 +1,499 @@
 package utils
 
-import "github.com/gdamore/tcell/v2"
+import (
+	"fmt"
+	"sort"
+	"strings"
 
-const ( // Login page colours
-	LoginPageTitleColor = tcell.ColorOrange
-
-	LoginFormBorderColor = tcell.ColorGrey
-	LoginFormLabelColor  = tcell.ColorWhite
+	"github.com/gdamore/tcell/v2"
 )
 
-const ( // Main page colors
-	MainPageGridTitleColor   = tcell.ColorOrange
-	MainPageGridBorderColor  = tcell.ColorLightGrey
-	MainPageTableTitleColor  = tcell.ColorLightSkyBlue
-	MainPageTableBorderColor = tcell.ColorGrey
+// Theme : The colours used by each page of the application.
+type Theme struct {
+	// General colours, used by every page.
+	Background      tcell.Color
+	Text            tcell.Color
+	FieldBackground tcell.Color // Input fields and buttons.
 
-	LoggedMainPageTitleColor     = tcell.ColorLightGoldenrodYellow
-	LoggedMainPagePubStatusColor = tcell.ColorSandyBrown
+	// Login page colours
+	LoginPageTitle  tcell.Color
+	LoginFormBorder tcell.Color
+	LoginFormLabel  tcell.Color
 
-	GuestMainPageTitleColor = tcell.ColorOrange
-	GuestMainPageDescColor  = tcell.ColorLightGrey
-	GuestMainPageTagColor   = tcell.ColorLightSteelBlue
-)
+	// Main page colours
+	MainPageGridTitle   tcell.Color
+	MainPageGridBorder  tcell.Color
+	MainPageTableTitle  tcell.Color
+	MainPageTableBorder tcell.Color
 
-const ( // Manga page colors
-	MangaPageGridTitleColor   = tcell.ColorOrange
-	MangaPageGridBorderColor  = tcell.ColorLightGrey
-	MangaPageTableTitleColor  = tcell.ColorLightSkyBlue
-	MangaPageTableBorderColor = tcell.ColorGrey
-
-	MangaPageInfoViewTitleColor  = tcell.ColorLightSkyBlue
-	MangaPageInfoViewBorderColor = tcell.ColorLightGrey
-
-	MangaPageChapNumColor      = tcell.ColorLightYellow
-	MangaPageTitleColor        = tcell.ColorLightSkyBlue
-	MangaPageDownloadStatColor = tcell.ColorPowderBlue
-	MangaPageReadStatColor     = tcell.ColorOrange
-	MangaPageScanGroupColor    = tcell.ColorDarkSalmon
-	MangaPageHighlightColor    = tcell.ColorMediumSpringGreen
-)
+	LoggedMainPageTitle      tcell.Color
+	LoggedMainPagePubStatus  tcell.Color
+	LoggedMainPageLastUpdate tcell.Color
 
-const ( // Search page colors
-	SearchPageGridTitleColor   = tcell.ColorOrange
-	SearchPageGridBorderColor  = tcell.ColorLightGrey
-	SearchPageTableTitleColor  = tcell.ColorLightSkyBlue
-	SearchPageTableBorderColor = tcell.ColorGrey
+	GuestMainPageTitle tcell.Color
+	GuestMainPageDesc  tcell.Color
+	GuestMainPageTag   tcell.Color
 
-	SearchFormLabelColor = tcell.ColorWhite
-)
+	// Manga page colours
+	MangaPageGridTitle   tcell.Color
+	MangaPageGridBorder  tcell.Color
+	MangaPageTableTitle  tcell.Color
+	MangaPageTableBorder tcell.Color
 
-const ( // Help page colors
-	HelpPageBorderColor = tcell.ColorLightGrey
-)
+	MangaPageInfoViewTitle  tcell.Color
+	MangaPageInfoViewBorder tcell.Color
 
-const ( // Modal colors
-	ModalColor = tcell.ColorDarkSlateGrey
-)
+	MangaPageChapNum       tcell.Color
+	MangaPageVolume        tcell.Color
+	MangaPageTitle         tcell.Color
+	MangaPageLang          tcell.Color
+	MangaPageDownloadStat  tcell.Color
+	MangaPageReadStat      tcell.Color
+	MangaPageScanGroup     tcell.Color
+	MangaPagePublished     tcell.Color
+	MangaPageHighlight     tcell.Color
+	MangaPageHighlightText tcell.Color
+
+	// Search page colours
+	SearchPageGridTitle   tcell.Color
+	SearchPageGridBorder  tcell.Color
+	SearchPageTableTitle  tcell.Color
+	SearchPageTableBorder tcell.Color
+
+	SearchFormLabel tcell.Color
+
+	// Help page colours
+	HelpPageBorder tcell.Color
+
+	// Notifications page colours
+	NotificationsPageBorder tcell.Color
+
+	// Settings page colours
+	SettingsPageBorder tcell.Color
+	SettingsFormLabel  tcell.Color
+
+	// Status bar colours
+	StatusBar     tcell.Color
+	StatusBarText tcell.Color
+	ToastInfo     tcell.Color
+	ToastError    tcell.Color
+
+	// Modal colours
+	Modal           tcell.Color
+	InputModalLabel tcell.Color
+}
+
+// Colors : The colours of the current theme.
+var Colors = DarkTheme()
+
+// Themes : The built-in themes, by name. A theme file may use any of these as its base.
+var Themes = map[string]func() Theme{
+	"dark":          DarkTheme,
+	"light":         LightTheme,
+	"high-contrast": HighContrastTheme,
+	"16-color":      SixteenColorTheme,
+}
+
+// DefaultThemeName : The name of the built-in theme used when no other is chosen.
+const DefaultThemeName = "dark"
+
+// DarkTheme : The default theme, for terminals with a dark background.
+func DarkTheme() Theme {
+	return Theme{
+		Background:      tcell.ColorBlack,
+		Text:            tcell.ColorWhite,
+		FieldBackground: tcell.ColorBlue,
+
+		LoginPageTitle:  tcell.ColorOrange,
+		LoginFormBorder: tcell.ColorGrey,
+		LoginFormLabel:  tcell.ColorWhite,
+
+		MainPageGridTitle:   tcell.ColorOrange,
+		MainPageGridBorder:  tcell.ColorLightGrey,
+		MainPageTableTitle:  tcell.ColorLightSkyBlue,
+		MainPageTableBorder: tcell.ColorGrey,
+
+		LoggedMainPageTitle:      tcell.ColorLightGoldenrodYellow,
+		LoggedMainPagePubStatus:  tcell.ColorSandyBrown,
+		LoggedMainPageLastUpdate: tcell.ColorLightSteelBlue,
+
+		GuestMainPageTitle: tcell.ColorOrange,
+		GuestMainPageDesc:  tcell.ColorLightGrey,
+		GuestMainPageTag:   tcell.ColorLightSteelBlue,
+
+		MangaPageGridTitle:   tcell.ColorOrange,
+		MangaPageGridBorder:  tcell.ColorLightGrey,
+		MangaPageTableTitle:  tcell.ColorLightSkyBlue,
+		MangaPageTableBorder: tcell.ColorGrey,
+
+		MangaPageInfoViewTitle:  tcell.ColorLightSkyBlue,
+		MangaPageInfoViewBorder: tcell.ColorLightGrey,
+
+		MangaPageChapNum:       tcell.ColorLightYellow,
+		MangaPageVolume:        tcell.ColorKhaki,
+		MangaPageTitle:         tcell.ColorLightSkyBlue,
+		MangaPageLang:          tcell.ColorLightGrey,
+		MangaPageDownloadStat:  tcell.ColorPowderBlue,
+		MangaPageReadStat:      tcell.ColorOrange,
+		MangaPageScanGroup:     tcell.ColorDarkSalmon,
+		MangaPagePublished:     tcell.ColorLightSteelBlue,
+		MangaPageHighlight:     tcell.ColorMediumSpringGreen,
+		MangaPageHighlightText: tcell.ColorBlack,
+
+		SearchPageGridTitle:   tcell.ColorOrange,
+		SearchPageGridBorder:  tcell.ColorLightGrey,
+		SearchPageTableTitle:  tcell.ColorLightSkyBlue,
+		SearchPageTableBorder: tcell.ColorGrey,
+
+		SearchFormLabel: tcell.ColorWhite,
+
+		HelpPageBorder: tcell.ColorLightGrey,
+
+		NotificationsPageBorder: tcell.ColorLightGrey,
+
+		SettingsPageBorder: tcell.ColorLightGrey,
+		SettingsFormLabel:  tcell.ColorWhite,
+
+		StatusBar:     tcell.ColorDarkSlateGrey,
+		StatusBarText: tcell.ColorWhite,
+		ToastInfo:     tcell.ColorMediumSpringGreen,
+		ToastError:    tcell.ColorSalmon,
+
+		Modal:           tcell.ColorDarkSlateGrey,
+		InputModalLabel: tcell.ColorWhite,
+	}
+}
+
+// LightTheme : A theme for terminals with a light background.
+func LightTheme() Theme {
+	return Theme{
+		Background:      tcell.ColorWhite,
+		Text:            tcell.ColorBlack,
+		FieldBackground: tcell.ColorLightSteelBlue,
+
+		LoginPageTitle:  tcell.ColorDarkOrange,
+		LoginFormBorder: tcell.ColorDimGrey,
+		LoginFormLabel:  tcell.ColorBlack,
+
+		MainPageGridTitle:   tcell.ColorDarkOrange,
+		MainPageGridBorder:  tcell.ColorDimGrey,
+		MainPageTableTitle:  tcell.ColorRoyalBlue,
+		MainPageTableBorder: tcell.ColorGrey,
+
+		LoggedMainPageTitle:      tcell.ColorDarkGoldenrod,
+		LoggedMainPagePubStatus:  tcell.ColorSienna,
+		LoggedMainPageLastUpdate: tcell.ColorSteelBlue,
+
+		GuestMainPageTitle: tcell.ColorDarkOrange,
+		GuestMainPageDesc:  tcell.ColorDimGrey,
+		GuestMainPageTag:   tcell.ColorSteelBlue,
+
+		MangaPageGridTitle:   tcell.ColorDarkOrange,
+		MangaPageGridBorder:  tcell.ColorDimGrey,
+		MangaPageTableTitle:  tcell.ColorRoyalBlue,
+		MangaPageTableBorder: tcell.ColorGrey,
+
+		MangaPageInfoViewTitle:  tcell.ColorRoyalBlue,
+		MangaPageInfoViewBorder: tcell.ColorDimGrey,
+
+		MangaPageChapNum:       tcell.ColorDarkGoldenrod,
+		MangaPageVolume:        tcell.ColorOliveDrab,
+		MangaPageTitle:         tcell.ColorRoyalBlue,
+		MangaPageLang:          tcell.ColorDimGrey,
+		MangaPageDownloadStat:  tcell.ColorTeal,
+		MangaPageReadStat:      tcell.ColorDarkOrange,
+		MangaPageScanGroup:     tcell.ColorBrown,
+		MangaPagePublished:     tcell.ColorSteelBlue,
+		MangaPageHighlight:     tcell.ColorSeaGreen,
+		MangaPageHighlightText: tcell.ColorWhite,
+
+		SearchPageGridTitle:   tcell.ColorDarkOrange,
+		SearchPageGridBorder:  tcell.ColorDimGrey,
+		SearchPageTableTitle:  tcell.ColorRoyalBlue,
+		SearchPageTableBorder: tcell.ColorGrey,
+
+		SearchFormLabel: tcell.ColorBlack,
+
+		HelpPageBorder: tcell.ColorDimGrey,
+
+		NotificationsPageBorder: tcell.ColorDimGrey,
+
+		SettingsPageBorder: tcell.ColorDimGrey,
+		SettingsFormLabel:  tcell.ColorBlack,
+
+		StatusBar:     tcell.ColorLightGrey,
+		StatusBarText: tcell.ColorBlack,
+		ToastInfo:     tcell.ColorDarkGreen,
+		ToastError:    tcell.ColorDarkRed,
+
+		Modal:           tcell.ColorLightGrey,
+		InputModalLabel: tcell.ColorBlack,
+	}
+}
+
+// HighContrastTheme : A theme using only black, white and a few bright colours, for readability.
+func HighContrastTheme() Theme {
+	return Theme{
+		Background:      tcell.ColorBlack,
+		Text:            tcell.ColorWhite,
+		FieldBackground: tcell.ColorNavy,
+
+		LoginPageTitle:  tcell.ColorYellow,
+		LoginFormBorder: tcell.ColorWhite,
+		LoginFormLabel:  tcell.ColorWhite,
+
+		MainPageGridTitle:   tcell.ColorYellow,
+		MainPageGridBorder:  tcell.ColorWhite,
+		MainPageTableTitle:  tcell.ColorAqua,
+		MainPageTableBorder: tcell.ColorWhite,
+
+		LoggedMainPageTitle:      tcell.ColorWhite,
+		LoggedMainPagePubStatus:  tcell.ColorYellow,
+		LoggedMainPageLastUpdate: tcell.ColorAqua,
+
+		GuestMainPageTitle: tcell.ColorWhite,
+		GuestMainPageDesc:  tcell.ColorWhite,
+		GuestMainPageTag:   tcell.ColorAqua,
+
+		MangaPageGridTitle:   tcell.ColorYellow,
+		MangaPageGridBorder:  tcell.ColorWhite,
+		MangaPageTableTitle:  tcell.ColorAqua,
+		MangaPageTableBorder: tcell.ColorWhite,
+
+		MangaPageInfoViewTitle:  tcell.ColorAqua,
+		MangaPageInfoViewBorder: tcell.ColorWhite,
+
+		MangaPageChapNum:       tcell.ColorYellow,
+		MangaPageVolume:        tcell.ColorAqua,
+		MangaPageTitle:         tcell.ColorWhite,
+		MangaPageLang:          tcell.ColorWhite,
+		MangaPageDownloadStat:  tcell.ColorLime,
+		MangaPageReadStat:      tcell.ColorYellow,
+		MangaPageScanGroup:     tcell.ColorWhite,
+		MangaPagePublished:     tcell.ColorWhite,
+		MangaPageHighlight:     tcell.ColorYellow,
+		MangaPageHighlightText: tcell.ColorBlack,
+
+		SearchPageGridTitle:   tcell.ColorYellow,
+		SearchPageGridBorder:  tcell.ColorWhite,
+		SearchPageTableTitle:  tcell.ColorAqua,
+		SearchPageTableBorder: tcell.ColorWhite,
+
+		SearchFormLabel: tcell.ColorWhite,
+
+		HelpPageBorder: tcell.ColorWhite,
+
+		NotificationsPageBorder: tcell.ColorWhite,
+
+		SettingsPageBorder: tcell.ColorWhite,
+		SettingsFormLabel:  tcell.ColorWhite,
+
+		StatusBar:     tcell.ColorWhite,
+		StatusBarText: tcell.ColorBlack,
+		ToastInfo:     tcell.ColorGreen,
+		ToastError:    tcell.ColorRed,
+
+		Modal:           tcell.ColorNavy,
+		InputModalLabel: tcell.ColorWhite,
+	}
+}
+
+// SixteenColorTheme : A theme using only the 16 standard terminal colours, for terminals without 256-colour or
+// true colour support. The terminal's own palette decides how each colour looks.
+func SixteenColorTheme() Theme {
+	return Theme{
+		Background:      tcell.ColorDefault,
+		Text:            tcell.ColorDefault,
+		FieldBackground: tcell.ColorNavy,
+
+		LoginPageTitle:  tcell.ColorOlive,
+		LoginFormBorder: tcell.ColorGray,
+		LoginFormLabel:  tcell.ColorSilver,
+
+		MainPageGridTitle:   tcell.ColorOlive,
+		MainPageGridBorder:  tcell.ColorSilver,
+		MainPageTableTitle:  tcell.ColorTeal,
+		MainPageTableBorder: tcell.ColorGray,
+
+		LoggedMainPageTitle:      tcell.ColorYellow,
+		LoggedMainPagePubStatus:  tcell.ColorOlive,
+		LoggedMainPageLastUpdate: tcell.ColorAqua,
+
+		GuestMainPageTitle: tcell.ColorYellow,
+		GuestMainPageDesc:  tcell.ColorSilver,
+		GuestMainPageTag:   tcell.ColorAqua,
+
+		MangaPageGridTitle:   tcell.ColorOlive,
+		MangaPageGridBorder:  tcell.ColorSilver,
+		MangaPageTableTitle:  tcell.ColorTeal,
+		MangaPageTableBorder: tcell.ColorGray,
+
+		MangaPageInfoViewTitle:  tcell.ColorTeal,
+		MangaPageInfoViewBorder: tcell.ColorSilver,
+
+		MangaPageChapNum:       tcell.ColorYellow,
+		MangaPageVolume:        tcell.ColorOlive,
+		MangaPageTitle:         tcell.ColorAqua,
+		MangaPageLang:          tcell.ColorSilver,
+		MangaPageDownloadStat:  tcell.ColorTeal,
+		MangaPageReadStat:      tcell.ColorOlive,
+		MangaPageScanGroup:     tcell.ColorFuchsia,
+		MangaPagePublished:     tcell.ColorSilver,
+		MangaPageHighlight:     tcell.ColorGreen,
+		MangaPageHighlightText: tcell.ColorBlack,
+
+		SearchPageGridTitle:   tcell.ColorOlive,
+		SearchPageGridBorder:  tcell.ColorSilver,
+		SearchPageTableTitle:  tcell.ColorTeal,
+		SearchPageTableBorder: tcell.ColorGray,
+
+		SearchFormLabel: tcell.ColorSilver,
+
+		HelpPageBorder: tcell.ColorSilver,
+
+		NotificationsPageBorder: tcell.ColorSilver,
+
+		SettingsPageBorder: tcell.ColorSilver,
+		SettingsFormLabel:  tcell.ColorSilver,
+
+		StatusBar:     tcell.ColorNavy,
+		StatusBarText: tcell.ColorWhite,
+		ToastInfo:     tcell.ColorLime,
+		ToastError:    tcell.ColorRed,
+
+		Modal:           tcell.ColorNavy,
+		InputModalLabel: tcell.ColorWhite,
+	}
+}
+
+// colors : Get the colours of the theme, by the names used in theme files.
+func (t *Theme) colors() map[string]*tcell.Color {
+	return map[string]*tcell.Color{
+		"background":      &t.Background,
+		"text":            &t.Text,
+		"fieldBackground": &t.FieldBackground,
+
+		"loginPageTitle":  &t.LoginPageTitle,
+		"loginFormBorder": &t.LoginFormBorder,
+		"loginFormLabel":  &t.LoginFormLabel,
+
+		"mainPageGridTitle":   &t.MainPageGridTitle,
+		"mainPageGridBorder":  &t.MainPageGridBorder,
+		"mainPageTableTitle":  &t.MainPageTableTitle,
+		"mainPageTableBorder": &t.MainPageTableBorder,
+
+		"loggedMainPageTitle":      &t.LoggedMainPageTitle,
+		"loggedMainPagePubStatus":  &t.LoggedMainPagePubStatus,
+		"loggedMainPageLastUpdate": &t.LoggedMainPageLastUpdate,
+
+		"guestMainPageTitle": &t.GuestMainPageTitle,
+		"guestMainPageDesc":  &t.GuestMainPageDesc,
+		"guestMainPageTag":   &t.GuestMainPageTag,
+
+		"mangaPageGridTitle":   &t.MangaPageGridTitle,
+		"mangaPageGridBorder":  &t.MangaPageGridBorder,
+		"mangaPageTableTitle":  &t.MangaPageTableTitle,
+		"mangaPageTableBorder": &t.MangaPageTableBorder,
+
+		"mangaPageInfoViewTitle":  &t.MangaPageInfoViewTitle,
+		"mangaPageInfoViewBorder": &t.MangaPageInfoViewBorder,
+
+		"mangaPageChapNum":       &t.MangaPageChapNum,
+		"mangaPageVolume":        &t.MangaPageVolume,
+		"mangaPageTitle":         &t.MangaPageTitle,
+		"mangaPageLang":          &t.MangaPageLang,
+		"mangaPageDownloadStat":  &t.MangaPageDownloadStat,
+		"mangaPageReadStat":      &t.MangaPageReadStat,
+		"mangaPageScanGroup":     &t.MangaPageScanGroup,
+		"mangaPagePublished":     &t.MangaPagePublished,
+		"mangaPageHighlight":     &t.MangaPageHighlight,
+		"mangaPageHighlightText": &t.MangaPageHighlightText,
+
+		"searchPageGridTitle":   &t.SearchPageGridTitle,
+		"searchPageGridBorder":  &t.SearchPageGridBorder,
+		"searchPageTableTitle":  &t.SearchPageTableTitle,
+		"searchPageTableBorder": &t.SearchPageTableBorder,
+
+		"searchFormLabel": &t.SearchFormLabel,
+
+		"helpPageBorder": &t.HelpPageBorder,
+
+		"notificationsPageBorder": &t.NotificationsPageBorder,
+
+		"settingsPageBorder": &t.SettingsPageBorder,
+		"settingsFormLabel":  &t.SettingsFormLabel,
+
+		"statusBar":     &t.StatusBar,
+		"statusBarText": &t.StatusBarText,
+		"toastInfo":     &t.ToastInfo,
+		"toastError":    &t.ToastError,
+
+		"modal":           &t.Modal,
+		"inputModalLabel": &t.InputModalLabel,
+	}
+}
+
+// ParseColor : Parse a colour, given by its name (e.g. `orange`), as a hex value (e.g. `#ffa500`), or as
+// `default` for the terminal's own colour.
+func ParseColor(s string) (tcell.Color, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "default" {
+		return tcell.ColorDefault, nil
+	}
+	if strings.HasPrefix(s, "#") && len(s) != 7 {
+		return tcell.ColorDefault, fmt.Errorf("hex colour %q must be in the form #rrggbb", s)
+	}
+	color := tcell.GetColor(s)
+	if color == tcell.ColorDefault {
+		return tcell.ColorDefault, fmt.Errorf("unknown colour %q", s)
+	}
+	return color, nil
+}
+
+// NewTheme : Create a theme from a built-in theme, with some of its colours changed, by the names used in theme
+// files. Returns every problem found, such as unknown themes, colour names and colours. Colours that cannot
+// be parsed are left as they are in the built-in theme.
+func NewTheme(base string, overrides map[string]string) (Theme, []error) {
+	var problems []error
+	if base == "" {
+		base = DefaultThemeName
+	}
+	builtin, ok := Themes[base]
+	if !ok {
+		problems = append(problems, fmt.Errorf("unknown theme %q, using %q", base, DefaultThemeName))
+		builtin = Themes[DefaultThemeName]
+	}
+	theme := builtin()
+
+	// Apply the overrides in a fixed order, so that problems are reported consistently.
+	names := make([]string, 0, len(overrides))
+	for name := range overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	colors := theme.colors()
+	for _, name := range names {
+		field, ok := colors[name]
+		if !ok {
+			problems = append(problems, fmt.Errorf("unknown colour name %q", name))
+			continue
+		}
+		color, err := ParseColor(overrides[name])
+		if err != nil {
+			problems = append(problems, fmt.Errorf("%q: %s", name, err.Error()))
+			continue
+		}
+		*field = color
+	}
+	return theme, problems
+}
+
+// ThemeNames : Get the names of the built-in themes, in alphabetical order.
+func ThemeNames() []string {
+	names := make([]string, 0, len(Themes))
+	for name := range Themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// FuzzyMatch : Check whether every character of the pattern appears in the text, in order, ignoring case.
+// Returns a score for how well the text matches, which is higher for characters that are next to each other or
+// at the start of words. Empty patterns match everything with a score of 0.
+func FuzzyMatch(pattern, text string) (int, bool) {
+	pattern = strings.ToLower(strings.Join(strings.Fields(pattern), ""))
+	lower := []rune(strings.ToLower(text))
+	original := []rune(text)
+
+	score, prev := 0, -2
+	i := 0
+	for _, p := range pattern {
+		// Find the next occurrence of the character.
+		for i < len(lower) && lower[i] != p {
+			i++
+		}
+		if i == len(lower) {
+			return 0, false
+		}
+
+		score++
+		if i == prev+1 { // Characters next to each other.
+			score += 5
+		}
+		if i == 0 || !unicode.IsLetter(original[i-1]) && !unicode.IsDigit(original[i-1]) ||
+			unicode.IsUpper(original[i]) && unicode.IsLower(original[i-1]) { // Start of a word.
+			score += 3
+		}
+		prev = i
+		i++
+	}
+	return score, true
+}
@@ -1,20 +1,24 @@
 package utils
 
 const (
-	LoginPageID  = "login_page" // Page IDs
-	MainPageID   = "main_page"
-	MangaPageID  = "manga_page"
-	HelpPageID   = "help_page"
-	SearchPageID = "search_page"
+	LoginPageID         = "login_page" // Page IDs
+	MainPageID          = "main_page"
+	MangaPageID         = "manga_page"
+	HelpPageID          = "help_page"
+	SearchPageID        = "search_page"
+	NotificationsPageID = "notifications_page"
+	SettingsPageID      = "settings_page"
 
-	LoginLogoutCfmModalID        = "logout_modal" // Modal IDs
-	StoreCredentialErrorModalID  = "store_cred_error_modal"
-	DownloadChaptersModalID      = "download_chapters_modal"
-	DownloadFinishedModalID      = "download_error_modal"
-	ToggleReadChapterModalID     = "toggle_read_chapters_modal"
-	ToggleFollowMangaModalID     = "toggle_follow_manga_modal"
-	ToggleFollowMangaDoneModalID = "toggle_follow_manga_done_modal"
-	GenericAPIErrorModalID       = "api_error_modal"
-	NotLoggedInErrorModalID      = "not_logged_in_error_modal"
-	OffsetErrorModalID           = "offset_error_modal"
+	LoginLogoutCfmModalID    = "logout_modal" // Modal IDs
+	DownloadChaptersModalID  = "download_chapters_modal"
+	DownloadFinishedModalID  = "download_error_modal"
+	ToggleReadChapterModalID = "toggle_read_chapters_modal"
+	ToggleFollowMangaModalID = "toggle_follow_manga_modal"
+	SelectRangeModalID       = "select_range_modal"
+	VimFindModalID           = "vim_find_modal"
+	VimCommandModalID        = "vim_command_modal"
+	PaletteModalID           = "palette_modal"
+	SessionEndedModalID      = "session_ended_modal"
+	ProfileModalID           = "profile_modal"
+	NewProfileModalID        = "new_profile_modal"
 )
@@ -0,0 +1,265 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// KeyAction : An action that can be bound to keys.
+type KeyAction struct {
+	Name        string   // Name used in the configuration, in the form `scope.action`.
+	Description string   // Shown on the help page.
+	Defaults    []string // Key sequences bound to the action by default.
+}
+
+// KeyScope : A group of actions that are available together, such as the actions of a page.
+type KeyScope struct {
+	Name    string
+	Title   string // Shown on the help page.
+	Actions []*KeyAction
+	Layer   bool // Whether the actions are layered on top of the actions of other scopes.
+}
+
+// Keymap : A registry of the actions of each scope, and the key sequences bound to them.
+// Actions of the global scope are available everywhere, so their keys must not clash with any other scope.
+// Layers are optional scopes whose actions are available alongside those of other scopes, when enabled.
+type Keymap struct {
+	global   string
+	scopes   []*KeyScope
+	actions  map[string]*KeyAction    // Registered actions, by name.
+	bindings map[string][]KeySequence // Key sequences bound to each action, by action name.
+	disabled map[string]bool          // Scopes that have been disabled.
+}
+
+// NewKeymap : Create a new keymap. Actions of the global scope are available everywhere.
+func NewKeymap(global string) *Keymap {
+	return &Keymap{
+		global:   global,
+		actions:  map[string]*KeyAction{},
+		bindings: map[string][]KeySequence{},
+		disabled: map[string]bool{},
+	}
+}
+
+// Register : Register a scope and its actions, bound to their default keys.
+// Panics if a default key is invalid, as it is a programming error.
+func (k *Keymap) Register(name, title string, actions ...*KeyAction) {
+	k.scopes = append(k.scopes, &KeyScope{Name: name, Title: title, Actions: actions})
+	for _, action := range actions {
+		k.actions[action.Name] = action
+		k.bindings[action.Name] = mustParseSequences(action.Defaults)
+	}
+}
+
+// RegisterLayer : Register a layer and its actions, bound to their default keys. The layer is disabled until
+// it is enabled with SetEnabled.
+func (k *Keymap) RegisterLayer(name, title string, actions ...*KeyAction) {
+	k.Register(name, title, actions...)
+	k.scopes[len(k.scopes)-1].Layer = true
+	k.disabled[name] = true
+}
+
+// SetEnabled : Enable or disable a scope. The actions of a disabled scope are never called.
+func (k *Keymap) SetEnabled(scope string, enabled bool) {
+	k.disabled[scope] = !enabled
+}
+
+// Scopes : Get the enabled scopes, in the order they were registered.
+func (k *Keymap) Scopes() []*KeyScope {
+	var scopes []*KeyScope
+	for _, scope := range k.scopes {
+		if !k.disabled[scope.Name] {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// Bindings : Get the key sequences bound to an action.
+func (k *Keymap) Bindings(action string) []KeySequence {
+	return k.bindings[action]
+}
+
+// Describe : Get the key sequences bound to an action, separated by `/`. Empty if no keys are bound.
+func (k *Keymap) Describe(action string) string {
+	var names []string
+	for _, seq := range k.bindings[action] {
+		names = append(names, seq.String())
+	}
+	return strings.Join(names, "/")
+}
+
+// Load : Bind actions to their default keys, then to the keys given in overrides, by action name.
+// An action can be unbound by giving it no keys. Returns every problem found, such as unknown actions,
+// invalid keys and conflicting bindings. Actions with invalid keys keep their default keys.
+func (k *Keymap) Load(overrides map[string][]string) []error {
+	var problems []error
+	for name, action := range k.actions {
+		k.bindings[name] = mustParseSequences(action.Defaults)
+	}
+
+	// Apply the overrides in a fixed order, so that problems are reported consistently.
+	names := make([]string, 0, len(overrides))
+	for name := range overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := k.actions[name]; !ok {
+			problems = append(problems, fmt.Errorf("unknown action %q", name))
+			continue
+		}
+		var (
+			sequences []KeySequence
+			invalid   bool
+		)
+		for _, s := range overrides[name] {
+			seq, err := ParseKeySequence(s)
+			if err != nil {
+				problems = append(problems, fmt.Errorf("action %q: %s", name, err.Error()))
+				invalid = true
+				break
+			}
+			sequences = append(sequences, seq)
+		}
+		if !invalid {
+			k.bindings[name] = sequences
+		}
+	}
+	return append(problems, k.conflicts()...)
+}
+
+// conflicts : Find key sequences that cannot be told apart, because they are the same or one starts with the
+// other, within each enabled scope and between each scope and the global scope and enabled layers.
+func (k *Keymap) conflicts() []error {
+	type binding struct {
+		action string
+		seq    KeySequence
+	}
+	var (
+		problems []error
+		global   []binding
+		layers   []binding
+	)
+	scopeBindings := func(scope *KeyScope) []binding {
+		var bindings []binding
+		for _, action := range scope.Actions {
+			for _, seq := range k.bindings[action.Name] {
+				bindings = append(bindings, binding{action: action.Name, seq: seq})
+			}
+		}
+		return bindings
+	}
+	for _, scope := range k.Scopes() {
+		if scope.Name == k.global {
+			global = scopeBindings(scope)
+		} else if scope.Layer {
+			layers = append(layers, scopeBindings(scope)...)
+		}
+	}
+
+	for _, scope := range k.Scopes() {
+		bindings := scopeBindings(scope)
+		own := len(bindings) // Bindings of other scopes are compared against each other in their own scope.
+		if scope.Name != k.global {
+			bindings = append(bindings, global...)
+		}
+		if scope.Name != k.global && !scope.Layer {
+			bindings = append(bindings, layers...)
+		}
+		for i, a := range bindings[:own] {
+			for _, b := range bindings[i+1:] {
+				if a.action == b.action {
+					continue
+				}
+				switch {
+				case len(a.seq) == len(b.seq) && a.seq.HasPrefix(b.seq):
+					problems = append(problems, fmt.Errorf("%q and %q are both bound to %s",
+						a.action, b.action, a.seq))
+				case a.seq.HasPrefix(b.seq), b.seq.HasPrefix(a.seq):
+					problems = append(problems, fmt.Errorf("%q (%s) and %q (%s) conflict, as one starts with the other",
+						a.action, a.seq, b.action, b.seq))
+				}
+			}
+		}
+	}
+	return problems
+}
+
+// mustParseSequences : Parse key sequences, panicking if any are invalid.
+func mustParseSequences(keys []string) []KeySequence {
+	var sequences []KeySequence
+	for _, s := range keys {
+		seq, err := ParseKeySequence(s)
+		if err != nil {
+			panic(err)
+		}
+		sequences = append(sequences, seq)
+	}
+	return sequences
+}
+
+// KeyHandler : Calls the handlers of actions when the keys bound to them are pressed.
+// Only actions given a handler are considered, so the actions of a scope may be handled by several primitives.
+type KeyHandler struct {
+	keymap   *Keymap
+	handlers map[string]func(event *tcell.EventKey) *tcell.EventKey
+	pending  KeySequence // The keys pressed so far of a key sequence that has not been completed.
+}
+
+// NewHandler : Create a handler calling the given functions, by action name. Each function returns the event
+// to forward to the primitive, or nil if the event has been consumed, as for tview input captures.
+func (k *Keymap) NewHandler(handlers map[string]func(event *tcell.EventKey) *tcell.EventKey) *KeyHandler {
+	return &KeyHandler{
+		keymap:   k,
+		handlers: handlers,
+	}
+}
+
+// Handle : Handle a key event, for use as a tview input capture. Keys that start a key sequence are consumed
+// until the sequence is completed. Returns the event to forward to the primitive, or nil if it was consumed.
+func (h *KeyHandler) Handle(event *tcell.EventKey) *tcell.EventKey {
+	seq := append(append(KeySequence{}, h.pending...), KeyFromEvent(event))
+	h.pending = nil
+	for {
+		action, isPrefix := h.match(seq)
+		if action != "" {
+			return h.handlers[action](event)
+		} else if isPrefix {
+			h.pending = seq
+			return nil
+		} else if len(seq) == 1 {
+			return event
+		}
+		// The keys pressed before do not complete any sequence, so try the latest key on its own.
+		seq = seq[len(seq)-1:]
+	}
+}
+
+// Pending : Get the keys pressed so far of a key sequence that has not been completed.
+func (h *KeyHandler) Pending() KeySequence {
+	return h.pending
+}
+
+// match : Find the action bound to a key sequence, and whether the sequence starts a longer key sequence.
+func (h *KeyHandler) match(seq KeySequence) (string, bool) {
+	isPrefix := false
+	for _, scope := range h.keymap.Scopes() {
+		for _, action := range scope.Actions {
+			if _, ok := h.handlers[action.Name]; !ok {
+				continue
+			}
+			for _, bound := range h.keymap.bindings[action.Name] {
+				if len(bound) == len(seq) && bound.HasPrefix(seq) {
+					return action.Name, false
+				} else if bound.HasPrefix(seq) {
+					isPrefix = true
+				}
+			}
+		}
+	}
+	return "", isPrefix
+}
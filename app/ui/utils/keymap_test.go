@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// newTestKeymap : Create a keymap with a global scope, a page and a layer, like the one of the app.
+func newTestKeymap() *Keymap {
+	k := NewKeymap("universal")
+	k.Register("universal", "Universal",
+		&KeyAction{Name: "universal.search", Defaults: []string{"Ctrl+S"}},
+		&KeyAction{Name: "universal.help", Defaults: []string{"Ctrl+K"}},
+	)
+	k.Register("manga", "Manga Page",
+		&KeyAction{Name: "manga.download", Defaults: []string{"Enter"}},
+		&KeyAction{Name: "manga.select", Defaults: []string{"Ctrl+E"}},
+	)
+	k.RegisterLayer("vim", "Vim Mode",
+		&KeyAction{Name: "vim.top", Defaults: []string{"g g"}},
+		&KeyAction{Name: "vim.down", Defaults: []string{"j"}},
+	)
+	return k
+}
+
+func TestKeymapLoad(t *testing.T) {
+	tests := []struct {
+		name      string
+		vim       bool
+		overrides map[string][]string
+		problems  []string // Part of each problem, in order.
+	}{
+		{name: "defaults", vim: true},
+		{
+			name:      "valid override",
+			overrides: map[string][]string{"manga.download": {"d", "Ctrl+D"}, "universal.help": {}},
+		},
+		{
+			name:      "unknown action",
+			overrides: map[string][]string{"manga.downlaod": {"d"}},
+			problems:  []string{`unknown action "manga.downlaod"`},
+		},
+		{
+			name:      "invalid key",
+			overrides: map[string][]string{"manga.download": {"Hyper+d"}},
+			problems:  []string{`action "manga.download": unknown modifier "Hyper"`},
+		},
+		{
+			name:      "same key in a scope",
+			overrides: map[string][]string{"manga.select": {"Enter"}},
+			problems:  []string{`"manga.download" and "manga.select" are both bound to Enter`},
+		},
+		{
+			name:      "same key as the global scope",
+			overrides: map[string][]string{"manga.select": {"Ctrl+S"}},
+			problems:  []string{`"manga.select" and "universal.search" are both bound to Ctrl+S`},
+		},
+		{
+			name:      "prefix of another sequence",
+			vim:       true,
+			overrides: map[string][]string{"vim.down": {"g"}},
+			problems:  []string{`"vim.top" (g g) and "vim.down" (g) conflict, as one starts with the other`},
+		},
+		{
+			// Layers that are not enabled do not conflict with anything.
+			name:      "disabled layer",
+			overrides: map[string][]string{"vim.down": {"Enter"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := newTestKeymap()
+			k.SetEnabled("vim", tt.vim)
+			problems := k.Load(tt.overrides)
+			if len(problems) != len(tt.problems) {
+				t.Fatalf("Load found problems %v, want %d", problems, len(tt.problems))
+			}
+			for i, problem := range problems {
+				if !strings.Contains(problem.Error(), tt.problems[i]) {
+					t.Errorf("problem %q, want one with %q", problem, tt.problems[i])
+				}
+			}
+		})
+	}
+}
+
+func TestKeymapLoadBindings(t *testing.T) {
+	k := newTestKeymap()
+	k.Load(map[string][]string{
+		"manga.download": {"d", "Ctrl+D"},
+		"manga.select":   {"Hyper+e"}, // Keeps its default keys.
+		"universal.help": {},          // Unbound.
+	})
+	for action, want := range map[string]string{
+		"manga.download":   "d/Ctrl+D",
+		"manga.select":     "Ctrl+E",
+		"universal.help":   "",
+		"universal.search": "Ctrl+S",
+	} {
+		if got := k.Describe(action); got != want {
+			t.Errorf("%s is bound to %q, want %q", action, got, want)
+		}
+	}
+
+	// Loading again starts from the default keys.
+	k.Load(nil)
+	if got := k.Describe("manga.download"); got != "Enter" {
+		t.Errorf("manga.download is bound to %q after loading again, want Enter", got)
+	}
+}
+
+func TestKeyHandlerSequences(t *testing.T) {
+	k := newTestKeymap()
+	k.SetEnabled("vim", true)
+	var called []string
+	handler := func(action string) func(event *tcell.EventKey) *tcell.EventKey {
+		return func(event *tcell.EventKey) *tcell.EventKey {
+			called = append(called, action)
+			return nil
+		}
+	}
+	h := k.NewHandler(map[string]func(event *tcell.EventKey) *tcell.EventKey{
+		"vim.top":  handler("vim.top"),
+		"vim.down": handler("vim.down"),
+	})
+	press := func(r rune) *tcell.EventKey {
+		return h.Handle(tcell.NewEventKey(tcell.KeyRune, r, tcell.ModNone))
+	}
+
+	// The first key of a sequence is held until the sequence is completed.
+	if press('g') != nil || h.Pending().String() != "g" || len(called) != 0 {
+		t.Fatalf("first key of g g: pending %q, called %v, want it held", h.Pending(), called)
+	}
+	if press('g') != nil || len(h.Pending()) != 0 || strings.Join(called, ",") != "vim.top" {
+		t.Fatalf("g g called %v, want vim.top", called)
+	}
+
+	// A key that does not complete the sequence is handled on its own.
+	called = nil
+	press('g')
+	if press('j') != nil || strings.Join(called, ",") != "vim.down" {
+		t.Errorf("g j called %v, want vim.down", called)
+	}
+	if event := press('x'); event == nil {
+		t.Error("unbound key was consumed")
+	}
+}
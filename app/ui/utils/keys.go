@@ -0,0 +1,174 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// Key : A single key press, such as `Ctrl+S`, `Shift+Up` or `g`.
+type Key struct {
+	Key  tcell.Key     // tcell.KeyRune for keys that type a character.
+	Rune rune          // The character typed, if Key is tcell.KeyRune.
+	Mod  tcell.ModMask // Modifiers not already part of Key or Rune, such as Alt.
+}
+
+// KeySequence : One or more key presses, such as `g g`, that are pressed one after the other.
+type KeySequence []Key
+
+// keysByName : Lookup of special keys by their lowercase name, e.g. `enter`, `up` and `f5`.
+var keysByName = map[string]tcell.Key{
+	"escape": tcell.KeyEsc,
+	"return": tcell.KeyEnter,
+}
+
+func init() {
+	for key, name := range tcell.KeyNames {
+		keysByName[strings.ToLower(name)] = key
+	}
+}
+
+// KeyFromEvent : Get the key pressed in a key event.
+// Modifiers implied by the key itself, such as Ctrl for tcell.KeyCtrlS, are removed so that keys compare equal
+// regardless of how the terminal reported them.
+func KeyFromEvent(event *tcell.EventKey) Key {
+	key := Key{Key: event.Key(), Mod: event.Modifiers()}
+	switch {
+	case key.Key == tcell.KeyRune:
+		key.Rune = event.Rune()
+		key.Mod &^= tcell.ModShift // Shift is already part of the character, e.g. `G`.
+	case key.Key >= tcell.KeyCtrlA && key.Key <= tcell.KeyCtrlZ, key.Key == tcell.KeyCtrlSpace:
+		key.Mod &^= tcell.ModCtrl | tcell.ModShift
+	}
+	return key
+}
+
+// ParseKey : Parse a key, such as `Ctrl+S`, `Alt+x`, `Shift+Up`, `Enter`, `F5`, `Space` or `g`.
+// Modifiers and key names are case-insensitive, but characters are not: `G` is Shift+g.
+func ParseKey(s string) (Key, error) {
+	// The key itself comes after the last `+`, unless the key is `+`.
+	var mods []string
+	name := s
+	if strings.HasSuffix(s, "++") || s == "+" {
+		mods, name = strings.Split(strings.TrimSuffix(s, "++"), "+"), "+"
+		if s == "+" {
+			mods = nil
+		}
+	} else if i := strings.LastIndex(s, "+"); i != -1 {
+		mods, name = strings.Split(s[:i], "+"), s[i+1:]
+	}
+
+	key := Key{}
+	var ctrl bool
+	for _, mod := range mods {
+		switch strings.ToLower(strings.TrimSpace(mod)) {
+		case "ctrl", "control":
+			ctrl = true
+		case "alt", "meta":
+			key.Mod |= tcell.ModAlt
+		case "shift":
+			key.Mod |= tcell.ModShift
+		default:
+			return Key{}, fmt.Errorf("unknown modifier %q in key %q", mod, s)
+		}
+	}
+
+	runes := []rune(name)
+	switch {
+	case len(runes) == 1:
+		key.Key, key.Rune = tcell.KeyRune, runes[0]
+		if key.Mod&tcell.ModShift != 0 { // Shift+g is G.
+			key.Rune = unicode.ToUpper(key.Rune)
+			key.Mod &^= tcell.ModShift
+		}
+		if ctrl {
+			// Ctrl with a letter is a key of its own.
+			lower := unicode.ToLower(key.Rune)
+			if lower < 'a' || lower > 'z' {
+				return Key{}, fmt.Errorf("only letters and special keys can be used with Ctrl, in key %q", s)
+			}
+			key.Key, key.Rune = tcell.KeyCtrlA+tcell.Key(lower-'a'), 0
+		}
+	case strings.EqualFold(name, "space"):
+		key.Key, key.Rune = tcell.KeyRune, ' '
+		if ctrl {
+			key.Key, key.Rune = tcell.KeyCtrlSpace, 0
+		}
+	default:
+		named, ok := keysByName[strings.ToLower(name)]
+		if !ok {
+			return Key{}, fmt.Errorf("unknown key %q", s)
+		}
+		key.Key = named
+		if ctrl {
+			key.Mod |= tcell.ModCtrl
+		}
+	}
+	return key, nil
+}
+
+// ParseKeySequence : Parse a sequence of keys separated by spaces, such as `g g` or `Ctrl+X Ctrl+S`.
+func ParseKeySequence(s string) (KeySequence, error) {
+	var seq KeySequence
+	for _, field := range strings.Fields(s) {
+		key, err := ParseKey(field)
+		if err != nil {
+			return nil, err
+		}
+		seq = append(seq, key)
+	}
+	if len(seq) == 0 {
+		return nil, fmt.Errorf("empty key sequence")
+	}
+	return seq, nil
+}
+
+// String : Get the name of the key, in the format accepted by ParseKey.
+func (k Key) String() string {
+	var name string
+	switch {
+	case k.Key == tcell.KeyRune && k.Rune == ' ':
+		name = "Space"
+	case k.Key == tcell.KeyRune:
+		name = string(k.Rune)
+	default:
+		// Names of keys pressed with Ctrl are in the form `Ctrl-S`. Some, such as Enter, have names of their own.
+		name = strings.Replace(tcell.KeyNames[k.Key], "Ctrl-", "Ctrl+", 1)
+	}
+
+	var mods []string
+	if k.Mod&tcell.ModCtrl != 0 {
+		mods = append(mods, "Ctrl")
+	}
+	if k.Mod&tcell.ModAlt != 0 {
+		mods = append(mods, "Alt")
+	}
+	if k.Mod&tcell.ModShift != 0 {
+		mods = append(mods, "Shift")
+	}
+	return strings.Join(append(mods, name), "+")
+}
+
+// String : Get the names of the keys in the sequence, in the format accepted by ParseKeySequence.
+func (s KeySequence) String() string {
+	names := make([]string, len(s))
+	for i, key := range s {
+		names[i] = key.String()
+	}
+	return strings.Join(names, " ")
+}
+
+// HasPrefix : Check whether the sequence starts with all the keys of another sequence.
+func (s KeySequence) HasPrefix(prefix KeySequence) bool {
+	if len(prefix) > len(s) {
+		return false
+	}
+	for i, key := range prefix {
+		if s[i] != key {
+			return false
+		}
+	}
+	return true
+}
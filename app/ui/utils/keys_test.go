@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		s    string
+		want Key
+		name string // The name of the key, as it is described.
+	}{
+		{s: "g", want: Key{Key: tcell.KeyRune, Rune: 'g'}, name: "g"},
+		{s: "G", want: Key{Key: tcell.KeyRune, Rune: 'G'}, name: "G"},
+		{s: "Shift+g", want: Key{Key: tcell.KeyRune, Rune: 'G'}, name: "G"},
+		{s: "ctrl+s", want: Key{Key: tcell.KeyCtrlS}, name: "Ctrl+S"},
+		{s: "Ctrl+Shift+S", want: Key{Key: tcell.KeyCtrlS}, name: "Ctrl+S"}, // Terminals cannot tell these apart.
+		{s: "Alt+x", want: Key{Key: tcell.KeyRune, Rune: 'x', Mod: tcell.ModAlt}, name: "Alt+x"},
+		{s: "Shift+Up", want: Key{Key: tcell.KeyUp, Mod: tcell.ModShift}, name: "Shift+Up"},
+		{s: "Alt+Left", want: Key{Key: tcell.KeyLeft, Mod: tcell.ModAlt}, name: "Alt+Left"},
+		{s: "enter", want: Key{Key: tcell.KeyEnter}, name: "Enter"},
+		{s: "Escape", want: Key{Key: tcell.KeyEsc}, name: "Esc"},
+		{s: "F5", want: Key{Key: tcell.KeyF5}, name: "F5"},
+		{s: "Space", want: Key{Key: tcell.KeyRune, Rune: ' '}, name: "Space"},
+		{s: "+", want: Key{Key: tcell.KeyRune, Rune: '+'}, name: "+"},
+		{s: "Alt++", want: Key{Key: tcell.KeyRune, Rune: '+', Mod: tcell.ModAlt}, name: "Alt++"},
+	}
+	for _, tt := range tests {
+		got, err := ParseKey(tt.s)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseKey(%q) = %+v, %v, want %+v", tt.s, got, err, tt.want)
+			continue
+		}
+		if name := got.String(); name != tt.name {
+			t.Errorf("ParseKey(%q) is described as %q, want %q", tt.s, name, tt.name)
+		}
+	}
+
+	for _, s := range []string{"", "Hyper+x", "Ctrl+1", "NotAKey"} {
+		if key, err := ParseKey(s); err == nil {
+			t.Errorf("ParseKey(%q) = %+v, want an error", s, key)
+		}
+	}
+}
+
+func TestKeyFromEvent(t *testing.T) {
+	// Keys compare equal however the terminal reported them.
+	tests := []struct {
+		event *tcell.EventKey
+		want  string
+	}{
+		{event: tcell.NewEventKey(tcell.KeyCtrlS, 0, tcell.ModCtrl), want: "Ctrl+S"},
+		{event: tcell.NewEventKey(tcell.KeyRune, 'G', tcell.ModShift), want: "G"},
+		{event: tcell.NewEventKey(tcell.KeyUp, 0, tcell.ModShift), want: "Shift+Up"},
+	}
+	for _, tt := range tests {
+		want, err := ParseKey(tt.want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := KeyFromEvent(tt.event); got != want {
+			t.Errorf("KeyFromEvent(%s) = %+v, want %+v", tt.event.Name(), got, want)
+		}
+	}
+}
+
+func TestParseKeySequence(t *testing.T) {
+	seq, err := ParseKeySequence("  g   g ")
+	if err != nil || seq.String() != "g g" {
+		t.Fatalf("ParseKeySequence = %q, %v, want g g", seq, err)
+	}
+	g, _ := ParseKeySequence("g")
+	ctrlX, _ := ParseKeySequence("Ctrl+X")
+	if !seq.HasPrefix(g) || !seq.HasPrefix(seq) || seq.HasPrefix(ctrlX) || g.HasPrefix(seq) {
+		t.Error("HasPrefix does not check whether a sequence starts with another one")
+	}
+
+	for _, s := range []string{"", "   ", "g Hyper+x"} {
+		if seq, err := ParseKeySequence(s); err == nil {
+			t.Errorf("ParseKeySequence(%q) = %q, want an error", s, seq)
+		}
+	}
+}
@@ -47,3 +47,14 @@ func (s *SelectorWrapper) AddSelection(row int) {
 func (s *SelectorWrapper) RemoveSelection(row int) {
 	delete(s.Selection, row)
 }
+
+// MoveSelections : Update the Selection after rows have been moved, using a mapping of old rows to new rows.
+func (s *SelectorWrapper) MoveSelections(mapping map[int]int) {
+	selection := map[int]struct{}{}
+	for row := range s.Selection {
+		if newRow, ok := mapping[row]; ok {
+			selection[newRow] = struct{}{}
+		}
+	}
+	s.Selection = selection
+}
@@ -0,0 +1,163 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rivo/tview"
+)
+
+// SortField : A field that a table can be sorted by.
+type SortField struct {
+	Name   string                             // Name of the field, shown to the user.
+	Column int                                // The table column showing the field, or -1 if it is not shown.
+	Less   func(a, b []*tview.TableCell) bool // Compares the cells of two rows in ascending order.
+}
+
+// SortWrapper : A wrapper to keep track of how a table is sorted. Used by the main and manga pages.
+type SortWrapper struct {
+	Fields     []SortField // The fields that the table can be sorted by.
+	Current    int         // Index of the field that the table is currently sorted by.
+	Descending bool        // Whether the table is sorted in descending order.
+}
+
+// SortByColumn : Sort by the field shown in a column. If the table is already sorted by that field, the
+// sort direction is reversed instead. Returns false if no field is shown in the column.
+func (s *SortWrapper) SortByColumn(column int) bool {
+	for i, field := range s.Fields {
+		if field.Column != column {
+			continue
+		}
+		if i == s.Current {
+			s.Descending = !s.Descending
+		} else {
+			s.Current, s.Descending = i, false
+		}
+		return true
+	}
+	return false
+}
+
+// NextField : Sort by the next field, in ascending order.
+func (s *SortWrapper) NextField() {
+	s.Current = (s.Current + 1) % len(s.Fields)
+	s.Descending = false
+}
+
+// ToggleDirection : Reverse the sort direction.
+func (s *SortWrapper) ToggleDirection() {
+	s.Descending = !s.Descending
+}
+
+// Description : Get a short description of the current sort order.
+func (s *SortWrapper) Description() string {
+	return fmt.Sprintf("%s %s", s.Fields[s.Current].Name, s.arrow())
+}
+
+// SetHeaders : Update the header row of a table to show the current sort order. Clicking on the header of a
+// sortable column sorts the table by that column, after which onSort is called to re-sort the table.
+func (s *SortWrapper) SetHeaders(table *tview.Table, onSort func()) {
+	for i, field := range s.Fields {
+		if field.Column < 0 {
+			continue
+		}
+		column := field.Column
+		cell := table.GetCell(0, column)
+
+		// Remove any previous arrow before adding the current one.
+		text := strings.TrimSuffix(strings.TrimSuffix(cell.Text, " ▲"), " ▼")
+		if i == s.Current {
+			text = fmt.Sprintf("%s %s", text, s.arrow())
+		}
+		cell.SetText(text).SetClickedFunc(func() bool {
+			s.SortByColumn(column)
+			onSort()
+			return true
+		})
+	}
+}
+
+// arrow : Get the arrow showing the sort direction.
+func (s *SortWrapper) arrow() string {
+	if s.Descending {
+		return "▼"
+	}
+	return "▲"
+}
+
+// Sort : Sort the rows of a table, excluding the header row, by the current field.
+// Rows that compare equal keep their relative order.
+// Returns a mapping of each row's old index to its new index.
+func (s *SortWrapper) Sort(table *tview.Table) map[int]int {
+	// Take a copy of the rows of the table, remembering where each row was.
+	var rows [][]*tview.TableCell
+	oldRows := map[*tview.TableCell]int{}
+	for row := 1; row < table.GetRowCount(); row++ {
+		cells := make([]*tview.TableCell, table.GetColumnCount())
+		for col := range cells {
+			cells[col] = table.GetCell(row, col)
+		}
+		rows = append(rows, cells)
+		oldRows[cells[0]] = row
+	}
+	s.SortRows(rows)
+
+	// Put the rows back in their new order.
+	mapping := map[int]int{}
+	for i, cells := range rows {
+		mapping[oldRows[cells[0]]] = i + 1
+		for col, cell := range cells {
+			table.SetCell(i+1, col, cell)
+		}
+	}
+	return mapping
+}
+
+// SortRows : Sort rows of table cells by the current field. Rows that compare equal keep their relative order.
+func (s *SortWrapper) SortRows(rows [][]*tview.TableCell) {
+	less := s.Fields[s.Current].Less
+	sort.SliceStable(rows, func(i, j int) bool {
+		if s.Descending {
+			return less(rows[j], rows[i])
+		}
+		return less(rows[i], rows[j])
+	})
+}
+
+// CompareNumeric : Compare two strings, treating any leading numbers as numbers rather than text.
+// For example, "10.5" is ordered between "10" and "11". Strings without a leading number, such as chapters without
+// a number, are ordered after those with one.
+// Returns a negative number if a < b, zero if a == b, and a positive number if a > b.
+func CompareNumeric(a, b string) int {
+	aNum, aRest, aOk := splitNumber(a)
+	bNum, bRest, bOk := splitNumber(b)
+	switch {
+	case aOk && bOk && aNum != bNum:
+		if aNum < bNum {
+			return -1
+		}
+		return 1
+	case aOk != bOk:
+		if aOk {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(strings.ToLower(aRest), strings.ToLower(bRest))
+}
+
+// splitNumber : Split a string into its leading number and the rest of the string.
+func splitNumber(s string) (float64, string, bool) {
+	s = strings.TrimSpace(s)
+	end := 0
+	for end < len(s) && (s[end] >= '0' && s[end] <= '9' || s[end] == '.') {
+		end++
+	}
+	num, err := strconv.ParseFloat(strings.TrimRight(s[:end], "."), 64)
+	if err != nil {
+		return 0, s, false
+	}
+	return num, s[end:], true
+}
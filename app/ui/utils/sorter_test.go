@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rivo/tview"
+)
+
+func TestCompareNumeric(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "2", b: "10", want: -1},
+		{a: "10", b: "10.5", want: -1},
+		{a: "10.5", b: "11", want: -1},
+		{a: "11", b: "10.5", want: 1},
+		{a: "10", b: "10.0", want: 0},
+		{a: " 7 ", b: "7", want: 0},
+		{a: "1", b: "1a", want: -1}, // The rest is compared as text.
+		{a: "5B", b: "5a", want: 1},
+		// Chapters without a number come after those with one.
+		{a: "", b: "1", want: 1},
+		{a: "1", b: "", want: -1},
+		{a: "Oneshot", b: "100", want: 1},
+		{a: "", b: "", want: 0},
+		{a: "extra", b: "Oneshot", want: -1},
+	}
+	for _, tt := range tests {
+		if got := CompareNumeric(tt.a, tt.b); sign(got) != tt.want {
+			t.Errorf("CompareNumeric(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+// sign : Get -1, 0 or 1 for a negative number, zero or a positive number.
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+func TestSortRows(t *testing.T) {
+	// Rows have a chapter number, and a name to tell apart rows that compare equal.
+	sorter := &SortWrapper{Fields: []SortField{{Name: "Chapter", Column: 0, Less: func(a, b []*tview.TableCell) bool {
+		return CompareNumeric(a[0].Text, b[0].Text) < 0
+	}}}}
+	tests := []struct {
+		descending bool
+		rows       string // Rows as chapter:name, in their order before sorting.
+		want       string // The names of the rows after sorting.
+	}{
+		{rows: "11:a 10.5:b 10:c 2:d", want: "d c b a"},
+		{rows: ":a 1:b Oneshot:c 0.5:d", want: "d b a c"},
+		{rows: "2:a 1:b 2:c 1:d 2:e", want: "b d a c e"},             // Ties keep their order.
+		{rows: "2:a 1:b 2:c 1:d", descending: true, want: "a c b d"}, // Also when descending.
+		{rows: "10:a :b 10.5:c", descending: true, want: "b c a"},
+	}
+	for _, tt := range tests {
+		var rows [][]*tview.TableCell
+		for _, row := range strings.Fields(tt.rows) {
+			chapter, name, _ := strings.Cut(row, ":")
+			rows = append(rows, []*tview.TableCell{tview.NewTableCell(chapter), tview.NewTableCell(name)})
+		}
+		sorter.Descending = tt.descending
+		sorter.SortRows(rows)
+
+		var names []string
+		for _, row := range rows {
+			names = append(names, row[1].Text)
+		}
+		if got := strings.Join(names, " "); got != tt.want {
+			t.Errorf("sorting %q (descending %t) = %q, want %q", tt.rows, tt.descending, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package ui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+
+	"github.com/darylhjd/mangadesk/app/core"
+	"github.com/darylhjd/mangadesk/app/ui/utils"
+)
+
+// vimNavigator : Vim-style navigation of a table or text view. The navigation keys are layered on top of the
+// keys of the page, and are only available when vim mode is enabled.
+type vimNavigator struct {
+	table *tview.Table    // The table to navigate, if any.
+	text  *tview.TextView // The text view to navigate, if any.
+	back  func()          // Goes back from the page. May be nil.
+	open  func()          // Opens the row under the cursor. May be nil.
+
+	count int    // The count typed before an action, such as 5 in `5j`. Zero if no count was typed.
+	term  string // The term last searched for.
+}
+
+// newTableNavigator : Creates a vimNavigator for a table. The first row of the table is taken to be a header.
+func newTableNavigator(table *tview.Table, back, open func()) *vimNavigator {
+	return &vimNavigator{table: table, back: back, open: open}
+}
+
+// newTextNavigator : Creates a vimNavigator for a text view.
+func newTextNavigator(text *tview.TextView, back func()) *vimNavigator {
+	return &vimNavigator{text: text, back: back}
+}
+
+// handleKeys : Creates an input capture that calls the handlers of actions when their keys are pressed,
+// along with the vim navigation actions. Counts typed before an action are passed to the navigation actions.
+func (v *vimNavigator) handleKeys(handlers map[string]func(event *tcell.EventKey) *tcell.EventKey) func(event *tcell.EventKey) *tcell.EventKey {
+	all := map[string]func(event *tcell.EventKey) *tcell.EventKey{
+		vimDownAction:         consume(func() { v.move(v.repeat()) }),
+		vimUpAction:           consume(func() { v.move(-v.repeat()) }),
+		vimTopAction:          consume(func() { v.goTo(v.repeat()) }),
+		vimBottomAction:       consume(func() { v.goTo(v.count) }),
+		vimHalfPageDownAction: consume(func() { v.move(v.repeat() * v.halfPage()) }),
+		vimHalfPageUpAction:   consume(func() { v.move(-v.repeat() * v.halfPage()) }),
+		vimFindAction:         consume(v.findInput),
+		vimNextMatchAction:    consume(func() { v.findNext(v.repeat()) }),
+		vimPrevMatchAction:    consume(func() { v.findNext(-v.repeat()) }),
+		vimBackAction:         consume(v.goBack),
+		vimOpenAction: consume(func() {
+			if v.open != nil {
+				v.open()
+			}
+		}),
+		vimCommandAction: consume(v.commandInput),
+	}
+	for name, handler := range handlers {
+		all[name] = handler
+	}
+
+	h := keymap.NewHandler(all)
+	return func(event *tcell.EventKey) *tcell.EventKey {
+		if isTyping(event) {
+			return event
+		}
+		// Digits typed before an action are the count for the action.
+		if core.App.Config.VimMode && len(h.Pending()) == 0 && event.Key() == tcell.KeyRune &&
+			event.Modifiers()&tcell.ModAlt == 0 {
+			if r := event.Rune(); (r >= '1' && r <= '9') || (r == '0' && v.count > 0) {
+				v.count = v.count*10 + int(r-'0')
+				return nil
+			}
+		}
+		event = h.Handle(event)
+		// The count is used up once a key sequence is complete.
+		if len(h.Pending()) == 0 {
+			v.count = 0
+		}
+		return event
+	}
+}
+
+// repeat : Get the number of times to repeat an action, which is the count typed before it or 1 by default.
+func (v *vimNavigator) repeat() int {
+	if v.count == 0 {
+		return 1
+	}
+	return v.count
+}
+
+// move : Move the cursor of the table, or scroll the text view, by a number of rows.
+func (v *vimNavigator) move(rows int) {
+	if v.table != nil {
+		row, _ := v.table.GetSelection()
+		v.selectRow(row + rows)
+	} else {
+		row, _ := v.text.GetScrollOffset()
+		v.text.ScrollTo(max(row+rows, 0), 0)
+	}
+}
+
+// goTo : Move to a line, counting from 1. Line 0 is the last line.
+func (v *vimNavigator) goTo(line int) {
+	switch {
+	case v.table != nil && line == 0:
+		v.selectRow(v.table.GetRowCount() - 1)
+	case v.table != nil:
+		v.selectRow(line)
+	case line == 0:
+		v.text.ScrollToEnd()
+	default:
+		v.text.ScrollTo(line-1, 0)
+	}
+}
+
+// selectRow : Select a row of the table, keeping within the rows below the header.
+func (v *vimNavigator) selectRow(row int) {
+	row = min(row, v.table.GetRowCount()-1)
+	v.table.Select(max(row, 1), 0)
+}
+
+// halfPage : Get the number of rows in half of the visible part of the table or text view.
+func (v *vimNavigator) halfPage() int {
+	var height int
+	if v.table != nil {
+		_, _, _, height = v.table.GetInnerRect()
+		height-- // The header row is always shown.
+	} else {
+		_, _, _, height = v.text.GetInnerRect()
+	}
+	return max(height/2, 1)
+}
+
+// goBack : Go back from the page, if possible.
+func (v *vimNavigator) goBack() {
+	if v.back != nil {
+		v.back()
+	}
+}
+
+// findInput : Allows user to search for a term, moving to the next row or line containing it.
+func (v *vimNavigator) findInput() {
+	modal := inputModal(utils.VimFindModalID, "Find", "/", "Moves to the next match. Case-insensitive.", func(text string) {
+		if text == "" {
+			return
+		}
+		v.term = text
+		v.findNext(1)
+	})
+	ShowModal(utils.VimFindModalID, modal)
+}
+
+// findNext : Move to a later (positive) or earlier (negative) row or line containing the search term.
+func (v *vimNavigator) findNext(matches int) {
+	if v.term == "" {
+		return
+	}
+	term := strings.ToLower(v.term)
+
+	// Get the text of each row or line, and where the cursor is.
+	var (
+		lines   []string
+		current int
+		first   int // The first line that can be moved to.
+	)
+	if v.table != nil {
+		for row := 0; row < v.table.GetRowCount(); row++ {
+			var cells []string
+			for col := 0; col < v.table.GetColumnCount(); col++ {
+				if cell := v.table.GetCell(row, col); cell != nil {
+					cells = append(cells, cell.Text)
+				}
+			}
+			lines = append(lines, strings.Join(cells, " "))
+		}
+		current, _ = v.table.GetSelection()
+		first = 1
+	} else {
+		lines = strings.Split(v.text.GetText(true), "\n")
+		current, _ = v.text.GetScrollOffset()
+	}
+	if len(lines) <= first {
+		return
+	}
+
+	step := 1
+	if matches < 0 {
+		step, matches = -1, -matches
+	}
+	found := false
+	for line, i := current, 0; i < matches; i++ {
+		// Look through every other line, wrapping around the ends.
+		for j := 0; j < len(lines)-first; j++ {
+			line = first + (line-first+step+len(lines)-first)%(len(lines)-first)
+			if strings.Contains(strings.ToLower(lines[line]), term) {
+				current, found = line, true
+				break
+			}
+		}
+	}
+	if !found {
+		notifyError(fmt.Sprintf("Pattern not found: %s", v.term))
+		return
+	}
+
+	if v.table != nil {
+		v.table.Select(current, 0)
+	} else {
+		v.text.ScrollTo(current, 0)
+	}
+}
+
+// commandInput : Allows user to enter a command, such as `:q` to go back.
+func (v *vimNavigator) commandInput() {
+	help := "Commands: [yellow]q[-] (back), [yellow]qa[-] (quit), [yellow]<number>[-] (go to line), " +
+		"[yellow]help[-], [yellow]search[-], [yellow]login[-], [yellow]noh[-] (clear search)"
+	modal := inputModal(utils.VimCommandModalID, "Command", ":", help, v.runCommand)
+	ShowModal(utils.VimCommandModalID, modal)
+}
+
+// runCommand : Run a command entered by the user.
+func (v *vimNavigator) runCommand(command string) {
+	command = strings.TrimSpace(strings.TrimPrefix(command, ":"))
+	if line, err := strconv.Atoi(command); err == nil {
+		v.goTo(max(line, 1))
+		return
+	}
+
+	switch command {
+	case "":
+	case "q", "quit":
+		v.goBack()
+	case "qa", "qall", "quitall":
+		ctrlCInput()
+	case "h", "help":
+		ShowHelpPage()
+	case "search":
+		ctrlSInput()
+	case "login", "logout":
+		ctrlLInput()
+	case "noh", "nohlsearch":
+		v.term = ""
+	default:
+		notifyError(fmt.Sprintf("Unknown command: %s", command))
+	}
+}
@@ -1,11 +1,24 @@
 package main
 
 import (
+	"os"
+
 	"github.com/darylhjd/mangadesk/app/service"
 )
 
 // Initialise the program.
 func main() {
+	// Use any options given on the command line or in environment variables.
+	args, code, exit := service.ParseOptions(os.Args[1:])
+	if exit {
+		os.Exit(code)
+	}
+
+	// Run any command given on the command line instead of the app.
+	if len(args) > 0 {
+		os.Exit(service.RunCommand(args))
+	}
+
 	// Initialise the application.
 	service.Start()
 	defer service.Shutdown()